@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codegangsta/negroni"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/auth"
+)
+
+// queueSize is the number of records waiting to be written before new ones
+// are dropped.
+const queueSize = 1000
+
+// Record is the audit record of an authenticated request.
+type Record struct {
+	ID      string            `json:"id" bson:"_id"`
+	Time    time.Time         `json:"time"`
+	Key     string            `json:"key"`
+	Name    string            `json:"name"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Params  map[string]string `json:"params,omitempty"`
+	Status  int               `json:"status"`
+	Latency int64             `json:"latencyMs"`
+	// Action and Details describe what an admin request did.
+	Action  string            `json:"action,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Auditor is the middleware recording authenticated requests.
+type Auditor interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+	// Denied records the request of identity denied for missing scope,
+	// which never reaches the middleware, see auth.Authenticator.OnDenied.
+	Denied(r *http.Request, identity auth.Identity, scope string)
+	// Close writes the queued records and closes the store. Records of
+	// requests still served after Close are dropped.
+	Close()
+}
+
+type requestAuditor struct {
+	Logger  *logrus.Logger
+	Store   Store
+	records chan Record
+	done    chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// New returns an Auditor writing to store the records of the requests
+// authenticated by the previous middleware. Records are written in the
+// background, in order.
+func New(l *logrus.Logger, store Store) Auditor {
+	ra := &requestAuditor{Logger: l, Store: store, records: make(chan Record, queueSize), done: make(chan struct{})}
+	go ra.write()
+
+	return ra
+}
+
+type recordKey struct{}
+
+// Action sets the action done by the request and its details on the audit
+// record of the request, e.g. the id of the key created by an admin.
+func Action(r *http.Request, action string, details map[string]string) {
+	rec, ok := r.Context().Value(recordKey{}).(*Record)
+	if !ok {
+		return
+	}
+
+	rec.Action = action
+	rec.Details = details
+}
+
+func (ra *requestAuditor) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	identity, ok := auth.FromContext(r.Context())
+	if !ok {
+		next(w, r)
+		return
+	}
+
+	start := time.Now()
+	rec := &Record{
+		ID:     newID(),
+		Time:   start,
+		Key:    identity.ID,
+		Name:   identity.Name,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Params: params(r),
+	}
+
+	next(w, r.WithContext(context.WithValue(r.Context(), recordKey{}, rec)))
+
+	if res, ok := w.(negroni.ResponseWriter); ok {
+		rec.Status = res.Status()
+	}
+	rec.Latency = int64(time.Since(start) / time.Millisecond)
+
+	ra.queue(r, *rec)
+}
+
+func (ra *requestAuditor) Denied(r *http.Request, identity auth.Identity, scope string) {
+	ra.queue(r, Record{
+		ID:      newID(),
+		Time:    time.Now(),
+		Key:     identity.ID,
+		Name:    identity.Name,
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Params:  params(r),
+		Status:  http.StatusForbidden,
+		Action:  "denied",
+		Details: map[string]string{"scope": scope},
+	})
+}
+
+// queue hands rec to the writer, it is dropped if the queue is full or the
+// auditor closed.
+func (ra *requestAuditor) queue(r *http.Request, rec Record) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if ra.closed {
+		ra.Logger.WithContext(r.Context()).Errorf("Auditor: closed, dropped record of %s %s by %s", rec.Method, rec.Path, rec.Name)
+		return
+	}
+
+	select {
+	case ra.records <- rec:
+	default:
+		ra.Logger.WithContext(r.Context()).Errorf("Auditor: queue full, dropped record of %s %s by %s", rec.Method, rec.Path, rec.Name)
+	}
+}
+
+func (ra *requestAuditor) Close() {
+	ra.mu.Lock()
+	first := !ra.closed
+	if first {
+		ra.closed = true
+		close(ra.records)
+	}
+	ra.mu.Unlock()
+
+	<-ra.done
+	if !first {
+		return
+	}
+
+	err := ra.Store.Close()
+	if err != nil {
+		ra.Logger.WithError(err).Errorf("Auditor: unable to close the store")
+	}
+}
+
+func (ra *requestAuditor) write() {
+	defer close(ra.done)
+
+	for rec := range ra.records {
+		err := ra.Store.Write(rec)
+		if err != nil {
+			ra.Logger.WithError(err).Errorf("Auditor: unable to write record of %s %s by %s", rec.Method, rec.Path, rec.Name)
+		}
+	}
+}
+
+func params(r *http.Request) map[string]string {
+	query := r.URL.Query()
+	if len(query) == 0 {
+		return nil
+	}
+
+	p := map[string]string{}
+	for k, v := range query {
+		p[k] = strings.Join(v, ",")
+	}
+
+	return p
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codegangsta/negroni"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/auth"
+)
+
+func newFileStore(t *testing.T) (Store, func()) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err, "Expected no error")
+
+	store, err := NewFileStore(filepath.Join(dir, "audit.jsonl"))
+	require.NoError(t, err, "Expected no error")
+
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func waitFor(t *testing.T, store Store, q Query, n int) []Record {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		records, err := store.Find(q)
+		require.NoError(t, err, "Expected no error")
+		if len(records) >= n {
+			return records
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("expected %d audit records", n)
+	return nil
+}
+
+func TestServeHTTP(t *testing.T) {
+	store, cleanup := newFileStore(t)
+	defer cleanup()
+	logger, _ := test.NewNullLogger()
+	a := New(logger, store)
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/keys?dry=1", nil)
+	r = r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{ID: "config:admin", Name: "admin"}))
+	w := negroni.NewResponseWriter(httptest.NewRecorder())
+	a.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+		Action(r, "key.create", map[string]string{"id": "k1"})
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	// anonymous requests are not recorded.
+	a.ServeHTTP(negroni.NewResponseWriter(httptest.NewRecorder()), httptest.NewRequest(http.MethodGet, "/healthcheck", nil),
+		func(w http.ResponseWriter, r *http.Request) {})
+
+	records := waitFor(t, store, Query{}, 1)
+	require.Len(t, records, 1)
+
+	rec := records[0]
+	assert.Equal(t, "config:admin", rec.Key)
+	assert.Equal(t, "admin", rec.Name)
+	assert.Equal(t, http.MethodPost, rec.Method)
+	assert.Equal(t, "/admin/keys", rec.Path)
+	assert.Equal(t, map[string]string{"dry": "1"}, rec.Params)
+	assert.Equal(t, http.StatusCreated, rec.Status)
+	assert.Equal(t, "key.create", rec.Action)
+	assert.Equal(t, map[string]string{"id": "k1"}, rec.Details)
+}
+
+func TestCloseWritesQueuedRecords(t *testing.T) {
+	store, cleanup := newFileStore(t)
+	defer cleanup()
+	logger, _ := test.NewNullLogger()
+	a := New(logger, store)
+
+	for i := 0; i < 10; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+		r = r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{ID: "config:web", Name: "web"}))
+		a.ServeHTTP(negroni.NewResponseWriter(httptest.NewRecorder()), r, func(w http.ResponseWriter, r *http.Request) {})
+	}
+	a.Close()
+
+	records, err := store.Find(Query{})
+	require.NoError(t, err, "Expected no error")
+	assert.Len(t, records, 10)
+}
+
+func TestServeHTTPAfterClose(t *testing.T) {
+	store, cleanup := newFileStore(t)
+	defer cleanup()
+	logger, hook := test.NewNullLogger()
+	a := New(logger, store)
+
+	r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+	r = r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{ID: "config:web", Name: "web"}))
+	a.ServeHTTP(negroni.NewResponseWriter(httptest.NewRecorder()), r, func(w http.ResponseWriter, r *http.Request) {
+		// the server closed the connection and gave up on the request.
+		a.Close()
+	})
+	a.Close()
+
+	records, err := store.Find(Query{})
+	require.NoError(t, err, "Expected no error")
+	assert.Empty(t, records)
+	require.NotNil(t, hook.LastEntry())
+	assert.Equal(t, "Auditor: closed, dropped record of GET /stock/AAPL by web", hook.LastEntry().Message)
+}
+
+func TestDenied(t *testing.T) {
+	store, cleanup := newFileStore(t)
+	defer cleanup()
+	logger, _ := test.NewNullLogger()
+	a := New(logger, store)
+
+	a.Denied(httptest.NewRequest(http.MethodGet, "/admin/keys", nil), auth.Identity{ID: "config:web", Name: "web"}, auth.ScopeAdmin)
+	a.Close()
+
+	records, err := store.Find(Query{})
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, records, 1)
+
+	rec := records[0]
+	assert.Equal(t, "config:web", rec.Key)
+	assert.Equal(t, "/admin/keys", rec.Path)
+	assert.Equal(t, http.StatusForbidden, rec.Status)
+	assert.Equal(t, "denied", rec.Action)
+	assert.Equal(t, map[string]string{"scope": auth.ScopeAdmin}, rec.Details)
+}
+
+func TestCloseClosesStore(t *testing.T) {
+	store, cleanup := newFileStore(t)
+	defer cleanup()
+	logger, hook := test.NewNullLogger()
+	a := New(logger, store)
+
+	a.Close()
+	a.Close()
+
+	err := store.Write(Record{ID: "1"})
+	assert.Error(t, err, "Expected the audit file to be closed")
+	assert.Empty(t, hook.AllEntries(), "Expected the store to be closed once")
+}
+
+func TestIndexes(t *testing.T) {
+	indexes := Indexes(24 * time.Hour)
+	require.Len(t, indexes, 3)
+	assert.Equal(t, "time", *indexes[0].Options.Name)
+	assert.Equal(t, int32(86400), *indexes[0].Options.ExpireAfterSeconds)
+
+	indexes = Indexes(0)
+	assert.Nil(t, indexes[0].Options.ExpireAfterSeconds, "Expected the records to be kept")
+}
+
+func TestFileStoreFind(t *testing.T) {
+	store, cleanup := newFileStore(t)
+	defer cleanup()
+
+	start := time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i, name := range []string{"web", "admin", "web", "web"} {
+		err := store.Write(Record{ID: name + string(rune('0'+i)), Key: "id-" + name, Name: name, Time: start.Add(time.Duration(i) * time.Hour)})
+		require.NoError(t, err, "Expected no error")
+	}
+
+	records, err := store.Find(Query{Key: "web", From: start.Add(time.Hour), To: start.Add(3 * time.Hour)})
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, records, 1)
+	assert.Equal(t, "web2", records[0].ID)
+
+	records, err = store.Find(Query{Key: "id-web", Limit: 2})
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, records, 2)
+	assert.Equal(t, "web3", records[0].ID)
+	assert.Equal(t, "web2", records[1].ID)
+}
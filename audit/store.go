@@ -0,0 +1,232 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Query filters audit records.
+type Query struct {
+	// Key is the id or the name of a key, any key when empty.
+	Key  string
+	From time.Time
+	To   time.Time
+	// Limit is the maximum number of records, the latest first.
+	Limit int64
+}
+
+func (q Query) match(rec Record) bool {
+	if q.Key != "" && rec.Key != q.Key && rec.Name != q.Key {
+		return false
+	}
+
+	if !q.From.IsZero() && rec.Time.Before(q.From) {
+		return false
+	}
+
+	if !q.To.IsZero() && !rec.Time.Before(q.To) {
+		return false
+	}
+
+	return true
+}
+
+// Store keeps audit records.
+type Store interface {
+	Write(Record) error
+	Find(Query) ([]Record, error)
+	// Close releases the store once no record is written anymore.
+	Close() error
+}
+
+type mongoStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoStore returns a Store backed by collection c.
+func NewMongoStore(c *mongo.Collection) Store {
+	return &mongoStore{Collection: c}
+}
+
+// Indexes returns the index definitions for the audit collection: the time
+// of the records, expiring them once older than retention unless it is 0,
+// and the key and the name used by Find, latest first.
+func Indexes(retention time.Duration) []mongo.IndexModel {
+	timeOptions := options.Index().SetName("time")
+	if retention > 0 {
+		timeOptions.SetExpireAfterSeconds(int32(retention / time.Second))
+	}
+
+	return []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "time", Value: 1}},
+			Options: timeOptions,
+		},
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}, {Key: "time", Value: -1}},
+			Options: options.Index().SetName("key_time"),
+		},
+		{
+			Keys:    bson.D{{Key: "name", Value: 1}, {Key: "time", Value: -1}},
+			Options: options.Index().SetName("name_time"),
+		},
+	}
+}
+
+// EnsureIndexes creates the indexes of the audit collection c which do not
+// exist yet. The time index keeps the retention it was created with, it has
+// to be dropped for a new retention to apply.
+func EnsureIndexes(c *mongo.Collection, retention time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	_, err := c.Indexes().CreateMany(ctx, Indexes(retention))
+	if err != nil {
+		return fmt.Errorf("ensureIndexes: unable to create audit indexes: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mongoStore) Write(rec Record) error {
+	_, err := s.Collection.InsertOne(context.Background(), rec)
+	if err != nil {
+		return fmt.Errorf("write: unable to insert audit record: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mongoStore) Find(q Query) ([]Record, error) {
+	filter := bson.D{}
+	if q.Key != "" {
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "key", Value: q.Key}},
+			bson.D{{Key: "name", Value: q.Key}},
+		}})
+	}
+
+	times := bson.D{}
+	if !q.From.IsZero() {
+		times = append(times, bson.E{Key: "$gte", Value: q.From})
+	}
+	if !q.To.IsZero() {
+		times = append(times, bson.E{Key: "$lt", Value: q.To})
+	}
+	if len(times) > 0 {
+		filter = append(filter, bson.E{Key: "time", Value: times})
+	}
+
+	ctx := context.Background()
+	cur, err := s.Collection.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "time", Value: -1}}).SetLimit(q.Limit))
+	if err != nil {
+		return nil, fmt.Errorf("find: unable to find audit records: %s", err)
+	}
+	defer cur.Close(ctx)
+
+	records := []Record{}
+	for cur.Next(ctx) {
+		var rec Record
+		err = cur.Decode(&rec)
+		if err != nil {
+			return nil, fmt.Errorf("find: error decoding audit record: %s", err)
+		}
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+func (s *mongoStore) Close() error {
+	return nil
+}
+
+type fileStore struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileStore returns a Store appending records as JSON lines to the file
+// at path. Finding records reads the whole file.
+func NewFileStore(path string) (Store, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("newFileStore: unable to open audit file: %s", err)
+	}
+
+	return &fileStore{path: path, file: file}, nil
+}
+
+func (s *fileStore) Write(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("write: unable to encode audit record: %s", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.file.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("write: unable to write audit record: %s", err)
+	}
+
+	return nil
+}
+
+func (s *fileStore) Find(q Query) ([]Record, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("find: unable to open audit file: %s", err)
+	}
+	defer file.Close()
+
+	records := []Record{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec Record
+		err = json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			return nil, fmt.Errorf("find: invalid audit record: %s", err)
+		}
+
+		if q.match(rec) {
+			records = append(records, rec)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("find: unable to read audit file: %s", err)
+	}
+
+	sort.SliceStable(records, func(a, b int) bool { return records[a].Time.After(records[b].Time) })
+	if q.Limit > 0 && int64(len(records)) > q.Limit {
+		records = records[:q.Limit]
+	}
+
+	return records, nil
+}
+
+func (s *fileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.file.Close()
+	if err != nil {
+		return fmt.Errorf("close: unable to close audit file: %s", err)
+	}
+
+	return nil
+}
@@ -2,40 +2,209 @@ package auth
 
 import (
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/vikashvverma/stock-backend/log"
 	"github.com/vikashvverma/stock-backend/response"
 )
 
+// Authenticator is the middleware authenticating requests by API key,
+// bearer token or client certificate.
 type Authenticator interface {
 	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+	// OnDenied calls fn with the requests of a valid identity denied for
+	// missing scope. It is set before serving requests.
+	OnDenied(fn func(r *http.Request, identity Identity, scope string))
+	// Close saves the usage not saved yet, once the requests are served.
+	Close()
+}
+
+// usageInterval is how often the usage of the keys is saved.
+const usageInterval = 10 * time.Second
+
+type usage struct {
+	requests int64
+	lastUsed time.Time
 }
 
 type requestAuthenticator struct {
-	Logger        *logrus.Logger
-	APIKey        string
-	AllowedRoutes []string
+	Logger *logrus.Logger
+	Keys   KeyStore
+	Tokens Verifier
+	Certs  Certificates
+	Rules  Rules
+
+	denied func(r *http.Request, identity Identity, scope string)
+
+	mu    sync.Mutex
+	usage map[string]usage
+
+	stop chan struct{}
+	done chan struct{}
 }
 
-func New(l *logrus.Logger, apiKey string, ar []string) Authenticator {
-	return &requestAuthenticator{Logger: l, APIKey: apiKey, AllowedRoutes: ar}
+// New returns an Authenticator looking up the API-KEY header in the key
+// store, verifying the Authorization bearer token with tokens if not nil,
+// or mapping the verified client certificate with certs. The first of the
+// rules matching a request tells whether it is public or the scopes it
+// requires. The usage of the keys is counted in memory and saved every
+// usageInterval.
+func New(l *logrus.Logger, keys KeyStore, tokens Verifier, certs Certificates, rules Rules) Authenticator {
+	ra := &requestAuthenticator{Logger: l, Keys: keys, Tokens: tokens, Certs: certs, Rules: rules, usage: map[string]usage{},
+		stop: make(chan struct{}), done: make(chan struct{})}
+	go ra.saveUsage()
+
+	return ra
 }
 
 func (ra *requestAuthenticator) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	for _, route := range ra.AllowedRoutes {
-		if route == r.RequestURI {
-			next(w, r)
+	rule, ok := ra.Rules.Match(r.Method, r.URL.Path)
+	if ok && rule.Public {
+		next(w, r)
+		return
+	}
+
+	identity, ok := ra.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	for _, scope := range rule.Scopes {
+		if !identity.HasScope(scope) {
+			ra.Logger.WithContext(r.Context()).Errorf("Authenticator: %s is missing scope %s for %s %s", identity.Name, scope, r.Method, r.URL.Path)
+			response.Response{Errors: &response.Error{Reason: "forbidden, missing scope " + scope, Code: response.CodeForbidden,
+				Details: map[string]string{"scope": scope}}}.Forbidden(w)
+			if ra.denied != nil {
+				ra.denied(r, identity, scope)
+			}
 			return
 		}
 	}
 
+	next(w, r.WithContext(WithIdentity(r.Context(), identity)))
+}
+
+func (ra *requestAuthenticator) OnDenied(fn func(r *http.Request, identity Identity, scope string)) {
+	ra.denied = fn
+}
+
+// authenticate returns the identity of the request. It writes the error
+// response and returns false if the request has no valid credentials.
+func (ra *requestAuthenticator) authenticate(w http.ResponseWriter, r *http.Request) (Identity, bool) {
+	if bearer := r.Header.Get("Authorization"); ra.Tokens != nil && strings.HasPrefix(bearer, "Bearer ") {
+		identity, err := ra.Tokens.Verify(strings.TrimPrefix(bearer, "Bearer "), time.Now())
+		if err != nil {
+			ra.Logger.WithContext(r.Context()).WithError(err).Errorf("Authenticator: unauthorized, invalid bearer token")
+			forbidden(w, r)
+			return Identity{}, false
+		}
+
+		log.AddFields(r, logrus.Fields{"Subject": identity.Name})
+
+		return identity, true
+	}
+
 	authToken := r.Header.Get("API-KEY")
-	if authToken != ra.APIKey {
-		ra.Logger.Errorf("Authenticator: unauthorized")
-		response.Error{Reason: "forbidden"}.Forbidden(w)
-		return
+	if authToken == "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+		identity, ok := ra.Certs.Identity(cert)
+		if !ok {
+			ra.Logger.WithContext(r.Context()).Errorf("Authenticator: unauthorized, unknown client certificate %s", cert.Subject)
+			forbidden(w, r)
+			return Identity{}, false
+		}
+
+		log.AddFields(r, logrus.Fields{"Certificate": identity.Name})
+
+		return identity, true
+	}
+
+	if authToken == "" {
+		ra.Logger.WithContext(r.Context()).Errorf("Authenticator: unauthorized, no API key")
+		forbidden(w, r)
+		return Identity{}, false
+	}
+
+	key, err := ra.Keys.Lookup(Hash(authToken))
+	if err == ErrKeyNotFound {
+		ra.Logger.WithContext(r.Context()).Errorf("Authenticator: unauthorized, unknown API key")
+		forbidden(w, r)
+		return Identity{}, false
+	}
+	if err != nil {
+		// the key may well be valid, the client should retry.
+		ra.Logger.WithContext(r.Context()).WithError(err).Errorf("Authenticator: unable to look up API key")
+		response.Response{Errors: &response.Error{Reason: "unable to check API key", Code: response.CodeUnavailable}}.ServiceUnavailable(w)
+		return Identity{}, false
 	}
 
-	next(w, r)
+	if err := key.Check(time.Now()); err != nil {
+		ra.Logger.WithContext(r.Context()).WithError(err).Errorf("Authenticator: unauthorized")
+		forbidden(w, r)
+		return Identity{}, false
+	}
+
+	ra.use(key.ID)
+
+	identity := key.Identity()
+	log.AddFields(r, logrus.Fields{"Key": identity.Name})
+
+	return identity, true
+}
+
+func (ra *requestAuthenticator) use(id string) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	u := ra.usage[id]
+	u.requests++
+	u.lastUsed = time.Now()
+	ra.usage[id] = u
+}
+
+func (ra *requestAuthenticator) saveUsage() {
+	defer close(ra.done)
+
+	ticker := time.NewTicker(usageInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ra.flush()
+		case <-ra.stop:
+			ra.flush()
+			return
+		}
+	}
+}
+
+func (ra *requestAuthenticator) Close() {
+	close(ra.stop)
+	<-ra.done
+}
+
+// flush saves the usage counted since the last flush.
+func (ra *requestAuthenticator) flush() {
+	ra.mu.Lock()
+	pending := ra.usage
+	ra.usage = map[string]usage{}
+	ra.mu.Unlock()
+
+	for id, u := range pending {
+		err := ra.Keys.Touch(id, u.requests, u.lastUsed)
+		if err != nil {
+			ra.Logger.WithError(err).Errorf("Authenticator: unable to save key usage")
+		}
+	}
+}
+
+// forbidden answers a request whose credentials are invalid.
+func forbidden(w http.ResponseWriter, r *http.Request) {
+	fail(r.Context())
+	response.Response{Errors: &response.Error{Reason: "forbidden", Code: response.CodeForbidden}}.Forbidden(w)
 }
@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+func newAuthenticator(t *testing.T) (*requestAuthenticator, KeyStore) {
+	logger, _ := test.NewNullLogger()
+	store := NewMemoryKeyStore()
+
+	expired := time.Now().Add(-time.Hour)
+	require.NoError(t, store.Seed([]Key{
+		{ID: "1", Name: "web", Hash: Hash("web-secret"), Scopes: []string{ScopeRead}},
+		{ID: "2", Name: "old", Hash: Hash("old-secret"), Scopes: []string{ScopeRead}, ExpiresAt: &expired},
+		{ID: "3", Name: "off", Hash: Hash("off-secret"), Scopes: []string{ScopeRead}, Disabled: true},
+	}))
+
+	rules, err := NewRules([]config.RouteSpec{
+		{Method: http.MethodGet, Path: "/healthcheck", Public: true},
+		{Path: "/stock/top/{from}/{to}", Scopes: []string{ScopeAnalytics}},
+	})
+	require.NoError(t, err, "Expected no error")
+
+	return New(logger, store, nil, nil, rules).(*requestAuthenticator), store
+}
+
+func serve(a Authenticator, r *http.Request) (*httptest.ResponseRecorder, *Identity) {
+	w := httptest.NewRecorder()
+	var identity *Identity
+	a.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+		if i, ok := FromContext(r.Context()); ok {
+			identity = &i
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	return w, identity
+}
+
+func TestServeHTTP(t *testing.T) {
+	a, _ := newAuthenticator(t)
+	r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+	r.Header.Set("API-KEY", "web-secret")
+
+	w, identity := serve(a, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, identity)
+	assert.Equal(t, Identity{ID: "1", Name: "web", Scopes: []string{ScopeRead}}, *identity)
+}
+
+func TestServeHTTPAllowsPublicRoutes(t *testing.T) {
+	a, _ := newAuthenticator(t)
+
+	w, identity := serve(a, httptest.NewRequest(http.MethodGet, "/healthcheck?probe=1", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Nil(t, identity)
+
+	w, _ = serve(a, httptest.NewRequest(http.MethodPost, "/healthcheck", nil))
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestServeHTTPRejectsInvalidKeys(t *testing.T) {
+	a, _ := newAuthenticator(t)
+
+	for _, key := range []string{"", "unknown", "old-secret", "off-secret"} {
+		r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+		r.Header.Set("API-KEY", key)
+
+		w, identity := serve(a, r)
+
+		assert.Equal(t, http.StatusForbidden, w.Code, key)
+		assert.Nil(t, identity, key)
+	}
+}
+
+type failingKeyStore struct {
+	KeyStore
+}
+
+func (failingKeyStore) Lookup(string) (Key, error) {
+	return Key{}, fmt.Errorf("lookup: unable to find key: server selection timeout")
+}
+
+func TestServeHTTPWhenKeyStoreFails(t *testing.T) {
+	a, store := newAuthenticator(t)
+	a.Keys = failingKeyStore{KeyStore: store}
+	r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+	r.Header.Set("API-KEY", "web-secret")
+
+	w, identity := serve(a, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"success":false,"errors":{"reason":"unable to check API key","code":"unavailable"}}`, w.Body.String())
+	assert.Nil(t, identity)
+}
+
+func TestServeHTTPRequiresRouteScopes(t *testing.T) {
+	a, store := newAuthenticator(t)
+	require.NoError(t, store.Seed([]Key{
+		{ID: "4", Name: "analyst", Hash: Hash("analyst-secret"), Scopes: []string{ScopeRead, ScopeAnalytics}},
+		{ID: "5", Name: "admin", Hash: Hash("admin-secret"), Scopes: []string{ScopeAdmin}},
+	}))
+
+	for key, code := range map[string]int{
+		"web-secret":     http.StatusForbidden,
+		"analyst-secret": http.StatusOK,
+		"admin-secret":   http.StatusOK,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/stock/top/01-01-2016/01-02-2016", nil)
+		r.Header.Set("API-KEY", key)
+
+		w, _ := serve(a, r)
+
+		assert.Equal(t, code, w.Code, key)
+	}
+}
+
+func TestServeHTTPReportsDeniedScopes(t *testing.T) {
+	a, _ := newAuthenticator(t)
+	var denied []string
+	a.OnDenied(func(r *http.Request, identity Identity, scope string) {
+		denied = append(denied, identity.Name+" "+scope)
+	})
+
+	for _, key := range []string{"web-secret", "unknown"} {
+		r := httptest.NewRequest(http.MethodGet, "/stock/top/01-01-2016/01-02-2016", nil)
+		r.Header.Set("API-KEY", key)
+
+		w, _ := serve(a, r)
+
+		assert.Equal(t, http.StatusForbidden, w.Code, key)
+	}
+	assert.Equal(t, []string{"web analytics"}, denied, "Expected only the valid key to be reported")
+}
+
+func TestServeHTTPWithClientCertificate(t *testing.T) {
+	a, _ := newAuthenticator(t)
+	certs, err := NewCertificates([]config.ClientCertSpec{
+		{Subject: "CN=batch,O=Example", Name: "batch", Scopes: []string{ScopeRead, ScopeAnalytics}},
+		{Subject: "web", Name: "web-client", Scopes: []string{ScopeRead}},
+	})
+	require.NoError(t, err, "Expected no error")
+	a.Certs = certs
+
+	for cn, code := range map[string]int{"batch": http.StatusOK, "web": http.StatusForbidden, "unknown": http.StatusForbidden} {
+		r := httptest.NewRequest(http.MethodGet, "/stock/top/01-01-2016/01-02-2016", nil)
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn, Organization: []string{"Example"}}}
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+		w, _ := serve(a, r)
+
+		assert.Equal(t, code, w.Code, cn)
+	}
+
+	_, err = NewCertificates([]config.ClientCertSpec{{Subject: "web", Name: "web", Scopes: []string{"write"}}})
+	assert.EqualError(t, err, `newCertificates: unknown scope "write" for web`)
+}
+
+func TestSeedKeys(t *testing.T) {
+	store := NewMemoryKeyStore()
+	expiresAt := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	err := SeedKeys(store, []config.KeySpec{{Name: "web", Key: "web-secret", Scopes: []string{ScopeRead}, ExpiresAt: expiresAt}})
+	require.NoError(t, err, "Expected no error")
+
+	k, err := store.Lookup(Hash("web-secret"))
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, "config:web", k.ID)
+	assert.True(t, k.Configured())
+	assert.Equal(t, []string{ScopeRead}, k.Scopes)
+	assert.Equal(t, expiresAt, *k.ExpiresAt)
+}
+
+func TestSeedKeysDeletesRemovedKeys(t *testing.T) {
+	store := NewMemoryKeyStore()
+	require.NoError(t, store.Create(Key{ID: "1", Name: "created", Hash: Hash("created-secret"), Scopes: []string{ScopeRead}}))
+
+	err := SeedKeys(store, []config.KeySpec{{Name: "web", Key: "web-secret", Scopes: []string{ScopeRead}},
+		{Name: "batch", Key: "batch-secret", Scopes: []string{ScopeRead}}})
+	require.NoError(t, err, "Expected no error")
+
+	err = SeedKeys(store, []config.KeySpec{{Name: "web", Key: "web-secret", Scopes: []string{ScopeRead}}})
+	require.NoError(t, err, "Expected no error")
+
+	_, err = store.Get("config:batch")
+	assert.Equal(t, ErrKeyNotFound, err)
+	_, err = store.Get("config:web")
+	assert.NoError(t, err, "Expected no error")
+	_, err = store.Get("1")
+	assert.NoError(t, err, "Expected the keys created by the API to be kept")
+}
+
+func TestValidateKeys(t *testing.T) {
+	err := ValidateKeys([]config.KeySpec{{Name: "web", Key: "web-secret", Scopes: []string{ScopeRead, ScopeAdmin}}})
+	assert.NoError(t, err, "Expected no error")
+
+	err = ValidateKeys([]config.KeySpec{{Name: "web", Key: "web-secret", Scopes: []string{"raed"}}})
+	assert.EqualError(t, err, `validateKeys: key web: unknown scope "raed"`)
+
+	err = ValidateKeys([]config.KeySpec{{Name: "web", Key: "web-secret", Scopes: []string{""}}})
+	assert.EqualError(t, err, `validateKeys: key web: unknown scope ""`)
+
+	err = ValidateKeys([]config.KeySpec{{Name: "web", Key: "web-secret"}})
+	assert.EqualError(t, err, "validateKeys: key web: at least one scope is required")
+
+	err = ValidateKeys([]config.KeySpec{{Name: "web", Key: "web-secret", Scopes: []string{ScopeRead}},
+		{Name: "web", Key: "other-secret", Scopes: []string{ScopeRead}}})
+	assert.EqualError(t, err, `validateKeys: duplicate key "web"`)
+}
+
+func TestSeedKeysFailsWhenKeyInvalid(t *testing.T) {
+	store := NewMemoryKeyStore()
+
+	err := SeedKeys(store, []config.KeySpec{{Name: "web", Key: "web-secret", Scopes: []string{"raed"}}})
+	assert.Error(t, err, "Expected an error")
+
+	keys, err := store.List()
+	require.NoError(t, err, "Expected no error")
+	assert.Empty(t, keys, "Expected no key to be seeded")
+}
+
+func TestServeHTTPRecordsUsage(t *testing.T) {
+	a, store := newAuthenticator(t)
+
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+		r.Header.Set("API-KEY", "web-secret")
+		serve(a, r)
+	}
+	a.flush()
+
+	k, err := store.Get("1")
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, int64(3), k.Requests)
+	require.NotNil(t, k.LastUsed)
+	assert.WithinDuration(t, time.Now(), *k.LastUsed, time.Minute)
+}
+
+func TestCloseSavesUsage(t *testing.T) {
+	a, store := newAuthenticator(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+	r.Header.Set("API-KEY", "web-secret")
+	serve(a, r)
+	a.Close()
+
+	k, err := store.Get("1")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, int64(1), k.Requests)
+}
+
+func TestNewKey(t *testing.T) {
+	k, secret, err := NewKey("web", []string{ScopeRead}, nil)
+	require.NoError(t, err, "Expected no error")
+
+	assert.NotEmpty(t, k.ID)
+	assert.Equal(t, Hash(secret), k.Hash)
+
+	old := k.Hash
+	rotated, err := k.Rotate()
+	require.NoError(t, err, "Expected no error")
+	assert.NotEqual(t, secret, rotated)
+	assert.NotEqual(t, old, k.Hash)
+
+	_, _, err = NewKey("web", []string{"write"}, nil)
+	assert.EqualError(t, err, `newKey: unknown scope "write"`)
+
+	_, _, err = NewKey("", []string{ScopeRead}, nil)
+	assert.EqualError(t, err, "newKey: name is required")
+}
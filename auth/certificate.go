@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+// certificatePrefix prefixes the id of the identities of client
+// certificates.
+const certificatePrefix = "cert:"
+
+// Certificates maps the subjects of verified client certificates to
+// identities.
+type Certificates map[string]Identity
+
+// NewCertificates returns the identities of the configured client
+// certificates.
+func NewCertificates(specs []config.ClientCertSpec) (Certificates, error) {
+	certs := Certificates{}
+	for _, spec := range specs {
+		for _, scope := range spec.Scopes {
+			if !ValidScope(scope) {
+				return nil, fmt.Errorf("newCertificates: unknown scope %q for %s", scope, spec.Subject)
+			}
+		}
+
+		certs[spec.Subject] = Identity{ID: certificatePrefix + spec.Name, Name: spec.Name, Scopes: spec.Scopes}
+	}
+
+	return certs, nil
+}
+
+// Identity returns the identity of cert, matching its full subject first,
+// then its common name.
+func (c Certificates) Identity(cert *x509.Certificate) (Identity, bool) {
+	if identity, ok := c[cert.Subject.String()]; ok {
+		return identity, true
+	}
+
+	identity, ok := c[cert.Subject.CommonName]
+	return identity, ok
+}
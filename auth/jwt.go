@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+// leeway is the clock skew tolerated checking the token times.
+const leeway = 30 * time.Second
+
+// Verifier verifies bearer tokens.
+type Verifier interface {
+	// Verify returns the identity of a valid token.
+	Verify(token string, now time.Time) (Identity, error)
+}
+
+type jwtVerifier struct {
+	issuer   string
+	audience string
+	secret   []byte
+	keys     map[string]*rsa.PublicKey
+}
+
+// NewVerifier returns a Verifier of the JWTs signed with the secret (HS256)
+// or one of the keys of the JWKS file (RS256), issued by the issuer for the
+// audience of spec.
+func NewVerifier(spec config.JWTSpec) (Verifier, error) {
+	v := &jwtVerifier{
+		issuer:   spec.Issuer,
+		audience: spec.Audience,
+		secret:   []byte(spec.Secret),
+	}
+
+	if spec.JWKSFile != "" {
+		content, err := ioutil.ReadFile(spec.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("newVerifier: unable to read JWKS file: %s", err)
+		}
+
+		v.keys, err = parseJWKS(content)
+		if err != nil {
+			return nil, fmt.Errorf("newVerifier: %s", err)
+		}
+	}
+
+	return v, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scopes    []string        `json:"scopes"`
+}
+
+func (v *jwtVerifier) Verify(token string, now time.Time) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, fmt.Errorf("verify: malformed token")
+	}
+
+	var header jwtHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return Identity{}, fmt.Errorf("verify: invalid header: %s", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, fmt.Errorf("verify: invalid signature encoding: %s", err)
+	}
+
+	err = v.verifySignature(header, []byte(parts[0]+"."+parts[1]), signature)
+	if err != nil {
+		return Identity{}, fmt.Errorf("verify: %s", err)
+	}
+
+	var claims jwtClaims
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return Identity{}, fmt.Errorf("verify: invalid claims: %s", err)
+	}
+
+	err = v.checkClaims(claims, now)
+	if err != nil {
+		return Identity{}, fmt.Errorf("verify: %s", err)
+	}
+
+	return claims.identity(), nil
+}
+
+func (v *jwtVerifier) verifySignature(header jwtHeader, signed, signature []byte) error {
+	sum := sha256.Sum256(signed)
+
+	switch header.Alg {
+	case "HS256":
+		if len(v.secret) == 0 {
+			return fmt.Errorf("HS256 tokens are not accepted")
+		}
+
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("invalid signature")
+		}
+	case "RS256":
+		key, err := v.key(header.Kid)
+		if err != nil {
+			return err
+		}
+
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], signature)
+		if err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	return nil
+}
+
+// key returns the RSA key with the given id, or the only key when the
+// token has no key id.
+func (v *jwtVerifier) key(kid string) (*rsa.PublicKey, error) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, nil
+		}
+	}
+
+	k, ok := v.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	return k, nil
+}
+
+func (v *jwtVerifier) checkClaims(c jwtClaims, now time.Time) error {
+	if c.ExpiresAt == nil {
+		return fmt.Errorf("token has no expiry")
+	}
+
+	if now.Add(-leeway).After(time.Unix(*c.ExpiresAt, 0)) {
+		return fmt.Errorf("token expired")
+	}
+
+	if c.NotBefore != nil && now.Add(leeway).Before(time.Unix(*c.NotBefore, 0)) {
+		return fmt.Errorf("token not valid yet")
+	}
+
+	if c.Issuer != v.issuer {
+		return fmt.Errorf("unexpected issuer %q", c.Issuer)
+	}
+
+	audiences, err := c.audiences()
+	if err != nil {
+		return err
+	}
+
+	for _, aud := range audiences {
+		if aud == v.audience {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unexpected audience %v", audiences)
+}
+
+// audiences returns the aud claim, a string or an array of strings.
+func (c jwtClaims) audiences() ([]string, error) {
+	if len(c.Audience) == 0 {
+		return nil, nil
+	}
+
+	var aud string
+	if err := json.Unmarshal(c.Audience, &aud); err == nil {
+		return []string{aud}, nil
+	}
+
+	var auds []string
+	if err := json.Unmarshal(c.Audience, &auds); err != nil {
+		return nil, fmt.Errorf("invalid audience: %s", err)
+	}
+
+	return auds, nil
+}
+
+// identity maps the claims to an identity. The scopes are read from the
+// space separated scope claim or the scopes array claim, unknown scopes are
+// left out.
+func (c jwtClaims) identity() Identity {
+	scopes := []string{}
+	for _, s := range append(strings.Fields(c.Scope), c.Scopes...) {
+		if ValidScope(s) {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return Identity{ID: "jwt:" + c.Subject, Name: c.Subject, Scopes: scopes}
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	content, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, v)
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// parseJWKS returns the RSA keys of a JSON Web Key Set by key id, the other
+// key types are ignored.
+func parseJWKS(content []byte) (map[string]*rsa.PublicKey, error) {
+	var set jwks
+	err := json.Unmarshal(content, &set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %s", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus of key %q: %s", k.Kid, err)
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exponent of key %q: %s", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no RSA key in JWKS")
+	}
+
+	return keys, nil
+}
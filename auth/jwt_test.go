@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+func segment(t *testing.T, v interface{}) string {
+	content, err := json.Marshal(v)
+	require.NoError(t, err, "Expected no error")
+
+	return base64.RawURLEncoding.EncodeToString(content)
+}
+
+func signHS256(t *testing.T, secret string, claims map[string]interface{}) string {
+	signed := segment(t, map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + segment(t, claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	signed := segment(t, map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid}) + "." + segment(t, claims)
+	sum := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	require.NoError(t, err, "Expected no error signing")
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func claims(exp time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":   "https://id.example.com/",
+		"sub":   "alice",
+		"aud":   []string{"stock", "other"},
+		"exp":   exp.Unix(),
+		"scope": "read analytics openid",
+	}
+}
+
+func TestVerifyHS256(t *testing.T) {
+	v, err := NewVerifier(config.JWTSpec{Issuer: "https://id.example.com/", Audience: "stock", Secret: "foo"})
+	require.NoError(t, err, "Expected no error")
+
+	now := time.Now()
+	identity, err := v.Verify(signHS256(t, "foo", claims(now.Add(time.Hour))), now)
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, Identity{ID: "jwt:alice", Name: "alice", Scopes: []string{ScopeRead, ScopeAnalytics}}, identity)
+}
+
+func TestVerifyRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err, "Expected no error generating key")
+
+	content, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	require.NoError(t, err, "Expected no error")
+
+	file, err := ioutil.TempFile("", "jwks")
+	require.NoError(t, err, "Expected no error")
+	defer os.Remove(file.Name())
+	_, err = file.Write(content)
+	require.NoError(t, err, "Expected no error writing JWKS")
+	require.NoError(t, file.Close(), "Expected no error closing file")
+
+	v, err := NewVerifier(config.JWTSpec{Issuer: "https://id.example.com/", Audience: "stock", JWKSFile: file.Name()})
+	require.NoError(t, err, "Expected no error")
+
+	now := time.Now()
+	c := claims(now.Add(time.Hour))
+	delete(c, "scope")
+	c["scopes"] = []string{"admin"}
+
+	identity, err := v.Verify(signRS256(t, key, "k1", c), now)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, []string{ScopeAdmin}, identity.Scopes)
+
+	_, err = v.Verify(signRS256(t, key, "k2", c), now)
+	assert.EqualError(t, err, `verify: unknown key "k2"`)
+
+	_, err = v.Verify(signHS256(t, "", c), now)
+	assert.EqualError(t, err, "verify: HS256 tokens are not accepted")
+}
+
+func TestVerifyFails(t *testing.T) {
+	v, err := NewVerifier(config.JWTSpec{Issuer: "https://id.example.com/", Audience: "stock", Secret: "foo"})
+	require.NoError(t, err, "Expected no error")
+
+	now := time.Now()
+	wrongIssuer := claims(now.Add(time.Hour))
+	wrongIssuer["iss"] = "https://evil.example.com/"
+	wrongAudience := claims(now.Add(time.Hour))
+	wrongAudience["aud"] = "other"
+	noExpiry := claims(now)
+	delete(noExpiry, "exp")
+	unsigned := segment(t, map[string]string{"alg": "none"}) + "." + segment(t, claims(now.Add(time.Hour))) + "."
+
+	for token, reason := range map[string]string{
+		signHS256(t, "bar", claims(now.Add(time.Hour))):  "verify: invalid signature",
+		signHS256(t, "foo", claims(now.Add(-time.Hour))): "verify: token expired",
+		signHS256(t, "foo", noExpiry):                    "verify: token has no expiry",
+		signHS256(t, "foo", wrongIssuer):                 `verify: unexpected issuer "https://evil.example.com/"`,
+		signHS256(t, "foo", wrongAudience):               "verify: unexpected audience [other]",
+		unsigned:                                         `verify: unsupported algorithm "none"`,
+		"foo.bar":                                        "verify: malformed token",
+	} {
+		_, err := v.Verify(token, now)
+		assert.EqualError(t, err, reason)
+	}
+}
+
+func TestServeHTTPWithBearerToken(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	v, err := NewVerifier(config.JWTSpec{Issuer: "https://id.example.com/", Audience: "stock", Secret: "foo"})
+	require.NoError(t, err, "Expected no error")
+	a := New(logger, NewMemoryKeyStore(), v, nil, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", signHS256(t, "foo", claims(time.Now().Add(time.Hour)))))
+	w, identity := serve(a, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NotNil(t, identity)
+	assert.Equal(t, "alice", identity.Name)
+
+	r = httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+	r.Header.Set("Authorization", fmt.Sprintf("Bearer %s", signHS256(t, "bar", claims(time.Now().Add(time.Hour)))))
+	w, identity = serve(a, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Nil(t, identity)
+}
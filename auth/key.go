@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// configPrefix prefixes the id of the keys seeded from the configuration.
+const configPrefix = "config:"
+
+// API key scopes.
+const (
+	// ScopeRead allows reading companies and prices.
+	ScopeRead = "read"
+	// ScopeAnalytics allows the aggregations like top stocks.
+	ScopeAnalytics = "analytics"
+	// ScopeAdmin allows admin and ingest endpoints and implies all scopes.
+	ScopeAdmin = "admin"
+)
+
+// Scopes lists all the known scopes.
+var Scopes = []string{ScopeRead, ScopeAnalytics, ScopeAdmin}
+
+// Key is a stored API key. Only the hash of the secret is kept.
+type Key struct {
+	ID        string     `json:"id" bson:"_id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"-"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Disabled  bool       `json:"disabled"`
+	Created   time.Time  `json:"created"`
+	LastUsed  *time.Time `json:"lastUsed,omitempty"`
+	Requests  int64      `json:"requests"`
+}
+
+// NewKey returns a new key and its secret. The secret is not stored, it
+// can't be read again.
+func NewKey(name string, scopes []string, expiresAt *time.Time) (Key, string, error) {
+	if name == "" {
+		return Key{}, "", fmt.Errorf("newKey: name is required")
+	}
+
+	if len(scopes) == 0 {
+		return Key{}, "", fmt.Errorf("newKey: at least one scope is required")
+	}
+
+	for _, scope := range scopes {
+		if !ValidScope(scope) {
+			return Key{}, "", fmt.Errorf("newKey: unknown scope %q", scope)
+		}
+	}
+
+	id, err := random(12)
+	if err != nil {
+		return Key{}, "", fmt.Errorf("newKey: unable to generate id: %s", err)
+	}
+
+	k := Key{ID: id, Name: name, Scopes: scopes, ExpiresAt: expiresAt, Created: time.Now()}
+
+	secret, err := k.Rotate()
+	if err != nil {
+		return Key{}, "", err
+	}
+
+	return k, secret, nil
+}
+
+// Rotate replaces the secret of the key and returns the new one.
+func (k *Key) Rotate() (string, error) {
+	secret, err := random(32)
+	if err != nil {
+		return "", fmt.Errorf("rotate: unable to generate secret: %s", err)
+	}
+
+	k.Hash = Hash(secret)
+
+	return secret, nil
+}
+
+// Configured tells whether the key is seeded from the configuration. Seeding
+// refreshes the name, secret hash, scopes and expiry of such a key and keeps
+// its disabled flag, creation time and usage.
+func (k Key) Configured() bool {
+	return strings.HasPrefix(k.ID, configPrefix)
+}
+
+// Check tells why the key can't be used at the given time, if at all.
+func (k Key) Check(now time.Time) error {
+	if k.Disabled {
+		return fmt.Errorf("key %q is disabled", k.Name)
+	}
+
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(now) {
+		return fmt.Errorf("key %q expired at %s", k.Name, k.ExpiresAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Identity returns the identity of requests made with the key.
+func (k Key) Identity() Identity {
+	return Identity{ID: k.ID, Name: k.Name, Scopes: k.Scopes}
+}
+
+// Hash returns the hash of an API key secret as stored in a Key.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func random(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// ValidScope tells whether scope is a known scope.
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Identity is the authenticated consumer of a request.
+type Identity struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// HasScope tells whether the identity was granted scope, admin implies all
+// scopes.
+func (i Identity) HasScope(scope string) bool {
+	for _, s := range i.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+
+	return false
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx holding the identity.
+func WithIdentity(ctx context.Context, i Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, i)
+}
+
+// FromContext returns the identity held by ctx.
+func FromContext(ctx context.Context) (Identity, bool) {
+	i, ok := ctx.Value(identityKey{}).(Identity)
+	return i, ok
+}
+
+type failureKey struct{}
+
+// WithFailures returns a copy of ctx in which the authenticator reports the
+// requests denied for invalid credentials, and a function telling whether
+// the request was. A valid identity missing a scope isn't a failure.
+func WithFailures(ctx context.Context) (context.Context, func() bool) {
+	failed := new(bool)
+	return context.WithValue(ctx, failureKey{}, failed), func() bool { return *failed }
+}
+
+// fail reports the credentials of the request of ctx as invalid.
+func fail(ctx context.Context) {
+	if failed, ok := ctx.Value(failureKey{}).(*bool); ok {
+		*failed = true
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/vikashvverma/stock-backend/config"
+	"github.com/vikashvverma/stock-backend/route"
+)
+
+// Rule is the authentication rule of the requests matching its pattern.
+type Rule struct {
+	route.Pattern
+	Public bool
+	Scopes []string
+}
+
+// Rules are evaluated in order, the first rule matching a request applies.
+type Rules []Rule
+
+// NewRules parses the route rules, see route.New for the path patterns.
+func NewRules(specs []config.RouteSpec) (Rules, error) {
+	var rules Rules
+	for _, spec := range specs {
+		for _, scope := range spec.Scopes {
+			if !ValidScope(scope) {
+				return nil, fmt.Errorf("newRules: route %s: unknown scope %q", spec.Path, scope)
+			}
+		}
+
+		p, err := route.New(spec.Method, spec.Path)
+		if err != nil {
+			return nil, fmt.Errorf("newRules: route %s: %s", spec.Path, err)
+		}
+
+		rules = append(rules, Rule{Pattern: p, Public: spec.Public, Scopes: spec.Scopes})
+	}
+
+	return rules, nil
+}
+
+// Match returns the first rule matching the method and the path.
+func (rs Rules) Match(method, path string) (Rule, bool) {
+	for _, r := range rs {
+		if r.Pattern.Match(method, path) {
+			return r, true
+		}
+	}
+
+	return Rule{}, false
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+func TestRulesMatch(t *testing.T) {
+	rules, err := NewRules([]config.RouteSpec{
+		{Method: http.MethodGet, Path: "/healthcheck", Public: true},
+		{Path: "/stock/top/{from}/{to}", Scopes: []string{ScopeAnalytics}},
+		{Path: "/stock/*", Scopes: []string{ScopeRead}},
+	})
+	require.NoError(t, err, "Expected no error")
+
+	rule, ok := rules.Match(http.MethodGet, "/healthcheck")
+	require.True(t, ok, "Expected a rule")
+	assert.True(t, rule.Public)
+
+	rule, ok = rules.Match(http.MethodGet, "/stock/top/01-01-2016/01-02-2016")
+	require.True(t, ok, "Expected a rule")
+	assert.Equal(t, []string{ScopeAnalytics}, rule.Scopes)
+
+	rule, ok = rules.Match(http.MethodGet, "/stock/AAPL")
+	require.True(t, ok, "Expected a rule")
+	assert.Equal(t, []string{ScopeRead}, rule.Scopes)
+
+	_, ok = rules.Match(http.MethodGet, "/admin/jobs")
+	assert.False(t, ok, "Expected no rule")
+}
+
+func TestNewRulesFails(t *testing.T) {
+	_, err := NewRules([]config.RouteSpec{{Path: "/stock/*", Scopes: []string{"write"}}})
+	assert.EqualError(t, err, `newRules: route /stock/*: unknown scope "write"`)
+
+	_, err = NewRules([]config.RouteSpec{{Path: "/stock/[a"}})
+	assert.Contains(t, err.Error(), "newRules: route /stock/[a: invalid glob [a:")
+}
+
+func TestDefaultRulesProtectMetrics(t *testing.T) {
+	rules, err := NewRules(config.Config{}.Routes())
+	require.NoError(t, err, "Expected no error")
+
+	rule, ok := rules.Match(http.MethodGet, "/metrics")
+	require.True(t, ok, "Expected a rule")
+	assert.False(t, rule.Public)
+	assert.Equal(t, []string{ScopeAdmin}, rule.Scopes)
+
+	_, ok = rules.Match(http.MethodGet, "/version")
+	assert.False(t, ok, "Expected no rule")
+}
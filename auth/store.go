@@ -0,0 +1,429 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+// ErrKeyNotFound is returned when no key matches.
+var ErrKeyNotFound = errors.New("key not found")
+
+// KeyStore stores API keys.
+type KeyStore interface {
+	// Lookup returns the key with the given secret hash.
+	Lookup(hash string) (Key, error)
+	// Seed replaces the configured keys: it deletes the configured keys not
+	// given and creates or updates the given keys, keeping their usage and
+	// disabled flag. On error the configured keys are restored.
+	Seed([]Key) error
+	Create(Key) error
+	Get(id string) (Key, error)
+	List() ([]Key, error)
+	// Update saves the name, secret hash, scopes, expiry and disabled flag
+	// of the key.
+	Update(Key) error
+	Delete(id string) error
+	// Touch adds requests to the request count of the key and sets when it
+	// was last used.
+	Touch(id string, requests int64, lastUsed time.Time) error
+}
+
+type mongoKeyStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoKeyStore returns a KeyStore backed by collection c.
+func NewMongoKeyStore(c *mongo.Collection) KeyStore {
+	return &mongoKeyStore{Collection: c}
+}
+
+func (s *mongoKeyStore) Lookup(hash string) (Key, error) {
+	var k Key
+	err := s.Collection.FindOne(context.Background(), bson.D{{Key: "hash", Value: hash}}).Decode(&k)
+	if err == mongo.ErrNoDocuments {
+		return k, ErrKeyNotFound
+	}
+	if err != nil {
+		return k, fmt.Errorf("lookup: unable to find key: %s", err)
+	}
+
+	return k, nil
+}
+
+func (s *mongoKeyStore) Seed(keys []Key) error {
+	ctx := context.Background()
+	collection := s.Collection
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetName("hash").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("seed: unable to create index: %s", err)
+	}
+
+	previous, err := s.configured(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.replaceConfigured(ctx, keys)
+	if err == nil {
+		return nil
+	}
+
+	// the bulk write stops at the first error, put back the keys as they
+	// were so no reload leaves half of its keys.
+	if restoreErr := s.replaceConfigured(ctx, previous); restoreErr != nil {
+		return fmt.Errorf("seed: unable to save keys: %s, unable to restore the previous keys: %s", err, restoreErr)
+	}
+
+	return fmt.Errorf("seed: unable to save keys: %s", err)
+}
+
+// configured returns the configured keys.
+func (s *mongoKeyStore) configured(ctx context.Context) ([]Key, error) {
+	cur, err := s.Collection.Find(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$regex", Value: "^" + configPrefix}}}})
+	if err != nil {
+		return nil, fmt.Errorf("seed: unable to find configured keys: %s", err)
+	}
+	defer cur.Close(ctx)
+
+	var keys []Key
+	for cur.Next(ctx) {
+		var k Key
+		err = cur.Decode(&k)
+		if err != nil {
+			return nil, fmt.Errorf("seed: error decoding key: %s", err)
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// replaceConfigured deletes the configured keys not in keys, first so that
+// a key renamed in the configuration can keep its secret, then saves keys.
+// Keys already saved keep their disabled flag, creation time and usage.
+func (s *mongoKeyStore) replaceConfigured(ctx context.Context, keys []Key) error {
+	ids := bson.A{}
+	for _, k := range keys {
+		ids = append(ids, k.ID)
+	}
+
+	models := []mongo.WriteModel{mongo.NewDeleteManyModel().SetFilter(bson.D{{Key: "_id", Value: bson.D{
+		{Key: "$regex", Value: "^" + configPrefix},
+		{Key: "$nin", Value: ids},
+	}}})}
+	for _, k := range keys {
+		update := bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "name", Value: k.Name},
+				{Key: "hash", Value: k.Hash},
+				{Key: "scopes", Value: k.Scopes},
+				{Key: "expiresat", Value: k.ExpiresAt},
+			}},
+			{Key: "$setOnInsert", Value: bson.D{
+				{Key: "disabled", Value: k.Disabled},
+				{Key: "created", Value: k.Created},
+				{Key: "lastused", Value: k.LastUsed},
+				{Key: "requests", Value: k.Requests},
+			}},
+		}
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(bson.D{{Key: "_id", Value: k.ID}}).SetUpdate(update).SetUpsert(true))
+	}
+
+	_, err := s.Collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(true))
+
+	return err
+}
+
+func (s *mongoKeyStore) Create(k Key) error {
+	_, err := s.Collection.InsertOne(context.Background(), k)
+	if err != nil {
+		return fmt.Errorf("create: unable to insert key %q: %s", k.Name, err)
+	}
+
+	return nil
+}
+
+func (s *mongoKeyStore) Get(id string) (Key, error) {
+	var k Key
+	err := s.Collection.FindOne(context.Background(), bson.D{{Key: "_id", Value: id}}).Decode(&k)
+	if err == mongo.ErrNoDocuments {
+		return k, ErrKeyNotFound
+	}
+	if err != nil {
+		return k, fmt.Errorf("get: unable to find key: %s", err)
+	}
+
+	return k, nil
+}
+
+func (s *mongoKeyStore) List() ([]Key, error) {
+	ctx := context.Background()
+	cur, err := s.Collection.Find(ctx, bson.D{}, options.Find().SetSort(bson.D{{Key: "created", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("list: unable to find keys: %s", err)
+	}
+	defer cur.Close(ctx)
+
+	keys := []Key{}
+	for cur.Next(ctx) {
+		var k Key
+		err = cur.Decode(&k)
+		if err != nil {
+			return nil, fmt.Errorf("list: error decoding key: %s", err)
+		}
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+func (s *mongoKeyStore) Update(k Key) error {
+	res, err := s.Collection.UpdateOne(context.Background(), bson.D{{Key: "_id", Value: k.ID}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "name", Value: k.Name},
+			{Key: "hash", Value: k.Hash},
+			{Key: "scopes", Value: k.Scopes},
+			{Key: "expiresat", Value: k.ExpiresAt},
+			{Key: "disabled", Value: k.Disabled},
+		}}})
+	if err != nil {
+		return fmt.Errorf("update: unable to update key %q: %s", k.Name, err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
+
+func (s *mongoKeyStore) Delete(id string) error {
+	res, err := s.Collection.DeleteOne(context.Background(), bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return fmt.Errorf("delete: unable to delete key %s: %s", id, err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
+
+func (s *mongoKeyStore) Touch(id string, requests int64, lastUsed time.Time) error {
+	_, err := s.Collection.UpdateOne(context.Background(), bson.D{{Key: "_id", Value: id}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "requests", Value: requests}}},
+			{Key: "$max", Value: bson.D{{Key: "lastused", Value: lastUsed}}},
+		})
+	if err != nil {
+		return fmt.Errorf("touch: unable to update usage of key %s: %s", id, err)
+	}
+
+	return nil
+}
+
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]Key
+}
+
+// NewMemoryKeyStore returns a KeyStore keeping keys in memory.
+func NewMemoryKeyStore() KeyStore {
+	return &memoryKeyStore{keys: map[string]Key{}}
+}
+
+func (s *memoryKeyStore) Lookup(hash string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+
+	return Key{}, ErrKeyNotFound
+}
+
+func (s *memoryKeyStore) Seed(keys []Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seeded := map[string]bool{}
+	for _, k := range keys {
+		seeded[k.ID] = true
+	}
+	for id, k := range s.keys {
+		if k.Configured() && !seeded[id] {
+			delete(s.keys, id)
+		}
+	}
+
+	for _, k := range keys {
+		if old, ok := s.keys[k.ID]; ok {
+			k.Disabled = old.Disabled
+			k.Created = old.Created
+			k.LastUsed = old.LastUsed
+			k.Requests = old.Requests
+		}
+		s.keys[k.ID] = k
+	}
+
+	return nil
+}
+
+func (s *memoryKeyStore) Create(k Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, old := range s.keys {
+		if old.ID == k.ID || old.Hash == k.Hash {
+			return fmt.Errorf("create: duplicate key %q", k.Name)
+		}
+	}
+	s.keys[k.ID] = k
+
+	return nil
+}
+
+func (s *memoryKeyStore) Get(id string) (Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return k, ErrKeyNotFound
+	}
+
+	return k, nil
+}
+
+func (s *memoryKeyStore) List() ([]Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := []Key{}
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(a, b int) bool { return keys[a].Created.Before(keys[b].Created) })
+
+	return keys, nil
+}
+
+func (s *memoryKeyStore) Update(k Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.keys[k.ID]
+	if !ok {
+		return ErrKeyNotFound
+	}
+
+	old.Name = k.Name
+	old.Hash = k.Hash
+	old.Scopes = k.Scopes
+	old.ExpiresAt = k.ExpiresAt
+	old.Disabled = k.Disabled
+	s.keys[k.ID] = old
+
+	return nil
+}
+
+func (s *memoryKeyStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[id]; !ok {
+		return ErrKeyNotFound
+	}
+	delete(s.keys, id)
+
+	return nil
+}
+
+func (s *memoryKeyStore) Touch(id string, requests int64, lastUsed time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return nil
+	}
+
+	k.Requests += requests
+	if k.LastUsed == nil || lastUsed.After(*k.LastUsed) {
+		k.LastUsed = &lastUsed
+	}
+	s.keys[id] = k
+
+	return nil
+}
+
+// ValidateKeys checks the keys of the configuration: each one needs a unique
+// name and known scopes.
+func ValidateKeys(specs []config.KeySpec) error {
+	names := map[string]bool{}
+	for _, spec := range specs {
+		if names[spec.Name] {
+			return fmt.Errorf("validateKeys: duplicate key %q", spec.Name)
+		}
+		names[spec.Name] = true
+
+		if len(spec.Scopes) == 0 {
+			return fmt.Errorf("validateKeys: key %s: at least one scope is required", spec.Name)
+		}
+		for _, scope := range spec.Scopes {
+			if !ValidScope(scope) {
+				return fmt.Errorf("validateKeys: key %s: unknown scope %q", spec.Name, scope)
+			}
+		}
+	}
+
+	return nil
+}
+
+// SeedKeys creates or updates the keys of the configuration in store and
+// deletes the configured keys no longer in it. A configured key keeps its
+// id, derived from its name, its usage and its disabled flag across
+// restarts and reloads. The keys are validated first and swapped at once,
+// on error the store keeps the previous ones.
+func SeedKeys(store KeyStore, specs []config.KeySpec) error {
+	err := ValidateKeys(specs)
+	if err != nil {
+		return err
+	}
+
+	var keys []Key
+	for _, spec := range specs {
+		k := Key{
+			ID:      configPrefix + spec.Name,
+			Name:    spec.Name,
+			Hash:    Hash(spec.Key),
+			Scopes:  spec.Scopes,
+			Created: time.Now(),
+		}
+		if !spec.ExpiresAt.IsZero() {
+			expiresAt := spec.ExpiresAt
+			k.ExpiresAt = &expiresAt
+		}
+
+		keys = append(keys, k)
+	}
+
+	return store.Seed(keys)
+}
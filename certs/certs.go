@@ -0,0 +1,169 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+// reloadInterval is how often the certificate files are checked for
+// changes.
+const reloadInterval = 10 * time.Second
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"":    tls.VersionTLS12,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Reloader serves a certificate and loads it again when its files change.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	logger   *logrus.Logger
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modified time.Time
+
+	stop chan struct{}
+}
+
+// NewReloader loads the certificate of the given files and checks them for
+// changes every reloadInterval until stopped.
+func NewReloader(certFile, keyFile string, l *logrus.Logger) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, logger: l, stop: make(chan struct{})}
+	if err := r.load(); err != nil {
+		return nil, fmt.Errorf("newReloader: %s", err)
+	}
+
+	go r.watch()
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, for tls.Config.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cert, nil
+}
+
+// Stop stops checking the files for changes.
+func (r *Reloader) Stop() {
+	close(r.stop)
+}
+
+func (r *Reloader) watch() {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.reload()
+		}
+	}
+}
+
+// reload loads the certificate if a file changed since the last load. A
+// certificate that fails to load, e.g. because only one of the files was
+// replaced yet, is tried again on the next check.
+func (r *Reloader) reload() {
+	modified, err := r.lastModified()
+	if err != nil {
+		r.logger.WithError(err).Errorf("Reloader: unable to check certificate files")
+		return
+	}
+
+	r.mu.RLock()
+	changed := modified.After(r.modified)
+	r.mu.RUnlock()
+	if !changed {
+		return
+	}
+
+	if err := r.load(); err != nil {
+		r.logger.WithError(err).Errorf("Reloader: unable to reload certificate, keeping the previous one")
+		return
+	}
+	r.logger.Infof("Reloader: reloaded certificate %s", r.certFile)
+}
+
+func (r *Reloader) load() error {
+	modified, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load: %s", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.modified = modified
+
+	return nil
+}
+
+// lastModified returns the latest modification time of the files.
+func (r *Reloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return latest, fmt.Errorf("lastModified: %s", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+// Config returns the server TLS configuration of spec, serving the
+// certificate of r.
+func Config(spec config.TLSSpec, r *Reloader) (*tls.Config, error) {
+	c := &tls.Config{
+		MinVersion:     tlsVersions[spec.MinVersion],
+		GetCertificate: r.GetCertificate,
+	}
+
+	if spec.ClientCAFile == "" {
+		return c, nil
+	}
+
+	pem, err := ioutil.ReadFile(spec.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("config: unable to read client CA file: %s", err)
+	}
+
+	c.ClientCAs = x509.NewCertPool()
+	if !c.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("config: no certificate in client CA file %s", spec.ClientCAFile)
+	}
+
+	c.ClientAuth = tls.RequireAndVerifyClientCert
+	if spec.ClientAuth == config.ClientAuthOptional {
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return c, nil
+}
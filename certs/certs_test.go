@@ -0,0 +1,129 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+// writeCert writes a self-signed certificate for cn and its key to dir.
+func writeCert(t *testing.T, dir, cn string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "Expected no error")
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err, "Expected no error")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err, "Expected no error")
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return certFile, keyFile
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "certs")
+	require.NoError(t, err, "Expected no error")
+
+	return dir
+}
+
+func commonName(t *testing.T, r *Reloader) string {
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err, "Expected no error")
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err, "Expected no error")
+
+	return leaf.Subject.CommonName
+}
+
+func TestReloader(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	logger, _ := test.NewNullLogger()
+
+	certFile, keyFile := writeCert(t, dir, "old")
+	r, err := NewReloader(certFile, keyFile, logger)
+	require.NoError(t, err, "Expected no error")
+	defer r.Stop()
+
+	assert.Equal(t, "old", commonName(t, r))
+
+	writeCert(t, dir, "new")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	r.reload()
+
+	assert.Equal(t, "new", commonName(t, r))
+
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte("garbage"), 0600))
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(keyFile, later, later))
+	r.reload()
+
+	assert.Equal(t, "new", commonName(t, r), "Expected the previous certificate to be kept")
+}
+
+func TestNewReloaderFailsWithoutFiles(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+
+	_, err := NewReloader("missing.pem", "missing-key.pem", logger)
+
+	assert.Error(t, err)
+}
+
+func TestConfig(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	logger, _ := test.NewNullLogger()
+
+	certFile, keyFile := writeCert(t, dir, "ca")
+	r, err := NewReloader(certFile, keyFile, logger)
+	require.NoError(t, err, "Expected no error")
+	defer r.Stop()
+
+	c, err := Config(config.TLSSpec{CertFile: certFile, KeyFile: keyFile}, r)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, uint16(tls.VersionTLS12), c.MinVersion)
+	assert.Equal(t, tls.NoClientCert, c.ClientAuth)
+	assert.Nil(t, c.ClientCAs)
+
+	c, err = Config(config.TLSSpec{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3", ClientCAFile: certFile}, r)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, uint16(tls.VersionTLS13), c.MinVersion)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, c.ClientAuth)
+	assert.NotNil(t, c.ClientCAs)
+
+	c, err = Config(config.TLSSpec{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, ClientAuth: config.ClientAuthOptional}, r)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, tls.VerifyClientCertIfGiven, c.ClientAuth)
+
+	_, err = Config(config.TLSSpec{CertFile: certFile, KeyFile: keyFile, ClientCAFile: keyFile}, r)
+	assert.EqualError(t, err, "config: no certificate in client CA file "+keyFile)
+}
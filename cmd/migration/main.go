@@ -2,158 +2,128 @@ package main
 
 import (
 	"context"
-	"encoding/csv"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
-	"strconv"
-	"strings"
-	"time"
+	"path/filepath"
 
 	"github.com/sirupsen/logrus"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/vikashvverma/stock-backend/config"
-	"github.com/vikashvverma/stock-backend/constants"
 	"github.com/vikashvverma/stock-backend/factory"
+	"github.com/vikashvverma/stock-backend/importer"
+	"github.com/vikashvverma/stock-backend/ingest"
 	"github.com/vikashvverma/stock-backend/stock"
 )
 
 func main() {
-	var c *config.Config
-	var err error
-
-	useFlags := !(len(os.Args) > 2 && os.Args[1] == "-config")
-	if useFlags {
-		c, err = config.FromFlags(os.Args)
-		if err != nil {
-			log.Fatalln(err)
-		}
-	} else {
-		c, err = config.FromFile(os.Args[2])
-		if err != nil {
+	c, err := config.Load(os.Args, os.Environ())
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if c.PrintConfig() {
+		if err := c.Print(os.Stdout); err != nil {
 			log.Fatalln(err)
 		}
+		return
 	}
 
 	l := logrus.New()
 	f := factory.NewFactory(c, l)
 
-	client := f.Client()
-
-	stocks, err := parseStock(c.Stock())
+	err = f.Indexer().Ensure()
 	if err != nil {
-		l.Fatalf("unable to parse stock csv file: %s", err)
+		l.Fatalf("unable to ensure indexes: %s", err)
 	}
 
-	collection := client.Database(constants.Database).Collection(constants.Collection)
+	loader := f.Loader()
 
-	ctx := context.Background()
-	csvFile, err := os.Open(c.Data())
+	if c.Convert() {
+		n, err := loader.ConvertLegacy()
+		if err != nil {
+			l.Fatalf("unable to convert legacy stock collection: %s", err)
+		}
+
+		fmt.Printf("Converted %d price points\n", n)
+		return
+	}
+
+	companyFile, err := os.Open(c.Stock())
 	if err != nil {
-		log.Fatalf("error opening stock file: %s", err)
+		l.Fatalf("error opening stock file: %s", err)
 	}
+	defer companyFile.Close()
 
-	insert(stocks, csvFile, collection, ctx)
+	res, err := ingest.Companies(context.Background(), loader, companyFile)
+	if err != nil {
+		l.Fatalf("unable to import stock csv file: %s", err)
+	}
+	fmt.Printf("Saved: %d companies\n", res.Saved)
+
+	err = insert(c.Data(), c.Format(), loader)
+	if err != nil {
+		l.Fatalf("unable to import price points: %s", err)
+	}
 
 }
 
-func insert(stocks map[string]stock.Stock, csvFile *os.File, collection *mongo.Collection, ctx context.Context) {
-	// Read File into a Variable
-	lines, err := csv.NewReader(csvFile).ReadAll()
+func insert(path, format string, loader stock.Loader) error {
+	files, err := dataFiles(path)
 	if err != nil {
-		logrus.Fatalf("Error reading file: %s", csvFile.Name())
+		return err
 	}
 
-	// Loop through lines & turn into object
-	for i, line := range lines {
-		if i == 0 { // skip header
-			continue
-		}
-		date, _ := time.Parse(
-			fmt.Sprintf(
-				"%s-%s-%s",
-				constants.StdLongYear,
-				constants.StdZeroMonth,
-				constants.StdZeroDay,
-			),
-			strings.Split(line[0], " ")[0])
-
-		d := stock.PricePoint{
-			Date:   date,
-			Symbol: line[1],
-			Open:   parseToFloat(line[2]),
-			Close:  parseToFloat(line[3]),
-			Low:    parseToFloat(line[4]),
-			High:   parseToFloat(line[5]),
-			Volume: parseToFloat(line[6]),
+	for _, file := range files {
+		im, err := importer.New(format, importer.SymbolFromPath(file))
+		if err != nil {
+			return err
 		}
 
-		if _, ok := stocks[line[1]]; !ok {
-			stocks[line[1]] = stock.Stock{Symbol: line[1]}
+		r, err := importer.Open(file)
+		if err != nil {
+			return err
 		}
 
-		st := stocks[line[1]]
-		st.PricePoints = append(st.PricePoints, d)
-
-		stocks[line[1]] = st
-	}
-	for _, v := range stocks {
-		_, err := collection.InsertOne(ctx, v, &options.InsertOneOptions{})
+		res, err := ingest.Prices(context.Background(), loader, im, r, func(res ingest.Result) {
+			fmt.Printf("Saved: %d price points\n", res.Saved)
+		})
+		r.Close()
 		if err != nil {
-			fmt.Printf("insert failed for the symbol: %s", v.Symbol)
-			continue
+			return fmt.Errorf("%s: %s", file, err)
 		}
 
-		fmt.Printf("Inserted: %s\n", v.Symbol)
-
+		for _, rowErr := range res.Errors {
+			fmt.Printf("Skipped %s: %s\n", file, rowErr)
+		}
+		fmt.Printf("Imported %s: %d rows, %d skipped, %d new symbols\n", file, res.Rows, res.Skipped, res.Symbols)
 	}
 
+	return nil
 }
 
-func parseToFloat(str string) float64 {
-	f, err := strconv.ParseFloat(str, 64)
+// dataFiles returns path itself or, when path is a directory, the files in it.
+func dataFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
 	if err != nil {
-		logrus.Fatalf("parseToFloat: unable to parse %v to float: %s", str, err)
+		return nil, fmt.Errorf("error opening price file: %s", err)
 	}
-	return f
-}
 
-func parseStock(filePath string) (map[string]stock.Stock, error) {
-	csvFile, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("parseStock: error reading file: %s", err)
+	if !info.IsDir() {
+		return []string{path}, nil
 	}
 
-	r := csv.NewReader(csvFile)
-	lines, err := r.ReadAll()
+	entries, err := ioutil.ReadDir(path)
 	if err != nil {
-		return nil, fmt.Errorf("parseStock: error reading all lines: %v", err)
+		return nil, fmt.Errorf("error reading price directory: %s", err)
 	}
 
-	stocks := make(map[string]stock.Stock, 0)
-
-	for i, line := range lines {
-		if i == 0 { //skip header
-			continue
-		}
-
-		marketCap, err := strconv.ParseFloat(line[2], 64)
-		if err != nil {
-			return nil, fmt.Errorf("parseStock: unable to parse market cap at line %d: %s", i+1, err)
-		}
-
-		s := stock.Stock{
-			Symbol:      line[0],
-			Name:        line[1],
-			MarketCap:   marketCap,
-			Sector:      line[3],
-			Industry:    line[4],
-			PricePoints: []stock.PricePoint{},
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			files = append(files, filepath.Join(path, e.Name()))
 		}
-		stocks[line[0]] = s
 	}
 
-	return stocks, nil
+	return files, nil
 }
@@ -1,47 +1,248 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	stdlog "log"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/codegangsta/negroni"
 	"github.com/sirupsen/logrus"
 
+	"github.com/vikashvverma/stock-backend/audit"
 	"github.com/vikashvverma/stock-backend/auth"
+	"github.com/vikashvverma/stock-backend/certs"
 	"github.com/vikashvverma/stock-backend/config"
 	"github.com/vikashvverma/stock-backend/factory"
 	"github.com/vikashvverma/stock-backend/log"
+	"github.com/vikashvverma/stock-backend/metrics"
+	"github.com/vikashvverma/stock-backend/ratelimit"
+	"github.com/vikashvverma/stock-backend/recovery"
+	"github.com/vikashvverma/stock-backend/reload"
+	"github.com/vikashvverma/stock-backend/requestid"
 	"github.com/vikashvverma/stock-backend/router"
+	"github.com/vikashvverma/stock-backend/timeout"
+	"github.com/vikashvverma/stock-backend/tracing"
 )
 
 var (
-	version      string
-	publicRoutes = []string{"/healthcheck", "/version"}
+	version string
 )
 
 func main() {
-	var c *config.Config
-	var err error
-
-	useFlags := !(len(os.Args) > 2 && os.Args[1] == "-config")
-	if useFlags {
-		c, err = config.FromFlags(os.Args)
-		if err != nil {
-			logrus.Fatalln(err)
-		}
-	} else {
-		c, err = config.FromFile(os.Args[2])
-		if err != nil {
+	c, err := config.Load(os.Args, os.Environ())
+	if err != nil {
+		logrus.Fatalln(err)
+	}
+	if c.PrintConfig() {
+		if err := c.Print(os.Stdout); err != nil {
 			logrus.Fatalln(err)
 		}
+		return
+	}
+	if err := auth.ValidateKeys(c.Keys()); err != nil {
+		logrus.Fatalln(err)
 	}
+	c.OpenLogs()
+
 	l := logrus.New()
+	l.AddHook(log.ContextHook{})
 	f := factory.NewFactory(c, l)
+	if err := f.Indexer().Ensure(); err != nil {
+		l.WithError(err).Warnf("unable to ensure indexes")
+	}
+	if err := auth.SeedKeys(f.KeyStore(), c.Keys()); err != nil {
+		l.WithError(err).Fatalf("unable to seed API keys")
+	}
+	var tokens auth.Verifier
+	if c.JWT().Enabled() {
+		tokens, err = auth.NewVerifier(c.JWT())
+		if err != nil {
+			l.WithError(err).Fatalf("unable to load JWT verifier")
+		}
+	}
+	clientCerts, err := auth.NewCertificates(c.TLS().ClientCerts)
+	if err != nil {
+		l.WithError(err).Fatalf("invalid client certificates")
+	}
+	rules, err := auth.NewRules(c.Routes())
+	if err != nil {
+		l.WithError(err).Fatalf("invalid route rules")
+	}
+	limiter, err := ratelimit.New(l, c.RateLimits())
+	if err != nil {
+		l.WithError(err).Fatalf("invalid rate limits")
+	}
+	timeouts, err := timeout.New(c.Timeouts())
+	if err != nil {
+		l.WithError(err).Fatalf("invalid timeouts")
+	}
 	muxRouter := router.Router(f, c, l)
+	access := log.AccessLogger(c, l)
+
+	// the router registers the job kinds, so the runner starts after it.
+	f.Runner().Start()
+
+	panics := recovery.New(l)
+	f.Metrics().CounterFunc("http_panics_total", "Number of panics recovered while serving HTTP requests.", func() float64 {
+		return float64(panics.Panics())
+	})
+
+	authenticator := auth.New(l, f.KeyStore(), tokens, clientCerts, rules)
+	auditor := audit.New(l, f.AuditStore())
+	authenticator.OnDenied(auditor.Denied)
 
 	n := negroni.New()
-	n.Use(log.New(l))
-	n.Use(auth.New(l, c.APIKey, publicRoutes))
+	n.Use(requestid.New())
+	n.Use(tracing.NewMiddleware(f.Tracer(), muxRouter))
+	n.Use(metrics.New(f.Metrics(), muxRouter))
+	n.Use(panics)
+	n.Use(log.New(access))
+	n.Use(ratelimit.NewFailureLimiter(l, c.AuthFailureLimit()))
+	n.Use(authenticator)
+	n.Use(auditor)
+	n.Use(limiter)
+	n.Use(timeouts)
 	n.UseHandler(muxRouter)
-	n.Run(fmt.Sprintf(":%d", c.AppPort()))
+
+	s := c.Server()
+	var serving sync.WaitGroup
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", c.AppPort()),
+		Handler:           track(n, &serving),
+		ReadTimeout:       s.ReadTimeout,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		MaxHeaderBytes:    s.MaxHeaderBytes,
+		ErrorLog:          stdlog.New(l.WriterLevel(logrus.ErrorLevel), "", 0),
+	}
+
+	var reloader *certs.Reloader
+	if c.TLS().Enabled() {
+		reloader, err = certs.NewReloader(c.TLS().CertFile, c.TLS().KeyFile, l)
+		if err != nil {
+			l.WithError(err).Fatalf("unable to load certificate")
+		}
+		srv.TLSConfig, err = certs.Config(c.TLS(), reloader)
+		if err != nil {
+			l.WithError(err).Fatalf("invalid tls configuration")
+		}
+	}
+
+	configs := reload.New(c, func() (*config.Config, error) {
+		return config.Load(os.Args, os.Environ())
+	}, l)
+	configs.Validate(func(next *config.Config) error {
+		return ratelimit.Validate(next.RateLimits())
+	})
+	configs.Validate(func(next *config.Config) error {
+		return auth.ValidateKeys(next.Keys())
+	})
+	configs.OnReload(func(next *config.Config) error {
+		l.SetLevel(logrus.Level(next.LogLevel()))
+		access.SetLevel(logrus.Level(next.LogLevel()))
+		return nil
+	})
+	configs.OnReload(func(next *config.Config) error {
+		return auth.SeedKeys(f.KeyStore(), next.Keys())
+	})
+	configs.OnReload(func(next *config.Config) error {
+		return limiter.Update(next.RateLimits())
+	})
+	if c.SourceFile() != "" {
+		configs.Watch(c.SourceFile())
+	}
+	go func() {
+		hangups := make(chan os.Signal, 1)
+		signal.Notify(hangups, syscall.SIGHUP)
+		for range hangups {
+			l.Infof("received SIGHUP, reloading config")
+			if err := configs.Reload(); err != nil {
+				l.WithError(err).Errorf("unable to reload config")
+			}
+		}
+	}()
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	errs := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			// the certificate comes from the reloader of TLSConfig.
+			errs <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		errs <- srv.ListenAndServe()
+	}()
+	f.Readiness().SetReady(true)
+	l.Infof("listening on %s, tls: %t", srv.Addr, srv.TLSConfig != nil)
+
+	select {
+	case err := <-errs:
+		l.WithError(err).Fatalf("unable to serve")
+	case sig := <-signals:
+		l.Infof("received %s, shutting down", sig)
+	}
+
+	shutdown(srv, &serving, s, f, l, signals)
+	configs.Stop()
+	if reloader != nil {
+		reloader.Stop()
+	}
+	authenticator.Close()
+	auditor.Close()
+	if err := f.Close(); err != nil {
+		l.WithError(err).Errorf("unable to close")
+	}
+
+	l.Infof("stopped")
+	if file, ok := l.Out.(*os.File); ok {
+		file.Sync()
+	}
+	c.Close()
+}
+
+// track counts the requests being served: closing the server closes their
+// connections but doesn't wait for their handlers to return.
+func track(h http.Handler, serving *sync.WaitGroup) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serving.Add(1)
+		defer serving.Done()
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// shutdown stops taking requests once load balancers had the drain delay to
+// notice the service isn't ready, waits for the requests in flight and
+// stops the jobs. Another signal exits right away.
+func shutdown(srv *http.Server, serving *sync.WaitGroup, s config.Server, f factory.Factory, l *logrus.Logger, signals <-chan os.Signal) {
+	go func() {
+		sig := <-signals
+		l.Warnf("received %s, exiting", sig)
+		os.Exit(1)
+	}()
+
+	f.Readiness().SetReady(false)
+	time.Sleep(s.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		l.WithError(err).Warnf("requests still in flight after %s, closing their connections", s.ShutdownTimeout)
+		srv.Close()
+	}
+	// the auditor, authenticator and database are closed next, so the
+	// handlers of the closed connections must have returned.
+	serving.Wait()
+
+	f.Runner().Stop()
 }
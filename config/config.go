@@ -9,47 +9,346 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/vikashvverma/stock-backend/constants"
+	"github.com/vikashvverma/stock-backend/rotate"
 )
 
 // Config holds the application configuration
 type Config struct {
 	appPort int
+	server  Server
+	tls     TLSSpec
 
 	dbUsername   string
 	dbPassword   string
 	dbServer     string
 	dbPORT       int
 	dbConnection string
+	database     string
+	collections  Collections
+
+	APIKey  string
+	apiKeys []KeySpec
+	jwt     JWTSpec
+	routes  []RouteSpec
+
+	rateLimits []LimitSpec
+	authLimit  AuthFailureLimitSpec
+	timeouts   []TimeoutSpec
+
+	logPath       string
+	logFile       io.Writer
+	logLevel      int
+	logFormat     string
+	logRotation   rotate.Options
+	accessLogPath string
+	accessLogFile io.Writer
+
+	stock   string
+	data    string
+	format  string
+	convert bool
+
+	uploadPath string
+	jobWorkers int
+
+	auditSink      string
+	auditFile      string
+	auditRetention time.Duration
+
+	tracing TracingSpec
+
+	// source is the args read by Load, for Print.
+	source      *args
+	sourceFile  string
+	printConfig bool
+}
+
+// defaultAuditRetention is how long the audit collection keeps the records
+// by default, 90 days.
+const defaultAuditRetention = 90 * 24 * time.Hour
+
+// Audit record sinks.
+const (
+	AuditMongo = "mongo"
+	AuditFile  = "file"
+)
+
+// Server holds the settings of the HTTP server.
+type Server struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// DrainDelay is how long the server keeps taking requests once not
+	// ready, for load balancers to notice.
+	DrainDelay time.Duration
+	// ShutdownTimeout is how long the requests in flight have to finish.
+	ShutdownTimeout time.Duration
+}
+
+// ServerSpec configures the HTTP server, durations are like "30s" and the
+// defaults apply to the empty ones.
+type ServerSpec struct {
+	ReadTimeout       string `json:"readTimeout"`
+	ReadHeaderTimeout string `json:"readHeaderTimeout"`
+	WriteTimeout      string `json:"writeTimeout"`
+	IdleTimeout       string `json:"idleTimeout"`
+	MaxHeaderBytes    int    `json:"maxHeaderBytes"`
+	DrainDelay        string `json:"drainDelay"`
+	ShutdownTimeout   string `json:"shutdownTimeout"`
+}
+
+// defaultServer are the server settings used when not configured. The
+// write timeout leaves time to the slowest route timeout.
+var defaultServer = Server{
+	ReadTimeout:       2 * time.Minute,
+	ReadHeaderTimeout: 10 * time.Second,
+	WriteTimeout:      2 * time.Minute,
+	IdleTimeout:       2 * time.Minute,
+	MaxHeaderBytes:    1 << 20,
+	DrainDelay:        5 * time.Second,
+	ShutdownTimeout:   30 * time.Second,
+}
+
+// Client certificate policies.
+const (
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// TLSSpec configures HTTPS, served when a certificate and key are set.
+type TLSSpec struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// MinVersion is 1.0, 1.1, 1.2 (default) or 1.3.
+	MinVersion string `json:"minVersion"`
+	// ClientCAFile is a PEM bundle of the CAs verifying client certificates.
+	ClientCAFile string `json:"clientCAFile"`
+	// ClientAuth is optional or require (default) when a client CA is set.
+	ClientAuth  string           `json:"clientAuth"`
+	ClientCerts []ClientCertSpec `json:"clientCerts"`
+}
+
+// Enabled tells whether HTTPS is served.
+func (t TLSSpec) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// ClientCertSpec maps the verified client certificates with a subject to
+// an API identity.
+type ClientCertSpec struct {
+	// Subject is the common name or the full distinguished name of the
+	// certificate subject, like "CN=web,O=Example".
+	Subject string   `json:"subject"`
+	Name    string   `json:"name"`
+	Scopes  []string `json:"scopes"`
+}
+
+// tlsVersions are the accepted TLS minimum versions.
+var tlsVersions = map[string]bool{"": true, "1.0": true, "1.1": true, "1.2": true, "1.3": true}
+
+// Log formats.
+const (
+	LogText = "text"
+	LogJSON = "json"
+)
+
+// LogRotationSpec configures the rotation of the log files.
+type LogRotationSpec struct {
+	// MaxSize is the size in megabytes rotating a file, unlimited when 0.
+	MaxSize int `json:"maxSize"`
+	// Interval rotates the files when a period starts, like 24h for every
+	// UTC day. No rotation by time when empty.
+	Interval string `json:"interval"`
+	// MaxBackups is the number of rotated files kept, all when 0.
+	MaxBackups int `json:"maxBackups"`
+	// MaxAge is how long rotated files are kept, like 720h, forever when
+	// empty.
+	MaxAge string `json:"maxAge"`
+	// Compress gzips the rotated files.
+	Compress bool `json:"compress"`
+}
+
+// Tracing span exporters.
+const (
+	TracingStdout = "stdout"
+	TracingFile   = "file"
+	TracingOTLP   = "otlp"
+)
+
+// TracingSpec configures the export of tracing spans, tracing is disabled
+// without an exporter.
+type TracingSpec struct {
+	// Exporter is stdout or file, writing spans as JSON lines, or otlp,
+	// posting them to an OTLP/HTTP JSON endpoint.
+	Exporter string `json:"exporter"`
+	File     string `json:"file"`
+	// Endpoint is the URL of the OTLP traces endpoint, like
+	// http://collector:4318/v1/traces.
+	Endpoint    string `json:"endpoint"`
+	ServiceName string `json:"serviceName"`
+	// SampleRatio is the ratio of the new traces recorded, all of them
+	// when 0. Traces started by clients follow their sampled flag.
+	SampleRatio float64 `json:"sampleRatio"`
+}
 
-	APIKey string
+// Enabled tells whether spans are exported.
+func (t TracingSpec) Enabled() bool {
+	return t.Exporter != ""
+}
+
+// KeySpec is an API key seeded from the configuration.
+type KeySpec struct {
+	Name      string    `json:"name"`
+	Key       string    `json:"key"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// JWTSpec configures the bearer tokens accepted besides API keys. Tokens
+// are only accepted when a secret or a JWKS file is set.
+type JWTSpec struct {
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+	// Secret verifies HS256 tokens.
+	Secret string `json:"secret"`
+	// JWKSFile is a JSON Web Key Set file with the RSA keys verifying
+	// RS256 tokens.
+	JWKSFile string `json:"jwksFile"`
+}
+
+// Enabled tells whether bearer tokens are accepted.
+func (j JWTSpec) Enabled() bool {
+	return j.Secret != "" || j.JWKSFile != ""
+}
 
-	logPath  string
-	logFile  io.Writer
-	logLevel int
+// RouteSpec is an authentication rule for the requests matching a method
+// and a path pattern, see auth.Rules.
+type RouteSpec struct {
+	// Method of the request, any method when empty.
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Public routes are served without authentication.
+	Public bool `json:"public"`
+	// Scopes are all required to be served.
+	Scopes []string `json:"scopes"`
+}
+
+// defaultRoutes follow the configured routes, the first matching rule
+// applies.
+var defaultRoutes = []RouteSpec{
+	{Method: "GET", Path: "/healthcheck", Public: true},
+	{Method: "GET", Path: "/readiness", Public: true},
+	{Method: "GET", Path: "/metrics", Scopes: []string{"admin"}},
+	{Path: "/stock/top/{from}/{to}", Scopes: []string{"analytics"}},
+	{Path: "/stock/*", Scopes: []string{"read"}},
+	{Path: "/admin/*", Scopes: []string{"admin"}},
+}
+
+// LimitSpec is the rate limit of the requests matching a method and a path
+// pattern, counted per API key, or per IP for anonymous requests.
+type LimitSpec struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Rate is the number of requests per second, unlimited when 0.
+	Rate float64 `json:"rate"`
+	// Burst is the number of requests allowed at once.
+	Burst int `json:"burst"`
+	// DailyQuota is the number of requests allowed per UTC day, unlimited
+	// when 0.
+	DailyQuota int64 `json:"dailyQuota"`
+}
+
+// defaultRateLimits follow the configured limits, the first matching limit
+// applies.
+var defaultRateLimits = []LimitSpec{
+	{Path: "/stock/top/*", Rate: 0.5, Burst: 5, DailyQuota: 1000},
+	{Path: "/*", Rate: 20, Burst: 40},
+}
 
-	stock string
-	data  string
+// AuthFailureLimitSpec limits the failed authentications per client IP.
+// Once an IP used its burst of failures its requests are rejected before
+// their API key is looked up, until the failures are refilled at rate.
+type AuthFailureLimitSpec struct {
+	// Rate is the number of failures per second, 0.1 by default.
+	Rate float64 `json:"rate"`
+	// Burst is the number of failures allowed at once, 10 by default.
+	Burst    int  `json:"burst"`
+	Disabled bool `json:"disabled"`
+}
+
+// TimeoutSpec is the deadline of the requests matching a method and a path
+// pattern. The database queries of a request are canceled at the deadline.
+type TimeoutSpec struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Timeout is a duration like "10s", "0" for no deadline.
+	Timeout string `json:"timeout"`
+}
+
+// Duration returns the parsed timeout, 0 if it's not valid.
+func (t TimeoutSpec) Duration() time.Duration {
+	d, _ := time.ParseDuration(t.Timeout)
+	return d
+}
+
+// defaultTimeouts follow the configured timeouts, the first matching
+// timeout applies.
+var defaultTimeouts = []TimeoutSpec{
+	{Path: "/stock/top/*", Timeout: "30s"},
+	{Path: "/stock/*", Timeout: "10s"},
 }
 
 type args struct {
-	AppPort string `json:"appPort"`
+	AppPort string     `json:"appPort"`
+	Server  ServerSpec `json:"server"`
+	TLS     TLSSpec    `json:"tls"`
+
+	DBUsername string    `json:"dbUsername"`
+	DBPassword string    `json:"dbPassword"`
+	DBServer   string    `json:"dbServer"`
+	DBPort     string    `json:"dbPort"`
+	Mongo      MongoSpec `json:"mongo"`
 
-	DBUsername string `json:"dbUsername"`
-	DBPassword string `json:"dbPassword"`
-	DBServer   string `json:"dbServer"`
-	DBPort     string `json:"dbPort"`
+	Database    string      `json:"database"`
+	Collections Collections `json:"collections"`
 
-	APIKey string `json:"apiKey"`
+	APIKey  string    `json:"apiKey"`
+	APIKeys []KeySpec `json:"apiKeys"`
+	JWT     JWTSpec   `json:"jwt"`
+
+	Routes     []RouteSpec   `json:"routes"`
+	RateLimits []LimitSpec   `json:"rateLimits"`
+	Timeouts   []TimeoutSpec `json:"timeouts"`
+	// AuthFailureLimit limits the failed authentications per client IP.
+	AuthFailureLimit AuthFailureLimitSpec `json:"authFailureLimit"`
 
 	LogPath  string `json:"logPath"`
 	LogLevel string `json:"logLevel"`
+	// LogFormat is text (default) or json.
+	LogFormat     string          `json:"logFormat"`
+	AccessLogPath string          `json:"accessLogPath"`
+	LogRotation   LogRotationSpec `json:"logRotation"`
+
+	Stock   string `json:"stock"`
+	Data    string `json:"data"`
+	Format  string `json:"format"`
+	Convert bool   `json:"convert"`
+
+	UploadPath string `json:"uploadPath"`
+	JobWorkers int    `json:"jobWorkers"`
 
-	Stock string `json:"stock"`
-	Data  string `json:"data"`
+	AuditSink      string `json:"auditSink"`
+	AuditFile      string `json:"auditFile"`
+	AuditRetention string `json:"auditRetention"`
+
+	Tracing TracingSpec `json:"tracing"`
 }
 
 // New creates application configuration from the given args
@@ -64,82 +363,263 @@ func New(a *args) (*Config, error) {
 		return nil, fmt.Errorf("invalid value %q supplied for appPort: %s", a.AppPort, err)
 	}
 
-	dbPort, err := strconv.Atoi(a.DBPort)
+	server, err := parseServer(a.Server)
 	if err != nil {
-		return nil, fmt.Errorf("invalid value %q supplied for dbPort: %s", a.DBPort, err)
+		return nil, fmt.Errorf("invalid server: %s", err)
 	}
 
-	connectionString := fmt.Sprintf("%s://%s:%s/%s",
-		constants.DBTypeMongo,
-		a.DBServer,
-		a.DBPort,
-		constants.Database,
-	)
+	err = validateTLS(a.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls: %s", err)
+	}
 
-	var dbConnection string
-	u, err := url.Parse(connectionString)
-	if err == nil {
-		u.User = url.UserPassword(a.DBUsername, a.DBPassword)
-		dbConnection = u.String()
+	var dbPort int
+	if a.DBPort != "" || !a.Mongo.configured() {
+		dbPort, err = strconv.Atoi(a.DBPort)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q supplied for dbPort: %s", a.DBPort, err)
+		}
+	}
+
+	dbConnection, database, err := mongoURI(a)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mongo: %s", err)
+	}
+
+	switch a.LogFormat {
+	case "", LogText, LogJSON:
+	default:
+		return nil, fmt.Errorf("invalid logFormat %q: must be %s or %s", a.LogFormat, LogText, LogJSON)
+	}
+
+	rotation, err := parseRotation(a.LogRotation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid logRotation: %s", err)
+	}
+
+	collections, err := parseCollections(a.Collections)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collections: %s", err)
+	}
+
+	names := map[string]bool{}
+	if a.APIKey != "" {
+		names[defaultKey] = true
+	}
+	for i, k := range a.APIKeys {
+		if k.Name == "" || k.Key == "" {
+			return nil, fmt.Errorf("invalid apiKeys[%d]: name and key are required", i)
+		}
+		if names[k.Name] {
+			return nil, fmt.Errorf("invalid apiKeys[%d]: duplicate name %q", i, k.Name)
+		}
+		names[k.Name] = true
+	}
+
+	if a.JWT.Enabled() && (a.JWT.Issuer == "" || a.JWT.Audience == "") {
+		return nil, fmt.Errorf("invalid jwt: issuer and audience are required")
+	}
+
+	for i, r := range a.Routes {
+		if !strings.HasPrefix(r.Path, "/") {
+			return nil, fmt.Errorf("invalid routes[%d]: path %q must start with /", i, r.Path)
+		}
+	}
+
+	for i, l := range a.RateLimits {
+		if !strings.HasPrefix(l.Path, "/") {
+			return nil, fmt.Errorf("invalid rateLimits[%d]: path %q must start with /", i, l.Path)
+		}
+		if l.Rate < 0 || l.DailyQuota < 0 || (l.Rate > 0 && l.Burst < 1) {
+			return nil, fmt.Errorf("invalid rateLimits[%d]: rate and dailyQuota can't be negative, burst must be positive", i)
+		}
+	}
+
+	if a.AuthFailureLimit.Rate < 0 || a.AuthFailureLimit.Burst < 0 {
+		return nil, fmt.Errorf("invalid authFailureLimit: rate and burst can't be negative")
+	}
+
+	for i, t := range a.Timeouts {
+		if !strings.HasPrefix(t.Path, "/") {
+			return nil, fmt.Errorf("invalid timeouts[%d]: path %q must start with /", i, t.Path)
+		}
+		if d, err := time.ParseDuration(t.Timeout); err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid timeouts[%d]: timeout %q must be a duration like 10s, or 0", i, t.Timeout)
+		}
+	}
+
+	switch a.AuditSink {
+	case "", AuditMongo:
+	case AuditFile:
+		if a.AuditFile == "" {
+			return nil, fmt.Errorf("invalid auditSink: auditFile is required for the file sink")
+		}
+	default:
+		return nil, fmt.Errorf("invalid auditSink %q: must be %s or %s", a.AuditSink, AuditMongo, AuditFile)
+	}
+
+	auditRetention := defaultAuditRetention
+	if a.AuditRetention != "" {
+		auditRetention, err = time.ParseDuration(a.AuditRetention)
+		if err != nil || auditRetention < 0 {
+			return nil, fmt.Errorf("invalid auditRetention %q: must be a duration like 2160h, or 0", a.AuditRetention)
+		}
+	}
+
+	switch a.Tracing.Exporter {
+	case "", TracingStdout:
+	case TracingFile:
+		if a.Tracing.File == "" {
+			return nil, fmt.Errorf("invalid tracing: file is required for the file exporter")
+		}
+	case TracingOTLP:
+		if u, err := url.Parse(a.Tracing.Endpoint); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid tracing: endpoint %q must be an absolute URL for the otlp exporter", a.Tracing.Endpoint)
+		}
+	default:
+		return nil, fmt.Errorf("invalid tracing exporter %q: must be %s, %s or %s", a.Tracing.Exporter, TracingStdout, TracingFile, TracingOTLP)
+	}
+	if a.Tracing.SampleRatio < 0 || a.Tracing.SampleRatio > 1 {
+		return nil, fmt.Errorf("invalid tracing: sampleRatio must be between 0 and 1")
 	}
 
 	c := Config{
 		appPort:      appPort,
+		server:       server,
+		tls:          a.TLS,
 		APIKey:       a.APIKey,
+		apiKeys:      a.APIKeys,
+		jwt:          a.JWT,
+		routes:       a.Routes,
+		rateLimits:   a.RateLimits,
+		authLimit:    a.AuthFailureLimit,
+		timeouts:     a.Timeouts,
 		dbUsername:   a.DBUsername,
 		dbPassword:   a.DBPassword,
 		dbServer:     a.DBServer,
 		dbPORT:       dbPort,
 		dbConnection: dbConnection,
+		database:     database,
+		collections:  collections,
 		logPath:      a.LogPath,
-		logFile:      logFile(a.LogPath, "stock.log"),
 		logLevel:     parseLevel(a.LogLevel),
+		logFormat:    a.LogFormat,
+		logRotation:  rotation,
 		data:         a.Data,
 		stock:        a.Stock,
+		format:       a.Format,
+		convert:      a.Convert,
+		uploadPath:   a.UploadPath,
+		jobWorkers:   a.JobWorkers,
+		auditSink:    a.AuditSink,
+		auditFile:    a.AuditFile,
+		tracing:      a.Tracing,
 	}
+	c.auditRetention = auditRetention
+	c.accessLogPath = a.AccessLogPath
 
 	return &c, nil
 }
 
 // FromFile reads Config from a JSON file.
 func FromFile(path string) (*Config, error) {
-	content, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("unable to open config file '%s': %s", path, err)
-	}
-
-	args := &args{}
-	err = json.Unmarshal(content, args)
+	a := &args{}
+	err := readFile(path, a)
 	if err != nil {
-		return nil, fmt.Errorf("config file not valid: %s", err)
+		return nil, err
 	}
 
-	return New(args)
+	return New(a)
 }
 
 // FromFlags reads Config from a flags.
 func FromFlags(cmdArgs []string) (*Config, error) {
 	flagSet := flag.NewFlagSet(cmdArgs[0], flag.ContinueOnError)
 	a := &args{}
+	bindFlags(flagSet, a)
+
+	err := flagSet.Parse(cmdArgs[1:])
+
+	if err != nil {
+		return nil, err
+	}
 
+	return New(a)
+}
+
+// readFile sets the args of the JSON file, keeping the others.
+func readFile(path string, a *args) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to open config file '%s': %s", path, err)
+	}
+
+	err = json.Unmarshal(content, a)
+	if err != nil {
+		return fmt.Errorf("config file not valid: %s", err)
+	}
+
+	return nil
+}
+
+// bindFlags defines the flags of the args on flagSet and sets the args to
+// the flag defaults.
+func bindFlags(flagSet *flag.FlagSet, a *args) {
 	flagSet.StringVar(&a.AppPort, "app_port", "9000", "Application Port")
+	flagSet.StringVar(&a.Server.ReadTimeout, "read_timeout", "", "Timeout reading a request, 2m by default")
+	flagSet.StringVar(&a.Server.ReadHeaderTimeout, "read_header_timeout", "", "Timeout reading the headers of a request, 10s by default")
+	flagSet.StringVar(&a.Server.WriteTimeout, "write_timeout", "", "Timeout writing a response, 2m by default")
+	flagSet.StringVar(&a.Server.IdleTimeout, "idle_timeout", "", "Timeout of idle keep-alive connections, 2m by default")
+	flagSet.IntVar(&a.Server.MaxHeaderBytes, "max_header_bytes", 0, "Maximum size of the request headers, 1MB by default")
+	flagSet.StringVar(&a.Server.DrainDelay, "drain_delay", "", "Time taking requests once not ready on shutdown, 5s by default")
+	flagSet.StringVar(&a.Server.ShutdownTimeout, "shutdown_timeout", "", "Time for the requests in flight to finish on shutdown, 30s by default")
+	flagSet.StringVar(&a.TLS.CertFile, "tls_cert_file", "", "PEM certificate file, serves HTTPS when set")
+	flagSet.StringVar(&a.TLS.KeyFile, "tls_key_file", "", "PEM private key file of the certificate")
+	flagSet.StringVar(&a.TLS.MinVersion, "tls_min_version", "", "Minimum TLS version: 1.0, 1.1, 1.2 (default) or 1.3")
+	flagSet.StringVar(&a.TLS.ClientCAFile, "tls_client_ca_file", "", "PEM bundle of the CAs verifying client certificates")
+	flagSet.StringVar(&a.TLS.ClientAuth, "tls_client_auth", "", "Client certificates: optional or require (default)")
 	flagSet.StringVar(&a.APIKey, "api_key", "", "API Key")
+	flagSet.StringVar(&a.JWT.Issuer, "jwt_issuer", "", "Issuer of the accepted JWTs")
+	flagSet.StringVar(&a.JWT.Audience, "jwt_audience", "", "Audience of the accepted JWTs")
+	flagSet.StringVar(&a.JWT.Secret, "jwt_secret", "", "Secret verifying HS256 JWTs")
+	flagSet.StringVar(&a.JWT.JWKSFile, "jwt_jwks_file", "", "JWKS file verifying RS256 JWTs")
 	flagSet.StringVar(&a.DBUsername, "db_username", "", "DB Username")
 	flagSet.StringVar(&a.DBPassword, "db_password", "", "DB Password")
 	flagSet.StringVar(&a.DBServer, "db_server", "", "DB Server")
 	flagSet.StringVar(&a.DBPort, "db_port", "27017", "DB Port")
+	flagSet.StringVar(&a.Database, "database", "", "MongoDB database, trading by default")
+	flagSet.StringVar(&a.Mongo.URI, "mongo_uri", "", "MongoDB connection string, instead of db_server and db_port")
+	flagSet.StringVar(&a.Mongo.ReplicaSet, "mongo_replica_set", "", "MongoDB replica set name")
+	flagSet.StringVar(&a.Mongo.AuthSource, "mongo_auth_source", "", "MongoDB database of the user")
+	flagSet.StringVar(&a.Mongo.AuthMechanism, "mongo_auth_mechanism", "", "MongoDB authentication mechanism, like SCRAM-SHA-256")
+	flagSet.BoolVar(&a.Mongo.TLS, "mongo_tls", false, "Connect to MongoDB with TLS")
+	flagSet.StringVar(&a.Mongo.TLSCAFile, "mongo_tls_ca_file", "", "PEM bundle of the CAs verifying the MongoDB servers")
+	flagSet.IntVar(&a.Mongo.MaxPoolSize, "mongo_max_pool_size", 0, "Maximum number of connections per MongoDB server, 100 by default")
+	flagSet.StringVar(&a.Mongo.ReadPreference, "mongo_read_preference", "", "MongoDB read preference, primary by default")
+	flagSet.StringVar(&a.Mongo.WriteConcern, "mongo_write_concern", "", "MongoDB write concern, like majority")
 	flagSet.StringVar(&a.LogPath, "log_path", "", "Log Path")
 	flagSet.StringVar(&a.LogLevel, "log_level", "info", "Log Level")
+	flagSet.StringVar(&a.LogFormat, "log_format", "", "Log format: text (default) or json")
+	flagSet.StringVar(&a.AccessLogPath, "access_log_path", "", "Directory of access.log, the requests are logged with the application log when empty")
+	flagSet.IntVar(&a.LogRotation.MaxSize, "log_max_size", 0, "Size in megabytes rotating the log files, unlimited when 0")
+	flagSet.StringVar(&a.LogRotation.Interval, "log_rotate_interval", "", "Interval rotating the log files, like 24h")
+	flagSet.IntVar(&a.LogRotation.MaxBackups, "log_max_backups", 0, "Number of rotated log files kept, all when 0")
+	flagSet.StringVar(&a.LogRotation.MaxAge, "log_max_age", "", "Time rotated log files are kept, like 720h")
+	flagSet.BoolVar(&a.LogRotation.Compress, "log_compress", false, "Gzip the rotated log files")
 	flagSet.StringVar(&a.Stock, "seating", "data/stock.csv", "Stock csv")
-	flagSet.StringVar(&a.Data, "data", "data/data.csv", "data csv")
-
-	err := flagSet.Parse(cmdArgs[1:])
-
-	if err != nil {
-		return nil, err
-	}
-
-	return New(a)
+	flagSet.StringVar(&a.Data, "data", "data/data.csv", "Price file or directory of price files")
+	flagSet.StringVar(&a.Format, "format", "auto", "Price file format: auto, standard, yahoo, stooq or jsonl")
+	flagSet.BoolVar(&a.Convert, "convert", false, "Convert the legacy stock collection")
+	flagSet.StringVar(&a.UploadPath, "upload_path", "", "Directory for uploaded files, kept in MongoDB GridFS when empty")
+	flagSet.IntVar(&a.JobWorkers, "job_workers", 2, "Number of background job workers")
+	flagSet.StringVar(&a.AuditSink, "audit_sink", AuditMongo, "Audit record sink: mongo or file")
+	flagSet.StringVar(&a.AuditFile, "audit_file", "", "JSON Lines file of the file audit sink")
+	flagSet.StringVar(&a.AuditRetention, "audit_retention", "", "Time audit records are kept in MongoDB, 2160h by default, forever when 0")
+	flagSet.StringVar(&a.Tracing.Exporter, "tracing_exporter", "", "Tracing span exporter: stdout, file or otlp, disabled when empty")
+	flagSet.StringVar(&a.Tracing.File, "tracing_file", "", "JSON Lines file of the file span exporter")
+	flagSet.StringVar(&a.Tracing.Endpoint, "tracing_endpoint", "", "URL of the OTLP/HTTP traces endpoint")
+	flagSet.StringVar(&a.Tracing.ServiceName, "tracing_service_name", "", "Service name of the exported spans")
+	flagSet.Float64Var(&a.Tracing.SampleRatio, "tracing_sample_ratio", 0, "Ratio of the new traces recorded, all when 0")
 }
 
 // AppPort for the service to listen to.
@@ -147,21 +627,120 @@ func (config Config) AppPort() int {
 	return config.appPort
 }
 
+// Server returns the settings of the HTTP server.
+func (config Config) Server() Server {
+	return config.server
+}
+
+// TLS returns the HTTPS configuration.
+func (config Config) TLS() TLSSpec {
+	return config.tls
+}
+
 // DBConnection for the database.
 func (config Config) DBConnection() string {
 	return config.dbConnection
 }
 
+// Database returns the name of the MongoDB database.
+func (config Config) Database() string {
+	return config.database
+}
+
+// Collections returns the names of the MongoDB collections.
+func (config Config) Collections() Collections {
+	return config.collections
+}
+
+// defaultKey is the name of the key seeded from the single apiKey setting.
+const defaultKey = "default"
+
+// Keys returns the API keys to seed the key store with. The single apiKey
+// setting is seeded as the "default" key with every scope.
+func (config Config) Keys() []KeySpec {
+	keys := append([]KeySpec{}, config.apiKeys...)
+	if config.APIKey != "" {
+		keys = append(keys, KeySpec{
+			Name:   defaultKey,
+			Key:    config.APIKey,
+			Scopes: []string{"read", "analytics", "admin"},
+		})
+	}
+
+	return keys
+}
+
+// JWT returns the bearer token configuration.
+func (config Config) JWT() JWTSpec {
+	return config.jwt
+}
+
+// Routes returns the authentication rules of the routes, the configured
+// ones first.
+func (config Config) Routes() []RouteSpec {
+	return append(append([]RouteSpec{}, config.routes...), defaultRoutes...)
+}
+
+// AuthFailureLimit returns the limit of the failed authentications per
+// client IP, with its defaults applied.
+func (config Config) AuthFailureLimit() AuthFailureLimitSpec {
+	l := config.authLimit
+	if l.Rate == 0 {
+		l.Rate = 0.1
+	}
+	if l.Burst == 0 {
+		l.Burst = 10
+	}
+
+	return l
+}
+
+// RateLimits returns the rate limits of the routes, the configured ones
+// first.
+func (config Config) RateLimits() []LimitSpec {
+	return append(append([]LimitSpec{}, config.rateLimits...), defaultRateLimits...)
+}
+
+// Timeouts returns the deadlines of the routes, the configured ones first.
+func (config Config) Timeouts() []TimeoutSpec {
+	return append(append([]TimeoutSpec{}, config.timeouts...), defaultTimeouts...)
+}
+
 // LogLevel returns log level for the application.
 func (config Config) LogLevel() int {
 	return config.logLevel
 }
 
-// LogFile returns file where the log should be logged.
+// OpenLogs opens the log files, once the config is the one of the running
+// service; configs loaded again on reload don't open them.
+func (config *Config) OpenLogs() {
+	config.logFile = logWriter(config.logPath, "stock.log", config.logRotation)
+	if config.accessLogPath != "" {
+		config.accessLogFile = logWriter(config.accessLogPath, "access.log", config.logRotation)
+	}
+}
+
+// LogFile returns file where the log should be logged, nil until the logs
+// are opened.
 func (config Config) LogFile() io.Writer {
 	return config.logFile
 }
 
+// LogFormat returns the format of the logs, text or json.
+func (config Config) LogFormat() string {
+	if config.logFormat == "" {
+		return LogText
+	}
+
+	return config.logFormat
+}
+
+// AccessLogFile returns the file where the requests should be logged, nil
+// to log them with the application log.
+func (config Config) AccessLogFile() io.Writer {
+	return config.accessLogFile
+}
+
 // Stock config for the table.
 func (config Config) Stock() string {
 	return config.stock
@@ -172,6 +751,62 @@ func (config Config) Data() string {
 	return config.data
 }
 
+// Format of the price files, see the importer package.
+func (config Config) Format() string {
+	return config.format
+}
+
+// Convert tells the migration to convert the legacy stock collection.
+func (config Config) Convert() bool {
+	return config.convert
+}
+
+// UploadPath is the directory uploaded files are kept in until imported,
+// which only the instance that received them can import. Files are kept in
+// the upload GridFS bucket when empty.
+func (config Config) UploadPath() string {
+	return config.uploadPath
+}
+
+// JobWorkers is the number of background jobs run at once.
+func (config Config) JobWorkers() int {
+	if config.jobWorkers < 1 {
+		return 2
+	}
+	return config.jobWorkers
+}
+
+// AuditSink is where audit records are written, the audit collection by
+// default or the AuditFile.
+func (config Config) AuditSink() string {
+	if config.auditSink == "" {
+		return AuditMongo
+	}
+	return config.auditSink
+}
+
+// AuditFile is the JSON Lines file of the file audit sink.
+func (config Config) AuditFile() string {
+	return config.auditFile
+}
+
+// AuditRetention is how long the audit collection keeps the records, they
+// are kept forever when 0.
+func (config Config) AuditRetention() time.Duration {
+	return config.auditRetention
+}
+
+// Tracing returns the tracing configuration, the service is named "stock"
+// by default.
+func (config Config) Tracing() TracingSpec {
+	t := config.tracing
+	if t.ServiceName == "" {
+		t.ServiceName = "stock"
+	}
+
+	return t
+}
+
 func validate(a *args) error {
 	if a == nil {
 		return fmt.Errorf("empty args supplied")
@@ -183,11 +818,11 @@ func validate(a *args) error {
 		missing = append(missing, "appPort")
 	}
 
-	if a.DBServer == "" {
+	if a.DBServer == "" && !a.Mongo.configured() {
 		missing = append(missing, "dbServer")
 	}
 
-	if a.DBPort == "" {
+	if a.DBPort == "" && !a.Mongo.configured() {
 		missing = append(missing, "dbPort")
 	}
 
@@ -202,8 +837,11 @@ func logFile(path, name string) *os.File {
 	if path == "" {
 		return os.Stdout
 	}
+	if !strings.HasSuffix(path, string(os.PathSeparator)) {
+		path += string(os.PathSeparator)
+	}
 
-	file, err := os.OpenFile(fmt.Sprintf("%s%s", path, name), os.O_CREATE|os.O_WRONLY, 0666)
+	file, err := os.OpenFile(fmt.Sprintf("%s%s", path, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
 		log.Printf("logFile: failed to create log to file, using default stdout %s", err)
 		return os.Stdout
@@ -212,6 +850,121 @@ func logFile(path, name string) *os.File {
 	return file
 }
 
+// logWriter returns the log file name in path, rotated with the options if
+// any rotation is set.
+func logWriter(path, name string, rotation rotate.Options) io.Writer {
+	if path == "" || rotation == (rotate.Options{}) {
+		return logFile(path, name)
+	}
+
+	file, err := rotate.Open(filepath.Join(path, name), rotation)
+	if err != nil {
+		log.Printf("logWriter: failed to create log to file, using default stdout %s", err)
+		return os.Stdout
+	}
+
+	return file
+}
+
+// parseRotation returns the rotation options of spec.
+func parseRotation(spec LogRotationSpec) (rotate.Options, error) {
+	o := rotate.Options{
+		MaxSize:    int64(spec.MaxSize) * 1024 * 1024,
+		MaxBackups: spec.MaxBackups,
+		Compress:   spec.Compress,
+	}
+	if spec.MaxSize < 0 || spec.MaxBackups < 0 {
+		return o, fmt.Errorf("maxSize and maxBackups can't be negative")
+	}
+
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{{"interval", spec.Interval, &o.Interval}, {"maxAge", spec.MaxAge, &o.MaxAge}} {
+		if d.value == "" {
+			continue
+		}
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return o, fmt.Errorf("%s %q must be a duration like 24h", d.name, d.value)
+		}
+		*d.dst = v
+	}
+
+	return o, nil
+}
+
+func validateTLS(t TLSSpec) error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("certFile and keyFile must be set together")
+	}
+	if !tlsVersions[t.MinVersion] {
+		return fmt.Errorf("minVersion %q must be 1.0, 1.1, 1.2 or 1.3", t.MinVersion)
+	}
+	if t.ClientCAFile != "" && !t.Enabled() {
+		return fmt.Errorf("clientCAFile requires certFile and keyFile")
+	}
+
+	switch t.ClientAuth {
+	case "":
+	case ClientAuthOptional, ClientAuthRequire:
+		if t.ClientCAFile == "" {
+			return fmt.Errorf("clientAuth requires clientCAFile")
+		}
+	default:
+		return fmt.Errorf("clientAuth %q must be %s or %s", t.ClientAuth, ClientAuthOptional, ClientAuthRequire)
+	}
+
+	for i, c := range t.ClientCerts {
+		if t.ClientCAFile == "" {
+			return fmt.Errorf("clientCerts require clientCAFile")
+		}
+		if c.Subject == "" || c.Name == "" {
+			return fmt.Errorf("clientCerts[%d]: subject and name are required", i)
+		}
+	}
+
+	return nil
+}
+
+// parseServer returns the server settings of spec, the defaults for the
+// settings not set.
+func parseServer(spec ServerSpec) (Server, error) {
+	s := defaultServer
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"readTimeout", spec.ReadTimeout, &s.ReadTimeout},
+		{"readHeaderTimeout", spec.ReadHeaderTimeout, &s.ReadHeaderTimeout},
+		{"writeTimeout", spec.WriteTimeout, &s.WriteTimeout},
+		{"idleTimeout", spec.IdleTimeout, &s.IdleTimeout},
+		{"drainDelay", spec.DrainDelay, &s.DrainDelay},
+		{"shutdownTimeout", spec.ShutdownTimeout, &s.ShutdownTimeout},
+	} {
+		if d.value == "" {
+			continue
+		}
+
+		v, err := time.ParseDuration(d.value)
+		if err != nil || v < 0 {
+			return s, fmt.Errorf("%s %q must be a duration like 30s", d.name, d.value)
+		}
+		*d.dst = v
+	}
+
+	if spec.MaxHeaderBytes < 0 {
+		return s, fmt.Errorf("maxHeaderBytes can't be negative")
+	}
+	if spec.MaxHeaderBytes > 0 {
+		s.MaxHeaderBytes = spec.MaxHeaderBytes
+	}
+
+	return s, nil
+}
+
 func parseLevel(level string) int {
 	switch strings.ToLower(level) {
 	case "error":
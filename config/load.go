@@ -0,0 +1,237 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvPrefix prefixes the environment variables of the settings, named
+// after their flags: STOCK_DB_PASSWORD sets -db_password. A variable with
+// the _FILE suffix, like STOCK_DB_PASSWORD_FILE, reads the setting from a
+// file, for secrets mounted as files.
+const EnvPrefix = "STOCK_"
+
+// redacted replaces the secrets printed by Print.
+const redacted = "REDACTED"
+
+// Load reads Config from layered sources, each overriding the previous
+// ones: the flag defaults, the JSON file of the -config flag or the
+// STOCK_CONFIG variable, the environment variables and the flags set in
+// cmdArgs. environ is like os.Environ.
+func Load(cmdArgs []string, environ []string) (*Config, error) {
+	flagSet := flag.NewFlagSet(cmdArgs[0], flag.ContinueOnError)
+	bindFlags(flagSet, &args{})
+	configFile := flagSet.String("config", "", "JSON config file, overridden by the environment and the flags")
+	printConfig := flagSet.Bool("print-config", false, "Print the effective config with the secrets redacted and exit")
+
+	err := flagSet.Parse(cmdArgs[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	env := map[string]string{}
+	for _, kv := range environ {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	// the layers are applied to the args through their own flags, so
+	// each source only overrides the settings it sets.
+	a := &args{}
+	layers := flag.NewFlagSet(cmdArgs[0], flag.ContinueOnError)
+	bindFlags(layers, a)
+
+	if *configFile == "" {
+		*configFile = env[EnvPrefix+"CONFIG"]
+	}
+	if *configFile != "" {
+		err = readFile(*configFile, a)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = readEnv(layers, env)
+	if err != nil {
+		return nil, err
+	}
+
+	flagSet.Visit(func(f *flag.Flag) {
+		if err == nil && layers.Lookup(f.Name) != nil {
+			err = layers.Set(f.Name, f.Value.String())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := New(a)
+	if err != nil {
+		return nil, err
+	}
+	c.source = a
+	c.sourceFile = *configFile
+	c.printConfig = *printConfig
+
+	return c, nil
+}
+
+// readEnv sets the flags of layers from their environment variables.
+func readEnv(layers *flag.FlagSet, env map[string]string) error {
+	var err error
+	layers.VisitAll(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+
+		name := EnvPrefix + strings.ToUpper(f.Name)
+		value, ok := env[name]
+		if file := env[name+"_FILE"]; !ok && file != "" {
+			content, readErr := ioutil.ReadFile(file)
+			if readErr != nil {
+				err = fmt.Errorf("unable to read %s_FILE: %s", name, readErr)
+				return
+			}
+			value, ok = strings.TrimRight(string(content), "\r\n"), true
+		}
+		if !ok {
+			return
+		}
+
+		if setErr := layers.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q supplied for %s: %s", value, name, setErr)
+		}
+	})
+
+	return err
+}
+
+// SourceFile returns the JSON file read by Load, if any.
+func (config Config) SourceFile() string {
+	return config.sourceFile
+}
+
+// Change is a setting which differs between two configs, its values are
+// JSON with the secrets redacted.
+type Change struct {
+	Setting string
+	Old     string
+	New     string
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Setting, c.Old, c.New)
+}
+
+// Diff returns the settings of the configs read by Load which differ,
+// sorted by name. The secrets which changed are listed redacted.
+func Diff(old, next *Config) ([]Change, error) {
+	if old.source == nil || next.source == nil {
+		return nil, fmt.Errorf("diff: config wasn't loaded")
+	}
+
+	var values [4]map[string]json.RawMessage
+	for i, a := range []args{*old.source, *next.source, old.source.redact(), next.source.redact()} {
+		v, err := settings(a)
+		if err != nil {
+			return nil, fmt.Errorf("diff: %s", err)
+		}
+		values[i] = v
+	}
+	oldValues, nextValues, oldRedacted, nextRedacted := values[0], values[1], values[2], values[3]
+
+	var changes []Change
+	for name, value := range nextValues {
+		if !bytes.Equal(oldValues[name], value) {
+			changes = append(changes, Change{Setting: name, Old: string(oldRedacted[name]), New: string(nextRedacted[name])})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Setting < changes[j].Setting })
+
+	return changes, nil
+}
+
+// settings returns the JSON value of each setting of a.
+func settings(a args) (map[string]json.RawMessage, error) {
+	content, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+
+	var s map[string]json.RawMessage
+	err = json.Unmarshal(content, &s)
+
+	return s, err
+}
+
+// Close closes the log files opened by OpenLogs, once the logs are written.
+func (config Config) Close() error {
+	var failed []string
+	for _, w := range []io.Writer{config.logFile, config.accessLogFile} {
+		c, ok := w.(io.Closer)
+		if !ok || w == os.Stdout {
+			continue
+		}
+		if err := c.Close(); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("close: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// PrintConfig tells whether the -print-config flag was set.
+func (config Config) PrintConfig() bool {
+	return config.printConfig
+}
+
+// Print writes the effective config read by Load as JSON, with the
+// secrets redacted.
+func (config Config) Print(w io.Writer) error {
+	if config.source == nil {
+		return fmt.Errorf("print: config wasn't loaded")
+	}
+
+	content, err := json.MarshalIndent(config.source.redact(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("print: %s", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(content))
+	return err
+}
+
+// redact returns a copy of the args with the secrets replaced.
+func (a args) redact() args {
+	secrets := []*string{&a.DBPassword, &a.APIKey, &a.JWT.Secret}
+
+	if a.APIKeys != nil {
+		keys := make([]KeySpec, len(a.APIKeys))
+		copy(keys, a.APIKeys)
+		for i := range keys {
+			secrets = append(secrets, &keys[i].Key)
+		}
+		a.APIKeys = keys
+	}
+
+	for _, secret := range secrets {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	a.Mongo.URI = redactURI(a.Mongo.URI)
+
+	return a
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	content := []byte(`{"appPort": "9100", "dbServer": "file", "dbPort": "5432", "dbUsername": "foo", "logLevel": "warn"}`)
+	tmpFileName := createTemporaryFile(t, content)
+	defer os.Remove(tmpFileName)
+
+	config, err := Load([]string{"cmd", "-config", tmpFileName, "-db_server=flag"},
+		[]string{"STOCK_DB_SERVER=env", "STOCK_DB_PORT=6543", "STOCK_JOB_WORKERS=4", "PATH=/bin"})
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, 9100, config.appPort, "Expected the file to override the default")
+	assert.Equal(t, 6543, config.dbPORT, "Expected the environment to override the file")
+	assert.Equal(t, "flag", config.dbServer, "Expected the flags to override the environment")
+	assert.Equal(t, "foo", config.dbUsername)
+	assert.Equal(t, 4, config.jobWorkers)
+	assert.Equal(t, 3, config.logLevel)
+	assert.Equal(t, AuditMongo, config.auditSink, "Expected the flag default")
+	assert.False(t, config.PrintConfig())
+}
+
+func TestLoadWithoutFile(t *testing.T) {
+	config, err := Load([]string{"cmd", "-print-config"}, []string{"STOCK_DB_SERVER=env"})
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, 9000, config.appPort)
+	assert.Equal(t, "env", config.dbServer)
+	assert.True(t, config.PrintConfig())
+}
+
+func TestLoadReadsSecretFiles(t *testing.T) {
+	secretFile := createTemporaryFile(t, []byte("s3cret\n"))
+	defer os.Remove(secretFile)
+
+	config, err := Load([]string{"cmd", "-db_server=baz"},
+		[]string{"STOCK_DB_PASSWORD_FILE=" + secretFile, "STOCK_DB_USERNAME=foo"})
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, "s3cret", config.dbPassword)
+
+	_, err = Load([]string{"cmd", "-db_server=baz"}, []string{"STOCK_DB_PASSWORD_FILE=/some/missing/file"})
+	assert.Contains(t, err.Error(), "unable to read STOCK_DB_PASSWORD_FILE:")
+}
+
+func TestLoadFailsWhenEnvInvalid(t *testing.T) {
+	_, err := Load([]string{"cmd", "-db_server=baz"}, []string{"STOCK_JOB_WORKERS=many"})
+
+	assert.Contains(t, err.Error(), `invalid value "many" supplied for STOCK_JOB_WORKERS:`)
+}
+
+func TestPrint(t *testing.T) {
+	content := []byte(`{"dbServer": "baz", "dbPassword": "bar", "apiKey": "foo",
+		"apiKeys": [{"name": "web", "key": "web-secret", "scopes": ["read"]}], "jwt": {"issuer": "me", "audience": "stock", "secret": "jwt-secret"}}`)
+	tmpFileName := createTemporaryFile(t, content)
+	defer os.Remove(tmpFileName)
+
+	config, err := Load([]string{"cmd", "-config", tmpFileName}, nil)
+	require.NoError(t, err, "Expected no error")
+
+	var buf bytes.Buffer
+	require.NoError(t, config.Print(&buf), "Expected no error")
+
+	var printed args
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &printed), "Expected no error")
+	assert.Equal(t, "baz", printed.DBServer)
+	assert.Equal(t, redacted, printed.DBPassword)
+	assert.Equal(t, redacted, printed.APIKey)
+	assert.Equal(t, redacted, printed.APIKeys[0].Key)
+	assert.Equal(t, redacted, printed.JWT.Secret)
+	assert.NotContains(t, buf.String(), "web-secret")
+	assert.NotContains(t, buf.String(), "jwt-secret")
+
+	assert.Equal(t, "web-secret", config.apiKeys[0].Key, "Expected the config to keep its secrets")
+}
+
+func TestDiff(t *testing.T) {
+	old, err := Load([]string{"cmd", "-db_server=baz", "-db_password=old-secret", "-log_level=info"}, nil)
+	require.NoError(t, err, "Expected no error")
+	next, err := Load([]string{"cmd", "-db_server=baz", "-db_password=new-secret", "-log_level=debug"}, nil)
+	require.NoError(t, err, "Expected no error")
+
+	changes, err := Diff(old, next)
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, []Change{
+		{Setting: "dbPassword", Old: `"REDACTED"`, New: `"REDACTED"`},
+		{Setting: "logLevel", Old: `"info"`, New: `"debug"`},
+	}, changes)
+	assert.Equal(t, `logLevel: "info" -> "debug"`, changes[1].String())
+
+	_, err = Diff(&Config{}, next)
+	assert.EqualError(t, err, "diff: config wasn't loaded")
+}
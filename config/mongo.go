@@ -0,0 +1,286 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/x/network/connstring"
+
+	"github.com/vikashvverma/stock-backend/constants"
+)
+
+// MongoSpec configures the MongoDB connection with a full connection
+// string, or hosts and options. The options override the ones of the
+// connection string, dbUsername and dbPassword its credentials. Without URI
+// nor hosts the connection is to dbServer:dbPort.
+type MongoSpec struct {
+	// URI is a mongodb:// or mongodb+srv:// connection string.
+	URI   string   `json:"uri"`
+	Hosts []string `json:"hosts"`
+
+	ReplicaSet    string `json:"replicaSet"`
+	AuthSource    string `json:"authSource"`
+	AuthMechanism string `json:"authMechanism"`
+
+	TLS                   bool   `json:"tls"`
+	TLSCAFile             string `json:"tlsCAFile"`
+	TLSCertificateKeyFile string `json:"tlsCertificateKeyFile"`
+	TLSInsecure           bool   `json:"tlsInsecure"`
+
+	MaxPoolSize            int    `json:"maxPoolSize"`
+	ConnectTimeout         string `json:"connectTimeout"`
+	ServerSelectionTimeout string `json:"serverSelectionTimeout"`
+	SocketTimeout          string `json:"socketTimeout"`
+
+	// ReadPreference is primary, primaryPreferred, secondary,
+	// secondaryPreferred or nearest.
+	ReadPreference string `json:"readPreference"`
+	// ReadConcern is local, available, majority, linearizable or snapshot.
+	ReadConcern string `json:"readConcern"`
+	// WriteConcern is majority, a tag set name or a number of nodes.
+	WriteConcern string `json:"writeConcern"`
+}
+
+// Collections names the MongoDB collections, the names not set have their
+// default.
+type Collections struct {
+	Company string `json:"company"`
+	Price   string `json:"price"`
+	// Legacy is the stock collection converted by the migration.
+	Legacy string `json:"legacy"`
+	Job    string `json:"job"`
+	APIKey string `json:"apiKey"`
+	Audit  string `json:"audit"`
+	// Upload is the GridFS bucket of the uploaded files.
+	Upload string `json:"upload"`
+}
+
+var defaultCollections = Collections{
+	Company: constants.CompanyCollection,
+	Price:   constants.PriceCollection,
+	Legacy:  constants.Collection,
+	Job:     constants.JobCollection,
+	APIKey:  constants.APIKeyCollection,
+	Audit:   constants.AuditCollection,
+	Upload:  constants.UploadBucket,
+}
+
+// configured tells whether the connection is configured by URI or hosts
+// rather than dbServer and dbPort.
+func (m MongoSpec) configured() bool {
+	return m.URI != "" || len(m.Hosts) > 0
+}
+
+var (
+	readPreferences = map[string]bool{"primary": true, "primaryPreferred": true, "secondary": true,
+		"secondaryPreferred": true, "nearest": true}
+	readConcerns = map[string]bool{"local": true, "available": true, "majority": true,
+		"linearizable": true, "snapshot": true}
+)
+
+// mongoURI returns the connection string of the args, validated with the
+// parser of the driver so errors show at startup rather than on the first
+// query, and the database: the database arg, else the one of the connection
+// string, else trading.
+func mongoURI(a *args) (string, string, error) {
+	m := a.Mongo
+
+	var u *url.URL
+	if m.URI != "" {
+		var err error
+		u, err = url.Parse(m.URI)
+		if err != nil {
+			return "", "", fmt.Errorf("uri: %s", redactURIError(err))
+		}
+		if u.Scheme != constants.DBTypeMongo && u.Scheme != constants.DBTypeMongoSRV {
+			return "", "", fmt.Errorf("uri: scheme must be %s or %s", constants.DBTypeMongo, constants.DBTypeMongoSRV)
+		}
+		if len(m.Hosts) > 0 {
+			return "", "", fmt.Errorf("hosts can't be set with uri")
+		}
+	} else {
+		hosts := m.Hosts
+		if len(hosts) == 0 {
+			hosts = []string{net.JoinHostPort(a.DBServer, a.DBPort)}
+		}
+		u = &url.URL{Scheme: constants.DBTypeMongo, Host: strings.Join(hosts, ",")}
+	}
+
+	database := a.Database
+	if database == "" {
+		database = strings.TrimPrefix(u.Path, "/")
+	}
+	if database == "" {
+		database = constants.Database
+	}
+	if strings.ContainsAny(database, `/\. "$`) {
+		return "", "", fmt.Errorf("database %q can't contain /, \\, ., \", $ or spaces", database)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/" + database
+	}
+	if a.DBUsername != "" && a.DBPassword == "" {
+		u.User = url.User(a.DBUsername)
+	} else if a.DBUsername != "" {
+		u.User = url.UserPassword(a.DBUsername, a.DBPassword)
+	}
+
+	query, err := mongoOptions(m, u.Query())
+	if err != nil {
+		return "", "", err
+	}
+	u.RawQuery = query
+
+	err = validateURI(u)
+	if err != nil {
+		return "", "", err
+	}
+
+	return u.String(), database, nil
+}
+
+// parseCollections returns the collection names of spec, the defaults for
+// the names not set.
+func parseCollections(spec Collections) (Collections, error) {
+	c := defaultCollections
+	for _, name := range []struct {
+		key   string
+		value string
+		dst   *string
+	}{
+		{"company", spec.Company, &c.Company},
+		{"price", spec.Price, &c.Price},
+		{"legacy", spec.Legacy, &c.Legacy},
+		{"job", spec.Job, &c.Job},
+		{"apiKey", spec.APIKey, &c.APIKey},
+		{"audit", spec.Audit, &c.Audit},
+		{"upload", spec.Upload, &c.Upload},
+	} {
+		if name.value == "" {
+			continue
+		}
+		if strings.Contains(name.value, "$") || strings.HasPrefix(name.value, "system.") {
+			return Collections{}, fmt.Errorf("%s %q can't contain $ nor start with system.", name.key, name.value)
+		}
+		*name.dst = name.value
+	}
+
+	return c, nil
+}
+
+// mongoOptions sets the options of m in query and returns it encoded.
+func mongoOptions(m MongoSpec, query url.Values) (string, error) {
+	set := func(key, value string) {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	set("replicaSet", m.ReplicaSet)
+	set("authSource", m.AuthSource)
+	set("authMechanism", m.AuthMechanism)
+	set("sslCertificateAuthorityFile", m.TLSCAFile)
+	set("sslClientCertificateKeyFile", m.TLSCertificateKeyFile)
+	if m.TLS || m.TLSCAFile != "" || m.TLSCertificateKeyFile != "" || m.TLSInsecure {
+		set("ssl", "true")
+	}
+	if m.TLSInsecure {
+		set("sslInsecure", "true")
+	}
+
+	if m.MaxPoolSize < 0 || m.MaxPoolSize > 65535 {
+		return "", fmt.Errorf("maxPoolSize must be between 0 and 65535")
+	}
+	if m.MaxPoolSize > 0 {
+		set("maxPoolSize", strconv.Itoa(m.MaxPoolSize))
+	}
+
+	for _, timeout := range []struct{ name, key, value string }{
+		{"connectTimeout", "connectTimeoutMS", m.ConnectTimeout},
+		{"serverSelectionTimeout", "serverSelectionTimeoutMS", m.ServerSelectionTimeout},
+		{"socketTimeout", "socketTimeoutMS", m.SocketTimeout},
+	} {
+		if timeout.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(timeout.value)
+		if err != nil || d < 0 {
+			return "", fmt.Errorf("%s %q must be a duration like 10s", timeout.name, timeout.value)
+		}
+		set(timeout.key, strconv.FormatInt(int64(d/time.Millisecond), 10))
+	}
+
+	if m.ReadPreference != "" && !readPreferences[m.ReadPreference] {
+		return "", fmt.Errorf("readPreference %q must be primary, primaryPreferred, secondary, secondaryPreferred or nearest", m.ReadPreference)
+	}
+	set("readPreference", m.ReadPreference)
+
+	if m.ReadConcern != "" && !readConcerns[m.ReadConcern] {
+		return "", fmt.Errorf("readConcern %q must be local, available, majority, linearizable or snapshot", m.ReadConcern)
+	}
+	set("readConcernLevel", m.ReadConcern)
+	set("w", m.WriteConcern)
+
+	return query.Encode(), nil
+}
+
+// validateURI parses u like the driver does, without the password so it
+// isn't part of the errors. A mongodb+srv connection string is checked
+// without resolving its host.
+func validateURI(u *url.URL) error {
+	v := *u
+	if v.User != nil {
+		v.User = url.UserPassword(v.User.Username(), redacted)
+	}
+
+	if v.Scheme == constants.DBTypeMongoSRV {
+		if strings.Contains(v.Host, ",") || strings.Contains(v.Host, ":") {
+			return fmt.Errorf("uri: %s must have a single host without port", constants.DBTypeMongoSRV)
+		}
+		v.Scheme = constants.DBTypeMongo
+	}
+
+	cs, err := connstring.Parse(v.String())
+	if err != nil {
+		return fmt.Errorf("uri: %s", err)
+	}
+	if len(cs.Hosts) == 0 {
+		return fmt.Errorf("uri: no host")
+	}
+	for option := range cs.UnknownOptions {
+		return fmt.Errorf("uri: unknown option %s", option)
+	}
+
+	return nil
+}
+
+// redactURIError returns the error of url.Parse without the connection
+// string it quotes, which may have a password.
+func redactURIError(err error) error {
+	if urlErr, ok := err.(*url.Error); ok {
+		return urlErr.Err
+	}
+
+	return err
+}
+
+// redactURI returns uri with its password replaced.
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return redacted
+	}
+	if u.User == nil {
+		return uri
+	}
+	if _, ok := u.User.Password(); !ok {
+		return uri
+	}
+
+	u.User = url.UserPassword(u.User.Username(), redacted)
+	return u.String()
+}
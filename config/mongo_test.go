@@ -0,0 +1,95 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMongoURI(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		args     args
+		uri      string
+		database string
+	}{
+		{"server", args{DBServer: "baz", DBPort: "27017"}, "mongodb://baz:27017/trading", "trading"},
+		{"hosts", args{DBUsername: "foo", DBPassword: "b@r", Mongo: MongoSpec{Hosts: []string{"a:27017", "b:27018"},
+			ReplicaSet: "rs0", AuthSource: "admin", MaxPoolSize: 50, ConnectTimeout: "5s", ReadPreference: "secondaryPreferred",
+			ReadConcern: "majority", WriteConcern: "majority"}},
+			"mongodb://foo:b%40r@a:27017,b:27018/trading?authSource=admin&connectTimeoutMS=5000&maxPoolSize=50" +
+				"&readConcernLevel=majority&readPreference=secondaryPreferred&replicaSet=rs0&w=majority", "trading"},
+		{"uri", args{DBServer: "ignored", Mongo: MongoSpec{URI: "mongodb://a,b/demo?replicaSet=rs0&w=2", TLS: true}},
+			"mongodb://a,b/demo?replicaSet=rs0&ssl=true&w=2", "demo"},
+		{"database", args{Database: "staging", Mongo: MongoSpec{URI: "mongodb://a/admin"}}, "mongodb://a/admin", "staging"},
+		{"database path", args{Database: "staging", DBServer: "a", DBPort: "27017"}, "mongodb://a:27017/staging", "staging"},
+		{"srv", args{DBUsername: "foo", Mongo: MongoSpec{URI: "mongodb+srv://cluster.example.com"}},
+			"mongodb+srv://foo@cluster.example.com/trading", "trading"},
+	} {
+		uri, database, err := mongoURI(&test.args)
+		require.NoError(t, err, test.name)
+
+		assert.Equal(t, test.uri, uri, test.name)
+		assert.Equal(t, test.database, database, test.name)
+	}
+}
+
+func TestMongoURIFailsWhenInvalid(t *testing.T) {
+	for _, test := range []struct {
+		mongo MongoSpec
+		err   string
+	}{
+		{MongoSpec{URI: "http://a"}, "uri: scheme must be mongodb or mongodb+srv"},
+		{MongoSpec{URI: "mongodb://a", Hosts: []string{"b"}}, "hosts can't be set with uri"},
+		{MongoSpec{URI: "mongodb+srv://a:27017"}, "uri: mongodb+srv must have a single host without port"},
+		{MongoSpec{URI: "mongodb://a/?tls=true"}, "uri: unknown option tls"},
+		{MongoSpec{Hosts: []string{"a:port"}}, "uri: error parsing uri"},
+		{MongoSpec{Hosts: []string{"a"}, AuthMechanism: "KERBEROS"}, "uri: error parsing uri"},
+		{MongoSpec{Hosts: []string{"a"}, MaxPoolSize: -1}, "maxPoolSize must be between 0 and 65535"},
+		{MongoSpec{Hosts: []string{"a"}, SocketTimeout: "soon"}, `socketTimeout "soon" must be a duration like 10s`},
+		{MongoSpec{Hosts: []string{"a"}, ReadPreference: "any"}, `readPreference "any" must be`},
+		{MongoSpec{Hosts: []string{"a"}, ReadConcern: "all"}, `readConcern "all" must be`},
+	} {
+		_, _, err := mongoURI(&args{DBUsername: "foo", DBPassword: "secret", Mongo: test.mongo})
+		require.Error(t, err, test.err)
+
+		assert.Contains(t, err.Error(), test.err)
+		assert.NotContains(t, err.Error(), "secret")
+	}
+}
+
+func TestNewWithMongoURI(t *testing.T) {
+	config, err := New(&args{AppPort: "9000", Mongo: MongoSpec{URI: "mongodb://a:27017,b:27017/?replicaSet=rs0"}})
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, "mongodb://a:27017,b:27017/trading?replicaSet=rs0", config.DBConnection())
+
+	_, err = New(&args{AppPort: "9000", Mongo: MongoSpec{URI: "mongodb://a:27017/?replicaSet=rs0&w=-1"}})
+	assert.Contains(t, err.Error(), "invalid mongo: uri:")
+}
+
+func TestMongoURIFailsWhenDatabaseInvalid(t *testing.T) {
+	_, _, err := mongoURI(&args{Database: "trading.prod", DBServer: "a", DBPort: "27017"})
+
+	assert.Contains(t, err.Error(), `database "trading.prod" can't contain`)
+}
+
+func TestParseCollections(t *testing.T) {
+	c, err := parseCollections(Collections{Price: "price_v2", Audit: "staging_audit"})
+	require.NoError(t, err, "Expected no error")
+
+	expected := defaultCollections
+	expected.Price = "price_v2"
+	expected.Audit = "staging_audit"
+	assert.Equal(t, expected, c)
+
+	_, err = parseCollections(Collections{Job: "system.jobs"})
+	assert.EqualError(t, err, `job "system.jobs" can't contain $ nor start with system.`)
+}
+
+func TestRedactURI(t *testing.T) {
+	assert.Equal(t, "mongodb://foo:REDACTED@a/trading", redactURI("mongodb://foo:bar@a/trading"))
+	assert.Equal(t, "mongodb://a/trading", redactURI("mongodb://a/trading"))
+	assert.Equal(t, "", redactURI(""))
+}
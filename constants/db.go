@@ -2,7 +2,14 @@ package constants
 
 // Database constants
 const (
-	DBTypeMongo = "mongodb"
-	Database    = "trading"
-	Collection  = "stock"
+	DBTypeMongo       = "mongodb"
+	DBTypeMongoSRV    = "mongodb+srv"
+	Database          = "trading"
+	Collection        = "stock"
+	CompanyCollection = "company"
+	PriceCollection   = "price"
+	JobCollection     = "job"
+	APIKeyCollection  = "apikey"
+	AuditCollection   = "audit"
+	UploadBucket      = "upload"
 )
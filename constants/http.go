@@ -0,0 +1,6 @@
+package constants
+
+// HTTP constants
+const (
+	RequestIDHeader = "X-Request-ID"
+)
@@ -3,6 +3,7 @@ package factory
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"sync"
 	"time"
 
@@ -10,24 +11,57 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/vikashvverma/stock-backend/audit"
+	"github.com/vikashvverma/stock-backend/auth"
 	"github.com/vikashvverma/stock-backend/config"
+	"github.com/vikashvverma/stock-backend/healthcheck"
+	"github.com/vikashvverma/stock-backend/ingest"
+	"github.com/vikashvverma/stock-backend/jobs"
+	"github.com/vikashvverma/stock-backend/log"
+	"github.com/vikashvverma/stock-backend/metrics"
 	"github.com/vikashvverma/stock-backend/stock"
+	"github.com/vikashvverma/stock-backend/tracing"
 )
 
-var dmDB sync.Once
+var (
+	dmDB         sync.Once
+	ingesterOnce sync.Once
+	runnerOnce   sync.Once
+	auditOnce    sync.Once
+	metricsOnce  sync.Once
+	tracerOnce   sync.Once
+	readyOnce    sync.Once
+)
 
 // Factory represents factory for the service.
 type Factory interface {
 	Client() *mongo.Client
+	Database() *mongo.Database
 	Trader() stock.Trader
+	Indexer() stock.Indexer
+	Loader() stock.Loader
+	Ingester() ingest.Ingester
+	Runner() jobs.Runner
+	KeyStore() auth.KeyStore
+	AuditStore() audit.Store
+	Metrics() *metrics.Registry
+	Tracer() *tracing.Tracer
+	Readiness() *healthcheck.Readiness
+	Close() error
 }
 
 type factory struct {
-	config  *config.Config
-	logger  *logrus.Logger
-	db      *sql.DB
-	client  *mongo.Client
-	seating map[int]int
+	config   *config.Config
+	logger   *logrus.Logger
+	db       *sql.DB
+	client   *mongo.Client
+	ingester ingest.Ingester
+	runner   jobs.Runner
+	audit    audit.Store
+	metrics  *metrics.Registry
+	tracer   *tracing.Tracer
+	ready    *healthcheck.Readiness
+	seating  map[int]int
 }
 
 // NewFactory returns a factory object.
@@ -44,7 +78,9 @@ func (f *factory) Client() *mongo.Client {
 	dmDB.Do(func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
 		defer cancel()
-		client, err := mongo.Connect(ctx, options.Client().ApplyURI(f.config.DBConnection()))
+		client, err := mongo.Connect(ctx, options.Client().
+			ApplyURI(f.config.DBConnection()).
+			SetMonitor(metrics.CommandMonitor(f.Metrics(), tracing.CommandMonitor(f.Tracer(), log.CommandMonitor(f.logger)))))
 
 		f.client = client
 		dbError = err
@@ -57,7 +93,135 @@ func (f *factory) Client() *mongo.Client {
 	return f.client
 }
 
+// Database returns the configured database of the connection.
+func (f *factory) Database() *mongo.Database {
+	return f.Client().Database(f.config.Database())
+}
+
+// stockCollections returns the configured names of the stock collections.
+func (f *factory) stockCollections() stock.Collections {
+	c := f.config.Collections()
+	return stock.Collections{Company: c.Company, Price: c.Price, Legacy: c.Legacy}
+}
+
 // Trader returns a new stock.Trader instance
 func (f *factory) Trader() stock.Trader {
-	return stock.New(f.Client())
+	return tracing.Trader(metrics.Trader(stock.New(f.Database(), f.stockCollections()), f.Metrics()), f.Tracer())
+}
+
+// Indexer returns a new stock.Indexer instance
+func (f *factory) Indexer() stock.Indexer {
+	return stock.NewIndexer(f.Database(), f.stockCollections())
+}
+
+// Loader returns a new stock.Loader instance
+func (f *factory) Loader() stock.Loader {
+	return stock.NewLoader(f.Database(), f.stockCollections())
+}
+
+// Ingester returns the ingest.Ingester shared by the upload handlers.
+func (f *factory) Ingester() ingest.Ingester {
+	ingesterOnce.Do(func() {
+		var spool ingest.Spool
+		if f.config.UploadPath() != "" {
+			spool = ingest.NewFileSpool(f.config.UploadPath())
+		} else {
+			spool = ingest.NewGridFSSpool(f.Database(), f.config.Collections().Upload)
+		}
+		f.ingester = ingest.New(f.Loader(), f.Runner(), spool, f.logger)
+	})
+
+	return f.ingester
+}
+
+// Runner returns the jobs.Runner shared by the service.
+func (f *factory) Runner() jobs.Runner {
+	runnerOnce.Do(func() {
+		f.runner = jobs.New(jobs.NewMongoStore(f.Database().Collection(f.config.Collections().Job)), f.config.JobWorkers(), f.logger)
+		metrics.Jobs(f.Metrics(), f.runner)
+	})
+
+	return f.runner
+}
+
+// KeyStore returns a new auth.KeyStore instance
+func (f *factory) KeyStore() auth.KeyStore {
+	return auth.NewMongoKeyStore(f.Database().Collection(f.config.Collections().APIKey))
+}
+
+// AuditStore returns the audit.Store shared by the service.
+func (f *factory) AuditStore() audit.Store {
+	auditOnce.Do(func() {
+		if f.config.AuditSink() != config.AuditFile {
+			collection := f.Database().Collection(f.config.Collections().Audit)
+			if err := audit.EnsureIndexes(collection, f.config.AuditRetention()); err != nil {
+				f.logger.WithError(err).Warnf("unable to ensure audit indexes")
+			}
+			f.audit = audit.NewMongoStore(collection)
+			return
+		}
+
+		store, err := audit.NewFileStore(f.config.AuditFile())
+		if err != nil {
+			f.logger.WithError(err).Fatalf("Could not open the audit file: %s", err)
+		}
+		f.audit = store
+	})
+
+	return f.audit
+}
+
+// Metrics returns the metrics.Registry shared by the service.
+func (f *factory) Metrics() *metrics.Registry {
+	metricsOnce.Do(func() {
+		f.metrics = metrics.NewRegistry()
+	})
+
+	return f.metrics
+}
+
+// Tracer returns the tracing.Tracer shared by the service, nil if tracing
+// is disabled.
+func (f *factory) Tracer() *tracing.Tracer {
+	tracerOnce.Do(func() {
+		tracer, err := tracing.New(f.config.Tracing(), f.logger)
+		if err != nil {
+			f.logger.WithError(err).Fatalf("Could not create the tracer: %s", err)
+		}
+		f.tracer = tracer
+	})
+
+	return f.tracer
+}
+
+// Readiness returns the healthcheck.Readiness of the service.
+func (f *factory) Readiness() *healthcheck.Readiness {
+	readyOnce.Do(func() {
+		f.ready = &healthcheck.Readiness{}
+	})
+
+	return f.ready
+}
+
+// Close exports the pending spans and disconnects from the DB, once
+// nothing uses them anymore.
+func (f *factory) Close() error {
+	err := f.tracer.Close()
+	if err != nil {
+		f.logger.WithError(err).Errorf("Could not close the tracer: %s", err)
+	}
+
+	if f.client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = f.client.Disconnect(ctx)
+	if err != nil {
+		return fmt.Errorf("close: could not disconnect from the DB: %s", err)
+	}
+
+	return nil
 }
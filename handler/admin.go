@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/factory"
+	"github.com/vikashvverma/stock-backend/response"
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+// Indexes represents the index listing API handler.
+func Indexes(i stock.Indexer, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := i.Stats()
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("Indexes: error getting index stats")
+			response.Response{Errors: &response.Error{Reason: "could not list indexes", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		response.Response{
+			Success: true,
+			Result:  stats,
+		}.Send(w)
+	}
+}
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/audit"
+	"github.com/vikashvverma/stock-backend/factory"
+	"github.com/vikashvverma/stock-backend/response"
+)
+
+// defaultAuditLimit is the number of audit records listed when no limit is
+// given.
+const defaultAuditLimit = 100
+
+// Audit represents the audit record query API handler. The key, from and
+// to (RFC 3339) query params filter the records.
+func Audit(s audit.Store, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queryParams := r.URL.Query()
+		q := audit.Query{Key: queryParams.Get("key"), Limit: defaultAuditLimit}
+
+		for param, t := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+			v := queryParams.Get(param)
+			if v == "" {
+				continue
+			}
+
+			var err error
+			*t, err = time.Parse(time.RFC3339, v)
+			if err != nil {
+				l.WithContext(r.Context()).WithError(err).Errorf("Audit: invalid %s: %s", param, v)
+				response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid %s: %s", param, v), Code: response.CodeInvalidParam, Field: param}}.ClientError(w)
+				return
+			}
+		}
+
+		if v := queryParams.Get("limit"); v != "" {
+			var err error
+			q.Limit, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || q.Limit < 1 {
+				l.WithContext(r.Context()).Errorf("Audit: invalid limit: %s", v)
+				response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid limit: %s", v), Code: response.CodeInvalidParam, Field: "limit"}}.ClientError(w)
+				return
+			}
+		}
+
+		records, err := s.Find(q)
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("Audit: error finding audit records")
+			response.Response{Errors: &response.Error{Reason: "could not find audit records", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		response.Response{
+			Success: true,
+			Result:  records,
+		}.Send(w)
+	}
+}
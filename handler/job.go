@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/audit"
+	"github.com/vikashvverma/stock-backend/factory"
+	"github.com/vikashvverma/stock-backend/jobs"
+	"github.com/vikashvverma/stock-backend/response"
+)
+
+// defaultJobLimit is the number of jobs listed when no limit is given.
+const defaultJobLimit = 50
+
+// Jobs represents the job listing API handler.
+func Jobs(run jobs.Runner, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queryParams := r.URL.Query()
+		status := queryParams.Get("status")
+
+		limit := int64(defaultJobLimit)
+		if v := queryParams.Get("limit"); v != "" {
+			var err error
+			limit, err = strconv.ParseInt(v, 10, 64)
+			if err != nil || limit < 1 {
+				l.WithContext(r.Context()).Errorf("Jobs: invalid limit: %s", v)
+				response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid limit: %s", v), Code: response.CodeInvalidParam, Field: "limit"}}.ClientError(w)
+				return
+			}
+		}
+
+		list, err := run.Jobs(status, limit)
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("Jobs: error listing jobs")
+			response.Response{Errors: &response.Error{Reason: "could not list jobs", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		response.Response{
+			Success: true,
+			Result:  list,
+		}.Send(w)
+	}
+}
+
+// Job represents the job status API handler.
+func Job(run jobs.Runner, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := mux.Vars(r)["id"]
+		if !ok {
+			l.WithContext(r.Context()).Errorf("Job: could not read 'id' from path params")
+			response.Response{Errors: &response.Error{Reason: "path params not valid", Code: response.CodeInvalidParam, Field: "id"}}.ClientError(w)
+			return
+		}
+
+		job, err := run.Job(id)
+		if err == jobs.ErrNotFound {
+			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("job not found: %s", id), Code: response.CodeNotFound}}.NotFound(w)
+			return
+		}
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("Job: error getting job")
+			response.Response{Errors: &response.Error{Reason: "could not get job", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		response.Response{
+			Success: true,
+			Result:  job,
+		}.Send(w)
+	}
+}
+
+// CancelJob represents the job cancellation API handler.
+func CancelJob(run jobs.Runner, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, ok := mux.Vars(r)["id"]
+		if !ok {
+			l.WithContext(r.Context()).Errorf("CancelJob: could not read 'id' from path params")
+			response.Response{Errors: &response.Error{Reason: "path params not valid", Code: response.CodeInvalidParam, Field: "id"}}.ClientError(w)
+			return
+		}
+
+		job, err := run.Cancel(id)
+		if err == jobs.ErrNotFound {
+			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("job not found: %s", id), Code: response.CodeNotFound}}.NotFound(w)
+			return
+		}
+		if statusErr, ok := err.(*jobs.StatusError); ok {
+			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("job %s is %s", id, statusErr.Status), Code: response.CodeConflict}}.Conflict(w)
+			return
+		}
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("CancelJob: could not cancel job")
+			response.Response{Errors: &response.Error{Reason: "could not cancel job", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		audit.Action(r, "job.cancel", map[string]string{"job": job.ID, "kind": job.Kind})
+
+		response.Response{
+			Success: true,
+			Result:  job,
+		}.Send(w)
+	}
+}
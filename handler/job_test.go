@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/jobs"
+)
+
+func TestJobErrors(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	store := jobs.NewMemoryStore()
+	require.NoError(t, store.Insert(jobs.Job{ID: "done", Kind: "echo", Status: jobs.StatusSucceeded, Created: time.Now()}))
+	run := jobs.New(store, 1, logger)
+
+	for _, tc := range []struct {
+		handler http.HandlerFunc
+		url     string
+		id      string
+		code    int
+		body    string
+	}{
+		{Jobs(run, nil, logger), "/admin/jobs?limit=0", "", http.StatusBadRequest,
+			`{"success":false,"errors":{"reason":"invalid limit: 0","code":"invalid_param","field":"limit"}}`},
+		{Job(run, nil, logger), "/admin/jobs/unknown", "unknown", http.StatusNotFound,
+			`{"success":false,"errors":{"reason":"job not found: unknown","code":"not_found"}}`},
+		{CancelJob(run, nil, logger), "/admin/jobs/done/cancel", "done", http.StatusConflict,
+			`{"success":false,"errors":{"reason":"job done is succeeded","code":"conflict"}}`},
+	} {
+		r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+		if tc.id != "" {
+			r = mux.SetURLVars(r, map[string]string{"id": tc.id})
+		}
+		w := httptest.NewRecorder()
+
+		tc.handler(w, r)
+
+		assert.Equal(t, tc.code, w.Code, tc.url)
+		assert.JSONEq(t, tc.body, w.Body.String(), tc.url)
+	}
+}
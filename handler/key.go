@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/audit"
+	"github.com/vikashvverma/stock-backend/auth"
+	"github.com/vikashvverma/stock-backend/factory"
+	"github.com/vikashvverma/stock-backend/response"
+)
+
+// keyRequest is the body of the key creation API.
+type keyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// keySecret is the result of the APIs creating a secret, the only time the
+// secret is shown.
+type keySecret struct {
+	Key    auth.Key `json:"key"`
+	Secret string   `json:"secret"`
+}
+
+// Keys represents the API key listing API handler.
+func Keys(ks auth.KeyStore, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := ks.List()
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("Keys: error listing keys")
+			response.Response{Errors: &response.Error{Reason: "could not list keys", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		response.Response{
+			Success: true,
+			Result:  keys,
+		}.Send(w)
+	}
+}
+
+// CreateKey represents the API key creation API handler.
+func CreateKey(ks auth.KeyStore, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req keyRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("CreateKey: could not decode request")
+			response.Response{Errors: &response.Error{Reason: "request body not valid", Code: response.CodeInvalidParam}}.ClientError(w)
+			return
+		}
+
+		key, secret, err := auth.NewKey(req.Name, req.Scopes, req.ExpiresAt)
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("CreateKey: invalid key")
+			response.Response{Errors: &response.Error{Reason: err.Error(), Code: response.CodeInvalidParam}}.ClientError(w)
+			return
+		}
+
+		err = ks.Create(key)
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("CreateKey: could not create key")
+			response.Response{Errors: &response.Error{Reason: "could not create key", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		audit.Action(r, "key.create", keyDetails(key))
+
+		response.Response{
+			Success: true,
+			Result:  keySecret{Key: key, Secret: secret},
+		}.Created(w)
+	}
+}
+
+// RotateKey represents the API key rotation API handler, the previous secret
+// stops working right away.
+func RotateKey(ks auth.KeyStore, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := managedKey(ks, w, r, l, "RotateKey")
+		if !ok {
+			return
+		}
+
+		secret, err := key.Rotate()
+		if err == nil {
+			err = ks.Update(key)
+		}
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("RotateKey: could not rotate key")
+			response.Response{Errors: &response.Error{Reason: "could not rotate key", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		audit.Action(r, "key.rotate", keyDetails(key))
+
+		response.Response{
+			Success: true,
+			Result:  keySecret{Key: key, Secret: secret},
+		}.Send(w)
+	}
+}
+
+// DisableKey represents the API key disabling API handler.
+func DisableKey(ks auth.KeyStore, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := findKey(ks, w, r, l, "DisableKey")
+		if !ok {
+			return
+		}
+
+		key.Disabled = true
+		err := ks.Update(key)
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("DisableKey: could not disable key")
+			response.Response{Errors: &response.Error{Reason: "could not disable key", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		audit.Action(r, "key.disable", keyDetails(key))
+
+		response.Response{
+			Success: true,
+			Result:  key,
+		}.Send(w)
+	}
+}
+
+// DeleteKey represents the API key deletion API handler.
+func DeleteKey(ks auth.KeyStore, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, ok := managedKey(ks, w, r, l, "DeleteKey")
+		if !ok {
+			return
+		}
+
+		err := ks.Delete(key.ID)
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("DeleteKey: could not delete key")
+			response.Response{Errors: &response.Error{Reason: "could not delete key", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		audit.Action(r, "key.delete", keyDetails(key))
+
+		response.Response{
+			Success: true,
+			Result:  key,
+		}.Send(w)
+	}
+}
+
+func keyDetails(k auth.Key) map[string]string {
+	return map[string]string{"id": k.ID, "name": k.Name, "scopes": strings.Join(k.Scopes, ",")}
+}
+
+// findKey returns the key with the id of the path params. It writes the
+// error response and returns false if there is none.
+func findKey(ks auth.KeyStore, w http.ResponseWriter, r *http.Request, l *logrus.Logger, name string) (auth.Key, bool) {
+	id, ok := mux.Vars(r)["id"]
+	if !ok {
+		l.WithContext(r.Context()).Errorf("%s: could not read 'id' from path params", name)
+		response.Response{Errors: &response.Error{Reason: "path params not valid", Code: response.CodeInvalidParam, Field: "id"}}.ClientError(w)
+		return auth.Key{}, false
+	}
+
+	key, err := ks.Get(id)
+	if err == auth.ErrKeyNotFound {
+		response.Response{Errors: &response.Error{Reason: fmt.Sprintf("key not found: %s", id), Code: response.CodeNotFound}}.NotFound(w)
+		return key, false
+	}
+	if err != nil {
+		l.WithContext(r.Context()).WithError(err).Errorf("%s: error getting key", name)
+		response.Response{Errors: &response.Error{Reason: "could not get key", Code: response.CodeInternal}}.ServerError(w)
+		return key, false
+	}
+
+	return key, true
+}
+
+// managedKey is like findKey but refuses the keys of the configuration,
+// they would be seeded again on the next start.
+func managedKey(ks auth.KeyStore, w http.ResponseWriter, r *http.Request, l *logrus.Logger, name string) (auth.Key, bool) {
+	key, ok := findKey(ks, w, r, l, name)
+	if !ok {
+		return key, false
+	}
+
+	if key.Configured() {
+		l.WithContext(r.Context()).Errorf("%s: key %q is set in the configuration", name, key.Name)
+		response.Response{Errors: &response.Error{Reason: fmt.Sprintf("key %q is set in the configuration", key.Name), Code: response.CodeConflict}}.Conflict(w)
+		return key, false
+	}
+
+	return key, true
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/factory"
+	"github.com/vikashvverma/stock-backend/response"
+)
+
+// RouteNotFound represents the handler of the requests matching no route.
+func RouteNotFound(f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l.WithContext(r.Context()).Errorf("RouteNotFound: no route for %s %s", r.Method, r.URL.Path)
+		response.Response{Errors: &response.Error{Reason: fmt.Sprintf("route not found: %s", r.URL.Path),
+			Code: response.CodeRouteNotFound}}.NotFound(w)
+	}
+}
+
+// MethodNotAllowed represents the handler of the requests matching a route
+// with another method.
+func MethodNotAllowed(f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l.WithContext(r.Context()).Errorf("MethodNotAllowed: %s not allowed for %s", r.Method, r.URL.Path)
+		response.Response{Errors: &response.Error{Reason: fmt.Sprintf("method not allowed: %s", r.Method),
+			Code: response.CodeMethodNotAllowed}}.MethodNotAllowed(w)
+	}
+}
@@ -20,15 +20,15 @@ func Find(t stock.Trader, f factory.Factory, l *logrus.Logger) http.HandlerFunc
 		vars := mux.Vars(r)
 		name, ok := vars["name"]
 		if !ok {
-			l.Errorf("Find: could not read 'name' from path params")
-			response.Response{Errors: &response.Error{Reason: "path params not valid"}}.ClientError(w)
+			l.WithContext(r.Context()).Errorf("Find: could not read 'name' from path params")
+			response.Response{Errors: &response.Error{Reason: "path params not valid", Code: response.CodeInvalidParam}}.ClientError(w)
 			return
 		}
 
-		stock, err := t.Find(name)
+		stock, err := t.Find(r.Context(), name)
 		if err != nil {
-			l.WithError(err).Errorf("Find: error getting price points")
-			response.Response{Errors: &response.Error{Reason: "could not find anything"}}.ServerError(w)
+			l.WithContext(r.Context()).WithError(err).Errorf("Find: error getting price points")
+			traderError(w, err)
 			return
 		}
 
@@ -46,43 +46,45 @@ func Top(t stock.Trader, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
 		vars := mux.Vars(r)
 		fromDateString, ok := vars["from"]
 		if !ok {
-			l.Errorf("Top: could not read `from` Date from path params")
-			response.Response{Errors: &response.Error{Reason: "path params not valid"}}.ClientError(w)
+			l.WithContext(r.Context()).Errorf("Top: could not read `from` Date from path params")
+			response.Response{Errors: &response.Error{Reason: "path params not valid", Code: response.CodeInvalidParam}}.ClientError(w)
 			return
 		}
 
 		fromDate, err := time.Parse(fmt.Sprintf("%s-%s-%s", constants.StdZeroDay, constants.StdZeroMonth, constants.StdLongYear), fromDateString)
 		if err != nil {
-			l.WithError(err).Errorf("Top: invalid `from` date: %s", fromDateString)
-			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid from date: %s", fromDateString)}}.ClientError(w)
+			l.WithContext(r.Context()).WithError(err).Errorf("Top: invalid `from` date: %s", fromDateString)
+			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid from date: %s", fromDateString),
+				Code: response.CodeInvalidParam, Field: "from"}}.ClientError(w)
 			return
 		}
 
 		toDateString, ok := vars["to"]
 		if !ok {
-			l.Errorf("Top: could not read `to` Date from path params")
-			response.Response{Errors: &response.Error{Reason: "path params not valid"}}.ClientError(w)
+			l.WithContext(r.Context()).Errorf("Top: could not read `to` Date from path params")
+			response.Response{Errors: &response.Error{Reason: "path params not valid", Code: response.CodeInvalidParam}}.ClientError(w)
 			return
 		}
 
 		toDate, err := time.Parse(fmt.Sprintf("%s-%s-%s", constants.StdZeroDay, constants.StdZeroMonth, constants.StdLongYear), toDateString)
 		if err != nil {
-			l.WithError(err).Errorf("Top: invalid `to` date: %s", toDateString)
-			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid `to` date: %s", toDateString)}}.ClientError(w)
+			l.WithContext(r.Context()).WithError(err).Errorf("Top: invalid `to` date: %s", toDateString)
+			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid `to` date: %s", toDateString),
+				Code: response.CodeInvalidParam, Field: "to"}}.ClientError(w)
 			return
 		}
 
-		topStock, err := t.Top(fromDate, toDate, true)
+		topStock, err := t.Top(r.Context(), fromDate, toDate, true)
 		if err != nil {
-			l.WithError(err).Errorf("Top: error getting top stocks")
-			response.Response{Errors: &response.Error{Reason: "could not find anything"}}.ServerError(w)
+			l.WithContext(r.Context()).WithError(err).Errorf("Top: error getting top stocks")
+			traderError(w, err)
 			return
 		}
 
-		bottomStock, err := t.Top(fromDate, toDate, false)
+		bottomStock, err := t.Top(r.Context(), fromDate, toDate, false)
 		if err != nil {
-			l.WithError(err).Errorf("Top: error getting bottom stocks")
-			response.Response{Errors: &response.Error{Reason: "could not find anything"}}.ServerError(w)
+			l.WithContext(r.Context()).WithError(err).Errorf("Top: error getting bottom stocks")
+			traderError(w, err)
 			return
 		}
 
@@ -100,39 +102,41 @@ func FindList(t stock.Trader, f factory.Factory, l *logrus.Logger) http.HandlerF
 		vars := mux.Vars(r)
 		fromDateString, ok := vars["from"]
 		if !ok {
-			l.Errorf("FindList: could not read `from` Date from path params")
-			response.Response{Errors: &response.Error{Reason: "path params not valid"}}.ClientError(w)
+			l.WithContext(r.Context()).Errorf("FindList: could not read `from` Date from path params")
+			response.Response{Errors: &response.Error{Reason: "path params not valid", Code: response.CodeInvalidParam}}.ClientError(w)
 			return
 		}
 
 		fromDate, err := time.Parse(fmt.Sprintf("%s-%s-%s", constants.StdZeroDay, constants.StdZeroMonth, constants.StdLongYear), fromDateString)
 		if err != nil {
-			l.WithError(err).Errorf("FindList: invalid `from` date: %s", fromDateString)
-			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid from date: %s", fromDateString)}}.ClientError(w)
+			l.WithContext(r.Context()).WithError(err).Errorf("FindList: invalid `from` date: %s", fromDateString)
+			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid from date: %s", fromDateString),
+				Code: response.CodeInvalidParam, Field: "from"}}.ClientError(w)
 			return
 		}
 
 		toDateString, ok := vars["to"]
 		if !ok {
-			l.Errorf("FindList: could not read `to` Date from path params")
-			response.Response{Errors: &response.Error{Reason: "path params not valid"}}.ClientError(w)
+			l.WithContext(r.Context()).Errorf("FindList: could not read `to` Date from path params")
+			response.Response{Errors: &response.Error{Reason: "path params not valid", Code: response.CodeInvalidParam}}.ClientError(w)
 			return
 		}
 
 		toDate, err := time.Parse(fmt.Sprintf("%s-%s-%s", constants.StdZeroDay, constants.StdZeroMonth, constants.StdLongYear), toDateString)
 		if err != nil {
-			l.WithError(err).Errorf("FindList: invalid `to` date: %s", toDateString)
-			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid `to` date: %s", toDateString)}}.ClientError(w)
+			l.WithContext(r.Context()).WithError(err).Errorf("FindList: invalid `to` date: %s", toDateString)
+			response.Response{Errors: &response.Error{Reason: fmt.Sprintf("invalid `to` date: %s", toDateString),
+				Code: response.CodeInvalidParam, Field: "to"}}.ClientError(w)
 			return
 		}
 
 		queryParams := r.URL.Query()
 		tickers := queryParams["ticker"]
 
-		stocks, err := t.FindAll(tickers, fromDate, toDate)
+		stocks, err := t.FindAll(r.Context(), tickers, fromDate, toDate)
 		if err != nil {
-			l.WithError(err).Errorf("FindList: error getting price points")
-			response.Response{Errors: &response.Error{Reason: "could not find anything"}}.ServerError(w)
+			l.WithContext(r.Context()).WithError(err).Errorf("FindList: error getting price points")
+			traderError(w, err)
 			return
 		}
 
@@ -143,3 +147,28 @@ func FindList(t stock.Trader, f factory.Factory, l *logrus.Logger) http.HandlerF
 
 	}
 }
+
+// traderError writes the error response matching an error of the Trader,
+// a server error unless it is a *stock.Error.
+func traderError(w http.ResponseWriter, err error) {
+	e, ok := err.(*stock.Error)
+	if !ok {
+		response.Response{Errors: &response.Error{Reason: "could not find anything", Code: response.CodeInternal}}.ServerError(w)
+		return
+	}
+
+	res := response.Response{Errors: &response.Error{Reason: e.Message, Code: e.Kind, Field: e.Field, Details: e.Details}}
+	switch e.Kind {
+	case stock.KindNotFound:
+		res.NotFound(w)
+	case stock.KindInvalidRange:
+		res.ClientError(w)
+	case stock.KindTimeout:
+		res.GatewayTimeout(w)
+	case stock.KindUnavailable, stock.KindCanceled:
+		// a canceled request has no client left to read the response.
+		res.ServiceUnavailable(w)
+	default:
+		res.ServerError(w)
+	}
+}
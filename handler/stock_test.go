@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+type fakeTrader struct {
+	err error
+}
+
+func (f fakeTrader) Find(context.Context, string) ([]stock.PricePoint, error) {
+	return nil, f.err
+}
+
+func (f fakeTrader) FindAll(context.Context, []string, time.Time, time.Time) ([]stock.Stock, error) {
+	return nil, f.err
+}
+
+func (f fakeTrader) Top(ctx context.Context, from, to time.Time, best bool) (interface{}, error) {
+	return nil, f.err
+}
+
+func TestFindErrors(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+
+	for _, tc := range []struct {
+		err  error
+		code int
+		body string
+	}{
+		{stock.NotFound("FOO"), http.StatusNotFound,
+			`{"success":false,"errors":{"reason":"stock not found: FOO","code":"not_found","details":{"name":"FOO"}}}`},
+		{stock.Unavailable("find", fmt.Errorf("server selection timeout")), http.StatusServiceUnavailable,
+			`{"success":false,"errors":{"reason":"stock data unavailable","code":"unavailable"}}`},
+		{stock.Timeout("find", context.DeadlineExceeded), http.StatusGatewayTimeout,
+			`{"success":false,"errors":{"reason":"stock data query timed out","code":"timeout"}}`},
+		{fmt.Errorf("find: could not decode result"), http.StatusInternalServerError,
+			`{"success":false,"errors":{"reason":"could not find anything","code":"internal"}}`},
+	} {
+		r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/stock/FOO", nil), map[string]string{"name": "FOO"})
+		w := httptest.NewRecorder()
+
+		Find(fakeTrader{err: tc.err}, nil, logger)(w, r)
+
+		assert.Equal(t, tc.code, w.Code)
+		assert.JSONEq(t, tc.body, w.Body.String())
+	}
+}
+
+func TestTopInvalidRange(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	from := time.Date(2016, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	r := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/stock/top/01-02-2016/01-01-2016", nil),
+		map[string]string{"from": "01-02-2016", "to": "01-01-2016"})
+	w := httptest.NewRecorder()
+
+	Top(fakeTrader{err: stock.InvalidRange(from, to)}, nil, logger)(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"success":false,"errors":{"reason":"invalid range: 2016-02-01 is after 2016-01-01",
+		"code":"invalid_range","field":"from","details":{"from":"2016-02-01","to":"2016-01-01"}}}`, w.Body.String())
+}
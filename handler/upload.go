@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/audit"
+	"github.com/vikashvverma/stock-backend/factory"
+	"github.com/vikashvverma/stock-backend/importer"
+	"github.com/vikashvverma/stock-backend/ingest"
+	"github.com/vikashvverma/stock-backend/response"
+)
+
+// maxUploadSize is the largest file accepted by the upload handlers.
+var maxUploadSize int64 = 1 << 30
+
+// upload is the body of an upload request, counting the bytes read to tell
+// a body over maxUploadSize from one cut short.
+type upload struct {
+	io.ReadCloser
+	read int64
+}
+
+func (u *upload) Read(p []byte) (int, error) {
+	n, err := u.ReadCloser.Read(p)
+	u.read += int64(n)
+
+	return n, err
+}
+
+// readError answers an upload which couldn't be read: 413 once it went over
+// maxUploadSize, 400 otherwise, like a client disconnecting.
+func readError(w http.ResponseWriter, u *upload, err error) {
+	if u.read >= maxUploadSize {
+		response.Response{Errors: &response.Error{Reason: fmt.Sprintf("file is larger than %d MiB", maxUploadSize>>20), Code: response.CodeInvalidParam, Field: "file"}}.PayloadTooLarge(w)
+		return
+	}
+
+	response.Response{Errors: &response.Error{Reason: err.Error(), Code: response.CodeInvalidParam, Field: "file"}}.ClientError(w)
+}
+
+// UploadCompanies represents the company CSV upload API handler.
+func UploadCompanies(i ingest.Ingester, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, body, err := uploadBody(w, r)
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("UploadCompanies: could not read upload")
+			readError(w, u, err)
+			return
+		}
+
+		job, err := i.Companies(body)
+		if readErr, ok := err.(*ingest.ReadError); ok {
+			l.WithContext(r.Context()).WithError(err).Errorf("UploadCompanies: could not read upload")
+			readError(w, u, readErr.Err)
+			return
+		}
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("UploadCompanies: could not queue import")
+			response.Response{Errors: &response.Error{Reason: "could not queue import", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		audit.Action(r, job.Kind, map[string]string{"job": job.ID})
+
+		response.Response{
+			Success: true,
+			Result:  job,
+		}.Accepted(w)
+	}
+}
+
+// UploadPrices represents the price file upload API handler. The optional
+// format and symbol query params are handed to the importer.
+func UploadPrices(i ingest.Ingester, f factory.Factory, l *logrus.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queryParams := r.URL.Query()
+		format := queryParams.Get("format")
+		symbol := queryParams.Get("symbol")
+
+		u, body, err := uploadBody(w, r)
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("UploadPrices: could not read upload")
+			readError(w, u, err)
+			return
+		}
+
+		job, err := i.Prices(body, format, symbol)
+		if readErr, ok := err.(*ingest.ReadError); ok {
+			l.WithContext(r.Context()).WithError(err).Errorf("UploadPrices: could not read upload")
+			readError(w, u, readErr.Err)
+			return
+		}
+		if formatErr, ok := err.(*importer.FormatError); ok {
+			response.Response{Errors: &response.Error{
+				Reason: fmt.Sprintf("unknown format %q", formatErr.Format),
+				Code:   response.CodeInvalidParam,
+				Field:  "format",
+			}}.ClientError(w)
+			return
+		}
+		if err != nil {
+			l.WithContext(r.Context()).WithError(err).Errorf("UploadPrices: could not queue import")
+			response.Response{Errors: &response.Error{Reason: "could not queue import", Code: response.CodeInternal}}.ServerError(w)
+			return
+		}
+
+		audit.Action(r, job.Kind, map[string]string{"job": job.ID, "format": format, "symbol": symbol})
+
+		response.Response{
+			Success: true,
+			Result:  job,
+		}.Accepted(w)
+	}
+}
+
+// uploadBody returns the request body, limited to maxUploadSize, and the
+// uploaded file: either the "file" part of a multipart form or the body
+// itself.
+func uploadBody(w http.ResponseWriter, r *http.Request) (*upload, io.Reader, error) {
+	u := &upload{ReadCloser: http.MaxBytesReader(w, r.Body, maxUploadSize)}
+	r.Body = u
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return u, u, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return u, nil, fmt.Errorf("invalid multipart body: %s", err)
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return u, nil, fmt.Errorf("multipart body has no file part")
+		}
+		if err != nil {
+			return u, nil, fmt.Errorf("invalid multipart body: %s", err)
+		}
+
+		if part.FormName() == "file" {
+			return u, part, nil
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vikashvverma/stock-backend/importer"
+	"github.com/vikashvverma/stock-backend/ingest"
+	"github.com/vikashvverma/stock-backend/jobs"
+)
+
+type fakeIngester struct {
+	err error
+}
+
+// Companies reads the upload like the ingester spooling it.
+func (f fakeIngester) Companies(r io.Reader) (jobs.Job, error) {
+	if _, err := ioutil.ReadAll(r); err != nil {
+		return jobs.Job{}, &ingest.ReadError{Err: err}
+	}
+
+	return jobs.Job{}, f.err
+}
+
+func (f fakeIngester) Prices(io.Reader, string, string) (jobs.Job, error) {
+	return jobs.Job{}, f.err
+}
+
+func TestUploadPricesErrors(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+
+	for _, tc := range []struct {
+		err  error
+		code int
+		body string
+	}{
+		{&importer.FormatError{Format: "xls"}, http.StatusBadRequest,
+			`{"success":false,"errors":{"reason":"unknown format \"xls\"","code":"invalid_param","field":"format"}}`},
+		{fmt.Errorf("open /tmp/import.prices-1: no space left on device"), http.StatusInternalServerError,
+			`{"success":false,"errors":{"reason":"could not queue import","code":"internal"}}`},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/admin/upload/prices?format=xls", strings.NewReader("date,symbol\n"))
+		w := httptest.NewRecorder()
+
+		UploadPrices(fakeIngester{err: tc.err}, nil, logger)(w, r)
+
+		assert.Equal(t, tc.code, w.Code)
+		assert.JSONEq(t, tc.body, w.Body.String())
+	}
+}
+
+func TestUploadCompaniesReadErrors(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	defer func(size int64) { maxUploadSize = size }(maxUploadSize)
+	maxUploadSize = 1 << 20
+
+	for _, tc := range []struct {
+		body        io.Reader
+		contentType string
+		code        int
+		reason      string
+	}{
+		{strings.NewReader(strings.Repeat("a", 1<<20+1)), "text/csv", http.StatusRequestEntityTooLarge, "file is larger than 1 MiB"},
+		{io.MultiReader(strings.NewReader("Symbol,Name"), iotest.ErrReader(io.ErrUnexpectedEOF)), "text/csv", http.StatusBadRequest, "unexpected EOF"},
+		{strings.NewReader("--x\r\nContent-Disposition: form-data; name=\"file\"\r\n\r\n" + strings.Repeat("a", 1<<20)),
+			"multipart/form-data; boundary=x", http.StatusRequestEntityTooLarge, "file is larger than 1 MiB"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/admin/upload/companies", tc.body)
+		r.Header.Set("Content-Type", tc.contentType)
+		w := httptest.NewRecorder()
+
+		UploadCompanies(fakeIngester{}, nil, logger)(w, r)
+
+		assert.Equal(t, tc.code, w.Code)
+		assert.JSONEq(t, `{"success":false,"errors":{"reason":"`+tc.reason+`","code":"invalid_param","field":"file"}}`, w.Body.String())
+	}
+}
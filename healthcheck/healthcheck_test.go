@@ -17,3 +17,25 @@ func TestHealthCheck(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code, "Invalid HTTP response code")
 	assert.Equal(t, "I am alive", w.Body.String(), "Invalid HTTP response body")
 }
+
+func TestReadiness(t *testing.T) {
+	var readiness Readiness
+
+	for _, tc := range []struct {
+		ready bool
+		code  int
+		body  string
+	}{
+		{false, http.StatusServiceUnavailable, "I am not ready"},
+		{true, http.StatusOK, "I am ready"},
+		{false, http.StatusServiceUnavailable, "I am not ready"},
+	} {
+		readiness.SetReady(tc.ready)
+		w := httptest.NewRecorder()
+
+		readiness.ServeHTTP(w, httptest.NewRequest("GET", "/readiness", nil))
+
+		assert.Equal(t, tc.code, w.Code, "Invalid HTTP response code")
+		assert.Equal(t, tc.body, w.Body.String(), "Invalid HTTP response body")
+	}
+}
@@ -0,0 +1,39 @@
+package healthcheck
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Readiness tells load balancers whether to send requests. It isn't ready
+// until the service is started and stops being ready when it shuts down.
+type Readiness struct {
+	ready int32
+}
+
+// SetReady sets whether the service is ready.
+func (rd *Readiness) SetReady(ready bool) {
+	var v int32
+	if ready {
+		v = 1
+	}
+	atomic.StoreInt32(&rd.ready, v)
+}
+
+// Ready tells whether the service is ready.
+func (rd *Readiness) Ready() bool {
+	return atomic.LoadInt32(&rd.ready) == 1
+}
+
+// ServeHTTP writes a ready message, or a service unavailable one when not
+// ready.
+func (rd *Readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !rd.Ready() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "I am not ready")
+		return
+	}
+
+	io.WriteString(w, "I am ready")
+}
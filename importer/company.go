@@ -0,0 +1,50 @@
+package importer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+// Companies reads a company CSV with the columns
+// Symbol,Name,MarketCap,Sector,Industry.
+func Companies(r io.Reader) ([]stock.Company, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 5
+
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("companies: error reading all lines: %v", err)
+	}
+
+	var companies []stock.Company
+	for i, line := range lines {
+		if i == 0 { //skip header
+			continue
+		}
+
+		symbol := strings.TrimSpace(line[0])
+		if symbol == "" {
+			return nil, fmt.Errorf("companies: missing symbol at line %d", i+1)
+		}
+
+		marketCap, err := strconv.ParseFloat(line[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("companies: unable to parse market cap at line %d: %s", i+1, err)
+		}
+
+		companies = append(companies, stock.Company{
+			Symbol:    symbol,
+			Name:      line[1],
+			MarketCap: marketCap,
+			Sector:    line[3],
+			Industry:  line[4],
+		})
+	}
+
+	return companies, nil
+}
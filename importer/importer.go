@@ -0,0 +1,403 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikashvverma/stock-backend/constants"
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+// Supported price file formats.
+const (
+	// FormatAuto detects the format from the first line of the file.
+	FormatAuto = "auto"
+	// FormatStandard is date,symbol,open,close,low,high,volume by position.
+	FormatStandard = "standard"
+	// FormatYahoo is Date,Open,High,Low,Close,Adj Close,Volume, one file per symbol.
+	FormatYahoo = "yahoo"
+	// FormatStooq is <TICKER>,<PER>,<DATE>,<TIME>,<OPEN>,<HIGH>,<LOW>,<CLOSE>,<VOL>,<OPENINT>.
+	FormatStooq = "stooq"
+	// FormatJSONL is one JSON price point per line.
+	FormatJSONL = "jsonl"
+)
+
+// Importer reads price points from a price file and hands them to fn one by
+// one. Import stops at the first error returned by fn. Rows which can't be
+// parsed, like the null rows Yahoo has for days without trading, are passed
+// to skip as a *RowError and the import goes on; with a nil skip they fail
+// the import.
+type Importer interface {
+	Import(r io.Reader, fn func(stock.PricePoint) error, skip func(error)) error
+}
+
+// FormatError is the error of New for a format without Importer.
+type FormatError struct {
+	Format string
+}
+
+func (e *FormatError) Error() string {
+	return fmt.Sprintf("new: unknown format %q", e.Format)
+}
+
+// RowError is a row of a price file which can't be parsed.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("invalid line %d: %s", e.Line, e.Err)
+}
+
+// skipRow hands the invalid row to skip, or returns it as the import error
+// when rows aren't skipped.
+func skipRow(skip func(error), line int, err error) error {
+	rowErr := &RowError{Line: line, Err: err}
+	if skip == nil {
+		return fmt.Errorf("import: %s", rowErr)
+	}
+
+	skip(rowErr)
+	return nil
+}
+
+var dateLayouts = []string{
+	fmt.Sprintf("%s-%s-%s", constants.StdLongYear, constants.StdZeroMonth, constants.StdZeroDay),
+	fmt.Sprintf("%s%s%s", constants.StdLongYear, constants.StdZeroMonth, constants.StdZeroDay),
+	fmt.Sprintf("%s/%s/%s", constants.StdZeroMonth, constants.StdZeroDay, constants.StdLongYear),
+}
+
+// New returns the Importer for the given format. symbol is used by layouts
+// without a symbol column, e.g. Yahoo which has one file per symbol.
+func New(format, symbol string) (Importer, error) {
+	switch strings.ToLower(format) {
+	case "", FormatAuto:
+		return &autoImporter{symbol: symbol}, nil
+	case FormatStandard:
+		return &csvImporter{columns: map[string]int{
+			"date": 0, "symbol": 1, "open": 2, "close": 3, "low": 4, "high": 5, "volume": 6,
+		}}, nil
+	case FormatYahoo:
+		return &csvImporter{symbol: symbol}, nil
+	case FormatStooq:
+		return &csvImporter{symbol: symbol, stooq: true}, nil
+	case FormatJSONL:
+		return &jsonImporter{symbol: symbol}, nil
+	default:
+		return nil, &FormatError{Format: format}
+	}
+}
+
+// Open opens the file at path, transparently decompressing gzip content.
+func Open(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open: %s", err)
+	}
+
+	r, err := Decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("open: %s: %s", path, err)
+	}
+
+	return &readCloser{Reader: r, closer: file}, nil
+}
+
+// Decompress returns a reader of the uncompressed content of r when r holds
+// gzip data and r itself otherwise.
+func Decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return br, nil
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("decompress: invalid gzip content: %s", err)
+	}
+
+	return gz, nil
+}
+
+// SymbolFromPath derives a symbol from a file name like aapl.csv.gz.
+func SymbolFromPath(path string) string {
+	name := filepath.Base(path)
+	for _, ext := range []string{".gz", ".csv", ".txt", ".jsonl", ".json"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	return strings.ToUpper(name)
+}
+
+type readCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (rc *readCloser) Close() error {
+	return rc.closer.Close()
+}
+
+// autoImporter picks the importer from the first line of the file.
+type autoImporter struct {
+	symbol string
+}
+
+func (a *autoImporter) Import(r io.Reader, fn func(stock.PricePoint) error, skip func(error)) error {
+	br := bufio.NewReader(r)
+	line, err := br.Peek(1)
+	if err != nil {
+		return fmt.Errorf("import: unable to read header: %s", err)
+	}
+
+	if line[0] == '{' {
+		return (&jsonImporter{symbol: a.symbol}).Import(br, fn, skip)
+	}
+
+	return (&csvImporter{symbol: a.symbol}).Import(br, fn, skip)
+}
+
+// csvImporter reads CSV price files. Without fixed columns they are mapped
+// from the header, which makes it work for Yahoo, Stooq and similar layouts.
+type csvImporter struct {
+	columns map[string]int
+	symbol  string
+	stooq   bool
+}
+
+func (c *csvImporter) Import(r io.Reader, fn func(stock.PricePoint) error, skip func(error)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("import: unable to read header: %s", err)
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(header[0]), "<") {
+		c.stooq = true
+	}
+
+	columns := c.columns
+	if columns == nil {
+		columns, err = mapColumns(header, c.symbol != "")
+		if err != nil {
+			return fmt.Errorf("import: %s", err)
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("import: error reading line %d: %s", line, err)
+		}
+
+		p, err := c.pricePoint(columns, record)
+		if err != nil {
+			if err = skipRow(skip, line, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = fn(p)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *csvImporter) pricePoint(columns map[string]int, record []string) (stock.PricePoint, error) {
+	value := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	p := stock.PricePoint{Symbol: c.symbol}
+	if _, ok := columns["symbol"]; ok {
+		p.Symbol = value("symbol")
+	}
+	if c.stooq {
+		p.Symbol = stooqSymbol(p.Symbol)
+	}
+	if p.Symbol == "" {
+		return p, fmt.Errorf("missing symbol")
+	}
+
+	var err error
+	p.Date, err = parseDate(value("date"))
+	if err != nil {
+		return p, err
+	}
+
+	fields := []struct {
+		name  string
+		value *float64
+	}{
+		{"open", &p.Open},
+		{"close", &p.Close},
+		{"low", &p.Low},
+		{"high", &p.High},
+		{"volume", &p.Volume},
+	}
+	for _, f := range fields {
+		v := value(f.name)
+		if v == "" && f.name == "volume" {
+			continue
+		}
+
+		*f.value, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return p, fmt.Errorf("invalid %s %q", f.name, v)
+		}
+	}
+
+	return p, nil
+}
+
+// mapColumns maps the price point fields to their position in header.
+func mapColumns(header []string, hasSymbol bool) (map[string]int, error) {
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		name = strings.NewReplacer("<", "", ">", "", " ", "", "_", "", "\ufeff", "").Replace(name)
+		switch name {
+		case "ticker":
+			name = "symbol"
+		case "vol":
+			name = "volume"
+		}
+
+		if _, ok := columns[name]; !ok {
+			columns[name] = i
+		}
+	}
+
+	var missing []string
+	required := []string{"date", "open", "close", "low", "high"}
+	if !hasSymbol {
+		required = append(required, "symbol")
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("unable to detect format, columns %s not found", strings.Join(missing, ", "))
+	}
+
+	return columns, nil
+}
+
+// jsonImporter reads one JSON price point per line.
+type jsonImporter struct {
+	symbol string
+}
+
+func (j *jsonImporter) Import(r io.Reader, fn func(stock.PricePoint) error, skip func(error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		p, err := j.pricePoint(text)
+		if err != nil {
+			if err = skipRow(skip, line, err); err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = fn(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("import: error reading file: %s", err)
+	}
+
+	return nil
+}
+
+func (j *jsonImporter) pricePoint(text []byte) (stock.PricePoint, error) {
+	var v struct {
+		Date   string  `json:"date"`
+		Symbol string  `json:"symbol"`
+		Open   float64 `json:"open"`
+		Close  float64 `json:"close"`
+		Low    float64 `json:"low"`
+		High   float64 `json:"high"`
+		Volume float64 `json:"volume"`
+	}
+	err := json.Unmarshal(text, &v)
+	if err != nil {
+		return stock.PricePoint{}, err
+	}
+
+	p := stock.PricePoint{Symbol: strings.TrimSpace(v.Symbol), Open: v.Open, Close: v.Close, Low: v.Low, High: v.High, Volume: v.Volume}
+	if p.Symbol == "" {
+		p.Symbol = j.symbol
+	}
+	if p.Symbol == "" {
+		return p, fmt.Errorf("missing symbol")
+	}
+
+	p.Date, err = parseDate(v.Date)
+
+	return p, err
+}
+
+// parseDate parses the date part of value, ignoring any time of day which
+// the daily price files carry as 00:00:00.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Truncate(24 * time.Hour), nil
+	}
+
+	day := strings.Split(value, " ")[0]
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, day)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+// stooqSymbol strips the market suffix of Stooq tickers like AAPL.US.
+func stooqSymbol(s string) string {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if i := strings.LastIndex(s, "."); i > 0 && len(s)-i == 3 {
+		return s[:i]
+	}
+
+	return s
+}
@@ -0,0 +1,208 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+func collect(t *testing.T, im Importer, content string) []stock.PricePoint {
+	var pp []stock.PricePoint
+	err := im.Import(strings.NewReader(content), func(p stock.PricePoint) error {
+		pp = append(pp, p)
+		return nil
+	}, nil)
+	require.NoError(t, err, "Expected no error importing")
+
+	return pp
+}
+
+func TestImportStandard(t *testing.T) {
+	im, err := New(FormatStandard, "")
+	require.NoError(t, err, "Expected no error")
+
+	pp := collect(t, im, "date,symbol,open,close,low,high,volume\n"+
+		"2016-01-05 00:00:00,WLTW,123.43,125.839996,122.309998,126.25,2163600.0\n")
+
+	require.Len(t, pp, 1)
+	assert.Equal(t, stock.PricePoint{
+		Date:   time.Date(2016, 1, 5, 0, 0, 0, 0, time.UTC),
+		Symbol: "WLTW",
+		Open:   123.43,
+		Close:  125.839996,
+		Low:    122.309998,
+		High:   126.25,
+		Volume: 2163600,
+	}, pp[0])
+}
+
+func TestImportAutoYahoo(t *testing.T) {
+	im, err := New(FormatAuto, "AAPL")
+	require.NoError(t, err, "Expected no error")
+
+	pp := collect(t, im, "Date,Open,High,Low,Close,Adj Close,Volume\n"+
+		"2019-01-02,154.889999,158.850006,154.229996,157.919998,155.214005,37039700\n")
+
+	require.Len(t, pp, 1)
+	assert.Equal(t, "AAPL", pp[0].Symbol)
+	assert.Equal(t, time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC), pp[0].Date)
+	assert.Equal(t, 154.889999, pp[0].Open)
+	assert.Equal(t, 158.850006, pp[0].High)
+	assert.Equal(t, 154.229996, pp[0].Low)
+	assert.Equal(t, 157.919998, pp[0].Close)
+	assert.Equal(t, float64(37039700), pp[0].Volume)
+}
+
+func TestImportAutoStooq(t *testing.T) {
+	im, err := New(FormatAuto, "")
+	require.NoError(t, err, "Expected no error")
+
+	pp := collect(t, im, "<TICKER>,<PER>,<DATE>,<TIME>,<OPEN>,<HIGH>,<LOW>,<CLOSE>,<VOL>,<OPENINT>\n"+
+		"AAPL.US,D,20190102,000000,154.89,158.85,154.23,157.92,37039700,0\n")
+
+	require.Len(t, pp, 1)
+	assert.Equal(t, "AAPL", pp[0].Symbol)
+	assert.Equal(t, time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC), pp[0].Date)
+	assert.Equal(t, 157.92, pp[0].Close)
+	assert.Equal(t, float64(37039700), pp[0].Volume)
+}
+
+func TestImportAutoJSONL(t *testing.T) {
+	im, err := New(FormatAuto, "")
+	require.NoError(t, err, "Expected no error")
+
+	pp := collect(t, im, `{"date":"2019-01-02","symbol":"MSFT","open":99.55,"close":101.12,"low":98.94,"high":101.75,"volume":35329300}`+"\n\n"+
+		`{"date":"2019-01-03T00:00:00Z","symbol":"MSFT","open":100.1,"close":97.4,"low":97.2,"high":100.19,"volume":42579100}`+"\n")
+
+	require.Len(t, pp, 2)
+	assert.Equal(t, "MSFT", pp[0].Symbol)
+	assert.Equal(t, 101.12, pp[0].Close)
+	assert.Equal(t, time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC), pp[1].Date)
+}
+
+func TestImportFailsWhenColumnsMissing(t *testing.T) {
+	im, err := New(FormatAuto, "")
+	require.NoError(t, err, "Expected no error")
+
+	err = im.Import(strings.NewReader("foo,bar\n1,2\n"), func(stock.PricePoint) error { return nil }, nil)
+	require.Error(t, err, "Expected an error")
+
+	assert.Equal(t, "import: unable to detect format, columns date, open, close, low, high, symbol not found", err.Error())
+}
+
+func TestImportFailsWhenValueInvalid(t *testing.T) {
+	im, err := New(FormatStandard, "")
+	require.NoError(t, err, "Expected no error")
+
+	err = im.Import(strings.NewReader("date,symbol,open,close,low,high,volume\n2016-01-05,WLTW,foo,1,1,1,1\n"),
+		func(stock.PricePoint) error { return nil }, nil)
+	require.Error(t, err, "Expected an error")
+
+	assert.Equal(t, `import: invalid line 2: invalid open "foo"`, err.Error())
+}
+
+func TestImportSkipsInvalidRows(t *testing.T) {
+	for _, tc := range []struct {
+		format  string
+		content string
+		skipped []int
+	}{
+		{FormatYahoo, "Date,Open,High,Low,Close,Adj Close,Volume\n" +
+			"2019-01-02,154.89,158.85,154.23,157.92,155.21,37039700\n" +
+			"2019-01-05,null,null,null,null,null,null\n" +
+			"2019-01-07,148.7,148.83,145.9,147.93,145.51,54777800\n", []int{3}},
+		{FormatJSONL, `{"date":"2019-01-02","open":1,"close":1,"low":1,"high":1}` + "\n" +
+			`{"date":null,"open":null}` + "\n" + `not json` + "\n" +
+			`{"date":"2019-01-07","open":1,"close":1,"low":1,"high":1}` + "\n", []int{2, 3}},
+	} {
+		im, err := New(tc.format, "AAPL")
+		require.NoError(t, err, "Expected no error")
+
+		var pp []stock.PricePoint
+		var skipped []int
+		err = im.Import(strings.NewReader(tc.content), func(p stock.PricePoint) error {
+			pp = append(pp, p)
+			return nil
+		}, func(err error) {
+			skipped = append(skipped, err.(*RowError).Line)
+		})
+		require.NoError(t, err, tc.format)
+
+		require.Len(t, pp, 2, tc.format)
+		assert.Equal(t, time.Date(2019, 1, 7, 0, 0, 0, 0, time.UTC), pp[1].Date, tc.format)
+		assert.Equal(t, tc.skipped, skipped, tc.format)
+	}
+}
+
+func TestImportStopsOnCallbackError(t *testing.T) {
+	im, err := New(FormatStandard, "")
+	require.NoError(t, err, "Expected no error")
+
+	err = im.Import(strings.NewReader("date,symbol,open,close,low,high,volume\n2016-01-05,WLTW,1,1,1,1,1\n"),
+		func(stock.PricePoint) error { return fmt.Errorf("stop") }, nil)
+
+	assert.EqualError(t, err, "stop")
+}
+
+func TestNewFailsWhenFormatUnknown(t *testing.T) {
+	_, err := New("xls", "")
+
+	assert.EqualError(t, err, `new: unknown format "xls"`)
+}
+
+func TestDecompress(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte("Date,Open,High,Low,Close,Volume\n"))
+	require.NoError(t, err, "Expected no error compressing")
+	require.NoError(t, gz.Close(), "Expected no error closing gzip writer")
+
+	r, err := Decompress(&buf)
+	require.NoError(t, err, "Expected no error")
+
+	content := new(bytes.Buffer)
+	_, err = content.ReadFrom(r)
+	require.NoError(t, err, "Expected no error reading")
+	assert.Equal(t, "Date,Open,High,Low,Close,Volume\n", content.String())
+
+	r, err = Decompress(strings.NewReader("plain"))
+	require.NoError(t, err, "Expected no error")
+
+	content.Reset()
+	_, err = content.ReadFrom(r)
+	require.NoError(t, err, "Expected no error reading")
+	assert.Equal(t, "plain", content.String())
+}
+
+func TestSymbolFromPath(t *testing.T) {
+	assert.Equal(t, "AAPL", SymbolFromPath("/data/aapl.csv"))
+	assert.Equal(t, "MSFT", SymbolFromPath("MSFT.csv.gz"))
+	assert.Equal(t, "IBM.US", SymbolFromPath("ibm.us.txt"))
+}
+
+func TestCompanies(t *testing.T) {
+	companies, err := Companies(strings.NewReader("Symbol,Name,MarketCap,Sector,Industry\n" +
+		`PIH,"1347 Property Insurance Holdings, Inc.",42903835.2,Finance,Property-Casualty Insurers` + "\n" +
+		"GOOG,Alphabet Inc.,5.73243E+11,Technology,Computer Software\n"))
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, []stock.Company{
+		{Symbol: "PIH", Name: "1347 Property Insurance Holdings, Inc.", MarketCap: 42903835.2, Sector: "Finance", Industry: "Property-Casualty Insurers"},
+		{Symbol: "GOOG", Name: "Alphabet Inc.", MarketCap: 5.73243e+11, Sector: "Technology", Industry: "Computer Software"},
+	}, companies)
+}
+
+func TestCompaniesFailsWhenMarketCapInvalid(t *testing.T) {
+	_, err := Companies(strings.NewReader("Symbol,Name,MarketCap,Sector,Industry\nPIH,Foo,bar,Finance,Insurers\n"))
+	require.Error(t, err, "Expected an error")
+
+	assert.Contains(t, err.Error(), "companies: unable to parse market cap at line 2:")
+}
@@ -0,0 +1,128 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/vikashvverma/stock-backend/importer"
+	"github.com/vikashvverma/stock-backend/jobs"
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+// batchSize is the number of price points saved at once.
+const batchSize = 1000
+
+// maxRowErrors is the number of errors of skipped rows kept in a Result.
+const maxRowErrors = 20
+
+// Result counts the rows read from a file, the rows skipped as they can't be
+// parsed and the documents written.
+type Result struct {
+	Rows    int64 `json:"rows"`
+	Skipped int64 `json:"skipped,omitempty"`
+	Saved   int64 `json:"saved"`
+	Symbols int64 `json:"symbols,omitempty"`
+	// Errors are the errors of the first skipped rows, with their line.
+	Errors []string `json:"errors,omitempty"`
+}
+
+func (r Result) progress() map[string]int64 {
+	return map[string]int64{"rows": r.Rows, "skipped": r.Skipped, "saved": r.Saved, "symbols": r.Symbols}
+}
+
+// Companies reads a company CSV from r and saves the companies. Errors in
+// the file are marked permanent with jobs.Permanent.
+func Companies(ctx context.Context, l stock.Loader, r io.Reader) (Result, error) {
+	var res Result
+
+	companies, err := importer.Companies(r)
+	if err != nil {
+		return res, jobs.Permanent(err)
+	}
+	res.Rows = int64(len(companies))
+
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+
+	res.Saved, err = l.SaveCompanies(companies)
+	if err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// Prices reads the price points of r with im and saves them in batches,
+// adding a company for every symbol which has none. Rows which can't be
+// parsed are skipped and counted, the errors of the first ones are kept in
+// the Result. progress, if not nil, is called after
+// every batch. Errors in the file are marked permanent with jobs.Permanent.
+func Prices(ctx context.Context, l stock.Loader, im importer.Importer, r io.Reader, progress func(Result)) (Result, error) {
+	var res Result
+
+	var batch []stock.PricePoint
+	var saveErr error
+	symbols := map[string]bool{}
+	flush := func() error {
+		n, err := l.SavePrices(batch)
+		res.Saved += n
+		if err != nil {
+			saveErr = err
+			return err
+		}
+
+		batch = batch[:0]
+		if progress != nil {
+			progress(res)
+		}
+		return nil
+	}
+
+	err := im.Import(r, func(p stock.PricePoint) error {
+		if err := ctx.Err(); err != nil {
+			saveErr = err
+			return err
+		}
+
+		res.Rows++
+		symbols[p.Symbol] = true
+
+		batch = append(batch, p)
+		if len(batch) < batchSize {
+			return nil
+		}
+		return flush()
+	}, func(err error) {
+		res.Skipped++
+		if len(res.Errors) < maxRowErrors {
+			res.Errors = append(res.Errors, err.Error())
+		}
+	})
+	if err != nil && err == saveErr {
+		return res, err
+	}
+	if err != nil {
+		return res, jobs.Permanent(err)
+	}
+
+	if len(batch) > 0 {
+		err = flush()
+		if err != nil {
+			return res, err
+		}
+	}
+
+	var list []string
+	for symbol := range symbols {
+		list = append(list, symbol)
+	}
+
+	res.Symbols, err = l.AddSymbols(list)
+	if err != nil {
+		return res, fmt.Errorf("prices: %s", err)
+	}
+
+	return res, nil
+}
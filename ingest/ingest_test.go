@@ -0,0 +1,232 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/importer"
+	"github.com/vikashvverma/stock-backend/jobs"
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+type fakeLoader struct {
+	companies []stock.Company
+	prices    []stock.PricePoint
+	symbols   []string
+	err       error
+}
+
+func (f *fakeLoader) SaveCompanies(c []stock.Company) (int64, error) {
+	f.companies = append(f.companies, c...)
+	return int64(len(c)), nil
+}
+
+func (f *fakeLoader) SavePrices(p []stock.PricePoint) (int64, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.prices = append(f.prices, p...)
+	return int64(len(p)), nil
+}
+
+func (f *fakeLoader) AddSymbols(s []string) (int64, error) {
+	f.symbols = append(f.symbols, s...)
+	return int64(len(s)), nil
+}
+
+func (f *fakeLoader) ConvertLegacy() (int64, error) {
+	return 0, nil
+}
+
+const prices = "date,symbol,open,close,low,high,volume\n" +
+	"2016-01-05,WLTW,1,2,1,2,100\n" +
+	"2016-01-06,WLTW,2,3,2,3,100\n"
+
+func TestPrices(t *testing.T) {
+	l := &fakeLoader{}
+	im, err := importer.New(importer.FormatAuto, "")
+	require.NoError(t, err, "Expected no error")
+
+	res, err := Prices(context.Background(), l, im, strings.NewReader(prices), nil)
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, Result{Rows: 2, Saved: 2, Symbols: 1}, res)
+	assert.Len(t, l.prices, 2)
+	assert.Equal(t, []string{"WLTW"}, l.symbols)
+}
+
+func TestPricesSkipsInvalidRows(t *testing.T) {
+	l := &fakeLoader{}
+	im, err := importer.New(importer.FormatStandard, "")
+	require.NoError(t, err, "Expected no error")
+
+	res, err := Prices(context.Background(), l, im, strings.NewReader(prices+"2016-01-07,WLTW,null,null,null,null,null\n"), nil)
+	require.NoError(t, err, "Expected no error")
+
+	assert.Equal(t, Result{Rows: 2, Skipped: 1, Saved: 2, Symbols: 1, Errors: []string{`invalid line 4: invalid open "null"`}}, res)
+	assert.Len(t, l.prices, 2)
+}
+
+func TestPricesMarksFileErrorsPermanent(t *testing.T) {
+	im, err := importer.New(importer.FormatStandard, "")
+	require.NoError(t, err, "Expected no error")
+
+	_, err = Prices(context.Background(), &fakeLoader{}, im, strings.NewReader("date,symbol\n\"2016-01-07\n"), nil)
+	require.Error(t, err, "Expected an error")
+	assert.True(t, jobs.IsPermanent(err))
+
+	_, err = Prices(context.Background(), &fakeLoader{err: fmt.Errorf("no server")}, im, strings.NewReader(prices), nil)
+	require.Error(t, err, "Expected an error")
+	assert.False(t, jobs.IsPermanent(err))
+}
+
+func TestIngesterPrices(t *testing.T) {
+	run, i := newIngester(&fakeLoader{})
+	defer run.Stop()
+
+	job, err := i.Prices(strings.NewReader(prices), "", "")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, KindPrices, job.Kind)
+
+	j := waitFor(t, run, job.ID, jobs.StatusSucceeded)
+	assert.Equal(t, int64(2), j.Progress["rows"])
+}
+
+func TestIngesterLogsSkippedRows(t *testing.T) {
+	run, i := newIngester(&fakeLoader{})
+	defer run.Stop()
+
+	job, err := i.Prices(strings.NewReader(prices+strings.Repeat("2016-01-07,WLTW,null,null,null,null,null\n", 25)), "", "")
+	require.NoError(t, err, "Expected no error")
+
+	j := waitFor(t, run, job.ID, jobs.StatusSucceeded)
+	assert.Equal(t, int64(25), j.Progress["skipped"])
+
+	var skipped []string
+	for _, line := range j.Logs {
+		// lines start with their time.
+		if message := line[strings.Index(line, " ")+1:]; strings.HasPrefix(message, "skipped ") {
+			skipped = append(skipped, message)
+		}
+	}
+	require.Len(t, skipped, 21)
+	assert.Equal(t, `skipped invalid line 4: invalid open "null"`, skipped[0])
+	assert.Equal(t, `skipped invalid line 23: invalid open "null"`, skipped[19])
+	assert.Equal(t, "skipped 5 more invalid rows", skipped[20])
+}
+
+func TestIngesterRemovesFileOfDoneJob(t *testing.T) {
+	run, i := newIngester(&fakeLoader{})
+	defer run.Stop()
+
+	job, err := i.Prices(strings.NewReader(prices), "", "")
+	require.NoError(t, err, "Expected no error")
+
+	waitFor(t, run, job.ID, jobs.StatusSucceeded)
+	// the file is removed right after the job is saved.
+	deadline := time.Now().Add(time.Second)
+	for _, err = os.Stat(job.Payload["file"]); err == nil && time.Now().Before(deadline); _, err = os.Stat(job.Payload["file"]) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.True(t, os.IsNotExist(err), "Expected the file to be removed")
+}
+
+func TestIngesterRemovesFileOfCanceledJob(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	run := jobs.New(jobs.NewMemoryStore(), 1, logger)
+	i := New(&fakeLoader{}, run, NewFileSpool(""), logger)
+
+	job, err := i.Companies(strings.NewReader("Symbol,Name,MarketCap,Sector,Industry\n"))
+	require.NoError(t, err, "Expected no error")
+	require.FileExists(t, job.Payload["file"])
+
+	_, err = run.Cancel(job.ID)
+	require.NoError(t, err, "Expected no error")
+	_, err = os.Stat(job.Payload["file"])
+	assert.True(t, os.IsNotExist(err), "Expected the file to be removed")
+}
+
+func TestIngesterFailsWhenFileInvalid(t *testing.T) {
+	run, i := newIngester(&fakeLoader{})
+	defer run.Stop()
+
+	job, err := i.Companies(strings.NewReader("Symbol,Name,MarketCap,Sector,Industry\nPIH,Foo,bar,Finance,Insurers\n"))
+	require.NoError(t, err, "Expected no error")
+
+	j := waitFor(t, run, job.ID, jobs.StatusFailed)
+	assert.Equal(t, 1, j.Attempts)
+	assert.Contains(t, j.Error, "unable to parse market cap at line 2")
+}
+
+func TestIngesterFailsWhenFormatUnknown(t *testing.T) {
+	run, i := newIngester(&fakeLoader{})
+	defer run.Stop()
+
+	_, err := i.Prices(strings.NewReader(prices), "xls", "")
+
+	assert.IsType(t, &importer.FormatError{}, err)
+	assert.EqualError(t, err, `new: unknown format "xls"`)
+}
+
+func TestIngesterFailsWhenUploadCutShort(t *testing.T) {
+	run, i := newIngester(&fakeLoader{})
+	defer run.Stop()
+
+	_, err := i.Companies(io.MultiReader(strings.NewReader("Symbol,Name"), iotest.ErrReader(io.ErrUnexpectedEOF)))
+
+	require.IsType(t, &ReadError{}, err)
+	assert.Equal(t, io.ErrUnexpectedEOF, err.(*ReadError).Err)
+}
+
+func TestFileSpool(t *testing.T) {
+	spool := NewFileSpool("")
+
+	id, err := spool.Create(KindPrices, strings.NewReader(prices))
+	require.NoError(t, err, "Expected no error")
+
+	file, err := spool.Open(id)
+	require.NoError(t, err, "Expected no error")
+	content, err := ioutil.ReadAll(file)
+	file.Close()
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, prices, string(content))
+
+	require.NoError(t, spool.Remove(id))
+	require.NoError(t, spool.Remove(id), "Expected no error removing a removed file")
+	_, err = spool.Open(id)
+	assert.Equal(t, ErrNoFile, err)
+}
+
+func newIngester(l stock.Loader) (jobs.Runner, Ingester) {
+	logger, _ := test.NewNullLogger()
+	run := jobs.New(jobs.NewMemoryStore(), 1, logger)
+	i := New(l, run, NewFileSpool(""), logger)
+	run.Start()
+
+	return run, i
+}
+
+func waitFor(t *testing.T, run jobs.Runner, id, status string) jobs.Job {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		j, err := run.Job(id)
+		if err == nil && j.Status == status {
+			return j
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("job %s did not reach status %s", id, status)
+	return jobs.Job{}
+}
@@ -0,0 +1,142 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/importer"
+	"github.com/vikashvverma/stock-backend/jobs"
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+// Job kinds.
+const (
+	KindCompanies = "import.companies"
+	KindPrices    = "import.prices"
+)
+
+// retry is the retry policy of import jobs, only failures to save are retried.
+var retry = jobs.Retry{Attempts: 3, Backoff: 10 * time.Second}
+
+// Ingester imports uploaded company and price files as background jobs.
+type Ingester interface {
+	Companies(r io.Reader) (jobs.Job, error)
+	Prices(r io.Reader, format, symbol string) (jobs.Job, error)
+}
+
+type ingester struct {
+	loader stock.Loader
+	runner jobs.Runner
+	logger *logrus.Logger
+	spool  Spool
+}
+
+// New returns an Ingester which keeps uploads in spool and imports them with
+// runner. It registers the import job kinds with runner.
+func New(loader stock.Loader, runner jobs.Runner, spool Spool, l *logrus.Logger) Ingester {
+	i := &ingester{loader: loader, runner: runner, logger: l, spool: spool}
+
+	runner.Register(KindCompanies, i.importCompanies, retry)
+	runner.Register(KindPrices, i.importPrices, retry)
+	runner.OnDone(KindCompanies, i.remove)
+	runner.OnDone(KindPrices, i.remove)
+
+	return i
+}
+
+// Companies queues the import of a company CSV. An error reading r is a
+// *ReadError.
+func (i *ingester) Companies(r io.Reader) (jobs.Job, error) {
+	return i.submit(KindCompanies, r, map[string]string{})
+}
+
+// Prices queues the import of a price file in the given format, an unknown
+// format is an *importer.FormatError and an error reading r a *ReadError.
+func (i *ingester) Prices(r io.Reader, format, symbol string) (jobs.Job, error) {
+	_, err := importer.New(format, symbol)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+
+	return i.submit(KindPrices, r, map[string]string{"format": format, "symbol": symbol})
+}
+
+func (i *ingester) submit(kind string, r io.Reader, payload map[string]string) (jobs.Job, error) {
+	file, err := i.spool.Create(kind, r)
+	if err != nil {
+		return jobs.Job{}, err
+	}
+	payload["file"] = file
+
+	j, err := i.runner.Submit(kind, payload)
+	if err != nil {
+		i.spool.Remove(file)
+		return j, err
+	}
+
+	return j, nil
+}
+
+func (i *ingester) importCompanies(ctx context.Context, t *jobs.Task) error {
+	return i.importFile(ctx, t, func(r io.Reader) (Result, error) {
+		return Companies(ctx, i.loader, r)
+	})
+}
+
+func (i *ingester) importPrices(ctx context.Context, t *jobs.Task) error {
+	im, err := importer.New(t.Payload("format"), t.Payload("symbol"))
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	return i.importFile(ctx, t, func(r io.Reader) (Result, error) {
+		return Prices(ctx, i.loader, im, r, func(res Result) {
+			t.Progress(res.progress())
+		})
+	})
+}
+
+// remove removes the spooled file of a job which is done, whether it
+// succeeded, failed or was canceled.
+func (i *ingester) remove(j jobs.Job) {
+	err := i.spool.Remove(j.Payload["file"])
+	if err != nil {
+		i.logger.WithError(err).Errorf("Ingester: unable to remove the file of job %s", j.ID)
+	}
+}
+
+// importFile runs fn on the spooled file of the job. The file is kept for
+// the next attempts and removed once the job is done.
+func (i *ingester) importFile(ctx context.Context, t *jobs.Task, fn func(io.Reader) (Result, error)) error {
+	file, err := i.spool.Open(t.Payload("file"))
+	if err == ErrNoFile {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r, err := importer.Decompress(file)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	res, err := fn(r)
+	t.Progress(res.progress())
+	for _, rowErr := range res.Errors {
+		t.Logf("skipped %s", rowErr)
+	}
+	if more := res.Skipped - int64(len(res.Errors)); more > 0 {
+		t.Logf("skipped %d more invalid rows", more)
+	}
+	if err != nil {
+		return err
+	}
+
+	t.Logf("imported %d rows, skipped %d invalid rows", res.Rows, res.Skipped)
+	return nil
+}
@@ -0,0 +1,183 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNoFile is returned by Open for a file which was removed.
+var ErrNoFile = errors.New("spooled file not found")
+
+// ReadError is an error reading an upload while spooling it, like a body
+// cut short, as opposed to an error writing the spooled file.
+type ReadError struct {
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("spool: unable to read upload: %s", e.Err)
+}
+
+// Spool keeps the uploaded files so the upload request can return before
+// the import is done and the import can be retried.
+type Spool interface {
+	// Create copies r to a new file and returns its id, an error reading r
+	// is a *ReadError.
+	Create(kind string, r io.Reader) (string, error)
+	// Open opens the file with the given id.
+	Open(id string) (io.ReadCloser, error)
+	// Remove removes the file with the given id, if it is still there.
+	Remove(id string) error
+}
+
+type fileSpool struct {
+	dir string
+}
+
+// NewFileSpool returns a Spool keeping the files in dir, the system
+// temporary directory when empty. Only the instance which received a file
+// can import it.
+func NewFileSpool(dir string) Spool {
+	return &fileSpool{dir: dir}
+}
+
+func (s *fileSpool) Create(kind string, r io.Reader) (string, error) {
+	file, err := ioutil.TempFile(s.dir, kind+"-")
+	if err != nil {
+		return "", fmt.Errorf("create: unable to create file: %s", err)
+	}
+	defer file.Close()
+
+	src := &errReader{r: r}
+	_, err = io.Copy(file, src)
+	if src.err != nil {
+		os.Remove(file.Name())
+		return "", &ReadError{Err: src.err}
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("create: unable to write file: %s", err)
+	}
+
+	return file.Name(), nil
+}
+
+func (s *fileSpool) Open(id string) (io.ReadCloser, error) {
+	file, err := os.Open(id)
+	if os.IsNotExist(err) {
+		return nil, ErrNoFile
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open: %s", err)
+	}
+
+	return file, nil
+}
+
+func (s *fileSpool) Remove(id string) error {
+	err := os.Remove(id)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove: %s", err)
+	}
+
+	return nil
+}
+
+type gridFSSpool struct {
+	DB     *mongo.Database
+	Bucket string
+}
+
+// NewGridFSSpool returns a Spool keeping the files in the GridFS bucket of
+// db, every instance can import them.
+func NewGridFSSpool(db *mongo.Database, bucket string) Spool {
+	return &gridFSSpool{DB: db, Bucket: bucket}
+}
+
+// bucket returns a new gridfs.Bucket, they can't be shared by concurrent
+// uploads.
+func (s *gridFSSpool) bucket() (*gridfs.Bucket, error) {
+	return gridfs.NewBucket(s.DB, options.GridFSBucket().SetName(s.Bucket))
+}
+
+func (s *gridFSSpool) Create(kind string, r io.Reader) (string, error) {
+	b, err := s.bucket()
+	if err != nil {
+		return "", fmt.Errorf("create: %s", err)
+	}
+
+	// the upload is aborted on an error reading r.
+	src := &errReader{r: r}
+	id, err := b.UploadFromStream(kind, src)
+	if src.err != nil {
+		return "", &ReadError{Err: src.err}
+	}
+	if err != nil {
+		return "", fmt.Errorf("create: unable to upload file: %s", err)
+	}
+
+	return id.Hex(), nil
+}
+
+func (s *gridFSSpool) Open(id string) (io.ReadCloser, error) {
+	fileID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrNoFile
+	}
+	b, err := s.bucket()
+	if err != nil {
+		return nil, fmt.Errorf("open: %s", err)
+	}
+
+	stream, err := b.OpenDownloadStream(fileID)
+	if err == gridfs.ErrFileNotFound {
+		return nil, ErrNoFile
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open: unable to download file %s: %s", id, err)
+	}
+
+	return stream, nil
+}
+
+func (s *gridFSSpool) Remove(id string) error {
+	fileID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil
+	}
+	b, err := s.bucket()
+	if err != nil {
+		return fmt.Errorf("remove: %s", err)
+	}
+
+	err = b.Delete(fileID)
+	if err != nil && err != gridfs.ErrFileNotFound {
+		return fmt.Errorf("remove: unable to delete file %s: %s", id, err)
+	}
+
+	return nil
+}
+
+// errReader keeps the error reading r, io.Copy doesn't tell it from the
+// write errors.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (e *errReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF {
+		e.err = err
+	}
+
+	return n, err
+}
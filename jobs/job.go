@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Job statuses.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+)
+
+// maxLogs is the number of log lines kept per job.
+const maxLogs = 100
+
+// ErrNotFound is returned for an unknown job id.
+var ErrNotFound = errors.New("job not found")
+
+// ErrNotOwned is returned by Save for a job which was requeued after the
+// lease of its runner expired, another runner may be running it.
+var ErrNotOwned = errors.New("job no longer owned by its runner")
+
+// StatusError is returned when canceling a job which is already done.
+type StatusError struct {
+	ID     string
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("cancel: job %s is %s", e.ID, e.Status)
+}
+
+// Job is a persisted unit of background work.
+type Job struct {
+	ID          string            `json:"id" bson:"_id"`
+	Kind        string            `json:"kind"`
+	Status      string            `json:"status"`
+	Payload     map[string]string `json:"payload,omitempty"`
+	Progress    map[string]int64  `json:"progress,omitempty"`
+	Logs        []string          `json:"logs,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Attempts    int               `json:"attempts"`
+	MaxAttempts int               `json:"maxAttempts"`
+	Created     time.Time         `json:"created"`
+	Updated     time.Time         `json:"updated"`
+	NotBefore   time.Time         `json:"notBefore,omitempty"`
+	Started     *time.Time        `json:"started,omitempty"`
+	Finished    *time.Time        `json:"finished,omitempty"`
+	// Runner is the id of the runner which claimed the job last.
+	Runner string `json:"runner,omitempty"`
+	// Heartbeat is when the runner of a running job last renewed its lease.
+	Heartbeat *time.Time `json:"heartbeat,omitempty"`
+	// CancelRequested asks the runner of a running job to cancel it.
+	CancelRequested bool `json:"cancelRequested,omitempty"`
+}
+
+// Done tells whether the job reached a final status.
+func (j Job) Done() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCanceled
+}
+
+// lastAttempt tells whether the job has no attempt left.
+func (j Job) lastAttempt() bool {
+	return j.Attempts >= j.MaxAttempts
+}
+
+// expire fails a job whose runner stopped renewing its lease on the last
+// attempt, e.g. as the job crashed the process.
+func (j *Job) expire(now time.Time) {
+	j.Status = StatusFailed
+	j.Error = fmt.Sprintf("lease expired on attempt %d of %d", j.Attempts, j.MaxAttempts)
+	j.Finished = &now
+}
+
+func (j *Job) log(line string) {
+	j.Logs = append(j.Logs, logLine(line))
+	if len(j.Logs) > maxLogs {
+		j.Logs = j.Logs[len(j.Logs)-maxLogs:]
+	}
+}
+
+// logLine returns line prefixed with the current time.
+func logLine(line string) string {
+	return time.Now().UTC().Format(time.RFC3339) + " " + line
+}
+
+// Retry is the retry policy of a job kind.
+type Retry struct {
+	// Attempts is the number of times a job is run before it fails.
+	Attempts int
+	// Backoff is the delay before the second attempt, doubled for every
+	// further attempt.
+	Backoff time.Duration
+}
+
+func (r Retry) delay(attempt int) time.Duration {
+	d := r.Backoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+	}
+
+	return d
+}
+
+type permanent struct {
+	err error
+}
+
+func (p permanent) Error() string {
+	return p.err.Error()
+}
+
+// Permanent marks err as not worth retrying, e.g. invalid input.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return permanent{err: err}
+}
+
+// IsPermanent tells whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	_, ok := err.(permanent)
+	return ok
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,551 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pollInterval is how often idle workers look for queued jobs, a submitted
+// job wakes a worker right away.
+const pollInterval = time.Second
+
+// heartbeatInterval is how often a runner renews the lease of its running
+// jobs, which are requeued by the other runners once the lease expires
+// after leaseTimeout.
+const (
+	heartbeatInterval = 10 * time.Second
+	leaseTimeout      = time.Minute
+)
+
+// Handler runs a job of one kind. It should return once ctx is done.
+type Handler func(ctx context.Context, t *Task) error
+
+// Runner runs jobs with a pool of workers.
+type Runner interface {
+	Register(kind string, h Handler, r Retry)
+	OnDone(kind string, fn func(Job))
+	Submit(kind string, payload map[string]string) (Job, error)
+	Job(id string) (Job, error)
+	Jobs(status string, limit int64) ([]Job, error)
+	Cancel(id string) (Job, error)
+	Stats() Stats
+	Start()
+	Stop()
+}
+
+// Job events counted by Stats besides the final statuses.
+const (
+	EventSubmitted = "submitted"
+	EventRetried   = "retried"
+)
+
+// Stats are the counters of the jobs since the start.
+type Stats struct {
+	// Running is the number of jobs being run.
+	Running int
+	// Counts are the number of jobs per kind and event, submitted, retried
+	// or a final status.
+	Counts map[string]map[string]int64
+}
+
+type kind struct {
+	handler Handler
+	retry   Retry
+}
+
+type runner struct {
+	id      string
+	store   Store
+	logger  *logrus.Logger
+	workers int
+
+	mu      sync.Mutex
+	kinds   map[string]kind
+	done    map[string]func(Job)
+	running map[string]context.CancelFunc
+	counts  map[string]map[string]int64
+
+	wake chan struct{}
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New returns a Runner keeping its jobs in store and running them with the
+// given number of workers.
+func New(store Store, workers int, l *logrus.Logger) Runner {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &runner{
+		id:      runnerID(),
+		store:   store,
+		logger:  l,
+		workers: workers,
+		kinds:   map[string]kind{},
+		done:    map[string]func(Job){},
+		running: map[string]context.CancelFunc{},
+		counts:  map[string]map[string]int64{},
+		wake:    make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+	}
+}
+
+// runnerID identifies the runner in the jobs it claims.
+func runnerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "runner"
+	}
+
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), newID()[:8])
+}
+
+// Register sets the handler and retry policy for jobs of the given kind.
+func (r *runner) Register(k string, h Handler, retry Retry) {
+	if retry.Attempts < 1 {
+		retry.Attempts = 1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kinds[k] = kind{handler: h, retry: retry}
+}
+
+// OnDone sets the function called with the jobs of the given kind once they
+// are done, whatever their status, e.g. to remove the files of their
+// payload. It is called by the runner which finished or canceled the job.
+func (r *runner) OnDone(k string, fn func(Job)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done[k] = fn
+}
+
+// Submit queues a job of the given kind.
+func (r *runner) Submit(k string, payload map[string]string) (Job, error) {
+	r.mu.Lock()
+	kd, ok := r.kinds[k]
+	r.mu.Unlock()
+	if !ok {
+		return Job{}, fmt.Errorf("submit: unknown job kind %q", k)
+	}
+
+	now := time.Now()
+	j := Job{
+		ID:          newID(),
+		Kind:        k,
+		Status:      StatusQueued,
+		Payload:     payload,
+		MaxAttempts: kd.retry.Attempts,
+		Created:     now,
+		Updated:     now,
+		NotBefore:   now,
+	}
+
+	err := r.store.Insert(j)
+	if err != nil {
+		return Job{}, err
+	}
+	r.count(k, EventSubmitted)
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+
+	return j, nil
+}
+
+// Job returns the job with the given id.
+func (r *runner) Job(id string) (Job, error) {
+	return r.store.Get(id)
+}
+
+// Jobs lists the latest jobs, only those with the given status if not empty.
+func (r *runner) Jobs(status string, limit int64) ([]Job, error) {
+	return r.store.List(status, limit)
+}
+
+// Cancel cancels a queued or running job. A running job is canceled once
+// its handler returns, by the runner running it.
+func (r *runner) Cancel(id string) (Job, error) {
+	r.mu.Lock()
+	cancel, ok := r.running[id]
+	r.mu.Unlock()
+	if ok {
+		cancel()
+		return r.store.Get(id)
+	}
+
+	j, err := r.store.Cancel(id)
+	if err != nil {
+		return j, err
+	}
+	if j.Status == StatusCanceled {
+		r.count(j.Kind, StatusCanceled)
+		r.finished(j)
+	}
+
+	return j, nil
+}
+
+// Stats returns a copy of the counters of the jobs.
+func (r *runner) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := Stats{Running: len(r.running), Counts: map[string]map[string]int64{}}
+	for k, events := range r.counts {
+		stats.Counts[k] = map[string]int64{}
+		for event, n := range events {
+			stats.Counts[k][event] = n
+		}
+	}
+
+	return stats
+}
+
+func (r *runner) count(kind, event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.counts[kind] == nil {
+		r.counts[kind] = map[string]int64{}
+	}
+	r.counts[kind][event]++
+}
+
+// Start requeues the jobs whose runner stopped renewing their lease, e.g.
+// after a crash, and starts the workers. Handlers must be registered
+// before.
+func (r *runner) Start() {
+	r.requeue()
+
+	for i := 0; i < r.workers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+
+	r.wg.Add(1)
+	go r.heartbeat()
+}
+
+// heartbeat renews the leases of the running jobs, cancels those whose
+// cancellation was requested and requeues the jobs of the runners which
+// stopped, until the runner stops.
+func (r *runner) heartbeat() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.renew()
+			r.requeue()
+		}
+	}
+}
+
+func (r *runner) renew() {
+	ids, err := r.store.Heartbeat(r.id)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Runner: unable to renew job leases")
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		if cancel, ok := r.running[id]; ok {
+			cancel()
+		}
+	}
+}
+
+func (r *runner) requeue() {
+	requeued, err := r.store.Requeue(time.Now().Add(-leaseTimeout))
+	if err != nil {
+		r.logger.WithError(err).Errorf("Runner: unable to requeue interrupted jobs")
+	}
+
+	n := 0
+	for _, j := range requeued {
+		if j.Done() {
+			r.count(j.Kind, j.Status)
+			r.finished(j)
+			continue
+		}
+		n++
+	}
+	if n > 0 {
+		r.logger.Warnf("Runner: requeued %d interrupted jobs", n)
+	}
+}
+
+// Stop cancels the running jobs and waits for the workers to return. The
+// canceled jobs are queued again.
+func (r *runner) Stop() {
+	close(r.stop)
+
+	r.mu.Lock()
+	for _, cancel := range r.running {
+		cancel()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+func (r *runner) work() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		for r.next() {
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// next runs one queued job and tells whether there was one.
+func (r *runner) next() bool {
+	select {
+	case <-r.stop:
+		return false
+	default:
+	}
+
+	j, ok, err := r.store.Claim(r.id)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Runner: unable to claim job")
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	r.run(j)
+	return true
+}
+
+func (r *runner) run(j Job) {
+	logger := r.logger.WithField("Job", j.ID)
+
+	r.mu.Lock()
+	kd, ok := r.kinds[j.Kind]
+	ctx, cancel := context.WithCancel(context.Background())
+	r.running[j.ID] = cancel
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.running, j.ID)
+		r.mu.Unlock()
+		cancel()
+	}()
+
+	t := &Task{job: j, store: r.store, logger: logger, cancel: cancel}
+	t.Logf("attempt %d of %d started", j.Attempts, j.MaxAttempts)
+
+	if !ok {
+		err := fmt.Errorf("unknown job kind %q", j.Kind)
+		r.finish(t, StatusFailed, err)
+		return
+	}
+
+	err := kd.handler(ctx, t)
+	if r.lost(t) {
+		return
+	}
+
+	select {
+	case <-r.stop:
+		if err == nil {
+			break
+		}
+		// shutting down, another runner or the next start runs the job.
+		t.mu.Lock()
+		t.job.Status = StatusQueued
+		t.job.log("interrupted by shutdown")
+		t.mu.Unlock()
+		t.save()
+		return
+	default:
+	}
+
+	switch {
+	case err == nil:
+		r.finish(t, StatusSucceeded, nil)
+	case ctx.Err() == context.Canceled:
+		r.finish(t, StatusCanceled, err)
+	case IsPermanent(err) || j.Attempts >= kd.retry.Attempts:
+		r.finish(t, StatusFailed, err)
+	default:
+		delay := kd.retry.delay(j.Attempts)
+
+		t.mu.Lock()
+		t.job.Status = StatusQueued
+		t.job.Error = err.Error()
+		t.job.NotBefore = time.Now().Add(delay)
+		t.job.log(fmt.Sprintf("failed, retrying in %s: %s", delay, err))
+		t.mu.Unlock()
+		t.save()
+		if r.lost(t) {
+			return
+		}
+
+		logger.WithError(err).Warnf("Runner: %s job failed, retrying in %s", j.Kind, delay)
+		r.count(j.Kind, EventRetried)
+	}
+}
+
+// lost tells whether the job of t was requeued after its lease expired,
+// its outcome is then left to the runner which claimed it since.
+func (r *runner) lost(t *Task) bool {
+	if !t.lost() {
+		return false
+	}
+
+	t.logger.Warnf("Runner: %s job was requeued after its lease expired", t.job.Kind)
+	return true
+}
+
+func (r *runner) finish(t *Task, status string, err error) {
+	finished := time.Now()
+
+	t.mu.Lock()
+	t.job.Status = status
+	t.job.Finished = &finished
+	if err != nil {
+		t.job.Error = err.Error()
+		t.job.log(fmt.Sprintf("%s: %s", status, err))
+	} else {
+		t.job.Error = ""
+		t.job.log(status)
+	}
+	t.mu.Unlock()
+
+	t.save()
+	if r.lost(t) {
+		return
+	}
+	r.count(t.job.Kind, status)
+
+	t.mu.Lock()
+	j := t.job
+	t.mu.Unlock()
+	r.finished(j)
+
+	if status == StatusFailed {
+		t.logger.WithError(err).Errorf("Runner: %s job failed", t.job.Kind)
+	} else {
+		t.logger.Infof("Runner: %s job %s", t.job.Kind, status)
+	}
+}
+
+// finished calls the OnDone function of the kind of j, a job which is done.
+func (r *runner) finished(j Job) {
+	r.mu.Lock()
+	fn, ok := r.done[j.Kind]
+	r.mu.Unlock()
+
+	if ok {
+		fn(j)
+	}
+}
+
+// Task is the handle a Handler uses to read its job and report on it.
+type Task struct {
+	mu     sync.Mutex
+	job    Job
+	store  Store
+	logger *logrus.Entry
+	cancel context.CancelFunc
+	// notOwned is set once the job was requeued by another runner.
+	notOwned bool
+}
+
+// ID of the job.
+func (t *Task) ID() string {
+	return t.job.ID
+}
+
+// Payload returns the payload value for key.
+func (t *Task) Payload(key string) string {
+	return t.job.Payload[key]
+}
+
+// Attempt is the number of the current attempt, starting at 1.
+func (t *Task) Attempt() int {
+	return t.job.Attempts
+}
+
+// LastAttempt tells whether a failure of the current attempt is final.
+func (t *Task) LastAttempt() bool {
+	return t.job.lastAttempt()
+}
+
+// Progress records and persists the progress counters of the job.
+func (t *Task) Progress(progress map[string]int64) {
+	t.mu.Lock()
+	t.job.Progress = progress
+	t.mu.Unlock()
+
+	t.save()
+}
+
+// Logf adds a line to the job log and persists it.
+func (t *Task) Logf(format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	t.logger.Debugf("Runner: %s", line)
+
+	t.mu.Lock()
+	t.job.log(line)
+	t.mu.Unlock()
+
+	t.save()
+}
+
+func (t *Task) save() {
+	t.mu.Lock()
+	t.job.Updated = time.Now()
+	j := t.job
+	t.mu.Unlock()
+
+	err := t.store.Save(j)
+	if err == ErrNotOwned {
+		// the lease expired, stop before another runner runs the job twice.
+		t.mu.Lock()
+		t.notOwned = true
+		t.mu.Unlock()
+		t.cancel()
+		return
+	}
+	if err != nil {
+		t.logger.WithError(err).Errorf("Runner: unable to save job")
+	}
+}
+
+func (t *Task) lost() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.notOwned
+}
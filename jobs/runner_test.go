@@ -0,0 +1,289 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRunner(store Store) Runner {
+	logger, _ := test.NewNullLogger()
+	return New(store, 2, logger)
+}
+
+func waitFor(t *testing.T, r Runner, id, status string) Job {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		j, err := r.Job(id)
+		if err == nil && j.Status == status {
+			return j
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("job %s did not reach status %s", id, status)
+	return Job{}
+}
+
+func TestRunnerRunsJob(t *testing.T) {
+	r := newRunner(NewMemoryStore())
+	r.Register("echo", func(ctx context.Context, task *Task) error {
+		task.Progress(map[string]int64{"rows": 3})
+		task.Logf("hello %s", task.Payload("name"))
+		return nil
+	}, Retry{})
+	r.Start()
+	defer r.Stop()
+
+	job, err := r.Submit("echo", map[string]string{"name": "world"})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, StatusQueued, job.Status)
+
+	j := waitFor(t, r, job.ID, StatusSucceeded)
+	assert.Equal(t, int64(3), j.Progress["rows"])
+	assert.Equal(t, 1, j.Attempts)
+	assert.NotNil(t, j.Finished)
+	assert.Contains(t, j.Logs[1], "hello world")
+}
+
+func TestRunnerRetriesJob(t *testing.T) {
+	r := newRunner(NewMemoryStore())
+	r.Register("flaky", func(ctx context.Context, task *Task) error {
+		if task.Attempt() < 2 {
+			return fmt.Errorf("try again")
+		}
+		return nil
+	}, Retry{Attempts: 3})
+	r.Start()
+	defer r.Stop()
+
+	job, err := r.Submit("flaky", nil)
+	require.NoError(t, err, "Expected no error")
+
+	j := waitFor(t, r, job.ID, StatusSucceeded)
+	assert.Equal(t, 2, j.Attempts)
+	assert.Empty(t, j.Error)
+	assert.Equal(t, map[string]int64{EventSubmitted: 1, EventRetried: 1, StatusSucceeded: 1}, r.Stats().Counts["flaky"])
+}
+
+func TestRunnerFailsJob(t *testing.T) {
+	r := newRunner(NewMemoryStore())
+	r.Register("broken", func(ctx context.Context, task *Task) error {
+		return Permanent(fmt.Errorf("invalid input"))
+	}, Retry{Attempts: 3})
+	r.Start()
+	defer r.Stop()
+
+	job, err := r.Submit("broken", nil)
+	require.NoError(t, err, "Expected no error")
+
+	j := waitFor(t, r, job.ID, StatusFailed)
+	assert.Equal(t, 1, j.Attempts)
+	assert.Equal(t, "invalid input", j.Error)
+}
+
+func TestRunnerCancelsRunningJob(t *testing.T) {
+	r := newRunner(NewMemoryStore())
+	started := make(chan struct{})
+	r.Register("slow", func(ctx context.Context, task *Task) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, Retry{Attempts: 3})
+	r.Start()
+	defer r.Stop()
+
+	job, err := r.Submit("slow", nil)
+	require.NoError(t, err, "Expected no error")
+
+	<-started
+	_, err = r.Cancel(job.ID)
+	require.NoError(t, err, "Expected no error")
+
+	waitFor(t, r, job.ID, StatusCanceled)
+}
+
+func TestRunnerCancelsQueuedJob(t *testing.T) {
+	r := newRunner(NewMemoryStore())
+	r.Register("echo", func(ctx context.Context, task *Task) error { return nil }, Retry{})
+
+	job, err := r.Submit("echo", nil)
+	require.NoError(t, err, "Expected no error")
+
+	j, err := r.Cancel(job.ID)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, StatusCanceled, j.Status)
+
+	_, err = r.Cancel(job.ID)
+	assert.EqualError(t, err, fmt.Sprintf("cancel: job %s is canceled", job.ID))
+
+	_, err = r.Cancel("unknown")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestRunnerCallsOnDone(t *testing.T) {
+	store := NewMemoryStore()
+	r := newRunner(store)
+	started := make(chan struct{}, 1)
+	r.Register("slow", func(ctx context.Context, task *Task) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}, Retry{Attempts: 3})
+	done := make(chan Job, 3)
+	r.OnDone("slow", func(j Job) { done <- j })
+
+	// queued, canceled by a requeue of an expired lease, and running.
+	queued, err := r.Submit("slow", nil)
+	require.NoError(t, err, "Expected no error")
+	_, err = r.Cancel(queued.ID)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, queued.ID, (<-done).ID)
+
+	require.NoError(t, store.Insert(Job{ID: "lapsed", Kind: "slow", Status: StatusRunning, CancelRequested: true, Created: time.Now()}))
+	r.Start()
+	defer r.Stop()
+	j := <-done
+	assert.Equal(t, "lapsed", j.ID)
+	assert.Equal(t, StatusCanceled, j.Status)
+
+	running, err := r.Submit("slow", nil)
+	require.NoError(t, err, "Expected no error")
+	<-started
+	_, err = r.Cancel(running.ID)
+	require.NoError(t, err, "Expected no error")
+	j = <-done
+	assert.Equal(t, running.ID, j.ID)
+	assert.Equal(t, StatusCanceled, j.Status)
+}
+
+func TestRunnerRecoversInterruptedJobs(t *testing.T) {
+	store := NewMemoryStore()
+	require.NoError(t, store.Insert(Job{ID: "crashed", Kind: "echo", Status: StatusRunning, MaxAttempts: 1, Created: time.Now()}))
+
+	r := newRunner(store)
+	r.Register("echo", func(ctx context.Context, task *Task) error { return nil }, Retry{})
+	r.Start()
+	defer r.Stop()
+
+	waitFor(t, r, "crashed", StatusSucceeded)
+}
+
+func TestRunnerCancelsJobOfAnotherRunner(t *testing.T) {
+	store := NewMemoryStore()
+	require.NoError(t, store.Insert(Job{ID: "remote", Kind: "echo", Status: StatusQueued, Created: time.Now()}))
+	_, ok, err := store.Claim("other")
+	require.True(t, ok, "Expected a job to claim")
+	require.NoError(t, err, "Expected no error")
+
+	j, err := newRunner(store).Cancel("remote")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, StatusRunning, j.Status)
+	assert.True(t, j.CancelRequested)
+
+	ids, err := store.Heartbeat("other")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, []string{"remote"}, ids)
+}
+
+func TestStoreRequeuesExpiredLeases(t *testing.T) {
+	store := NewMemoryStore()
+	require.NoError(t, store.Insert(Job{ID: "a", Kind: "echo", Status: StatusQueued, MaxAttempts: 2, Created: time.Now()}))
+	j, _, err := store.Claim("first")
+	require.NoError(t, err, "Expected no error")
+
+	requeued, err := store.Requeue(time.Now().Add(-leaseTimeout))
+	require.NoError(t, err, "Expected no error")
+	assert.Empty(t, requeued, "Expected the leased job to be kept")
+
+	requeued, err = store.Requeue(time.Now().Add(time.Second))
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, requeued, 1)
+	assert.Equal(t, StatusQueued, requeued[0].Status)
+
+	claimed, ok, err := store.Claim("second")
+	require.NoError(t, err, "Expected no error")
+	require.True(t, ok, "Expected the requeued job to be claimed")
+	assert.Equal(t, "second", claimed.Runner)
+
+	j.Status = StatusSucceeded
+	assert.Equal(t, ErrNotOwned, store.Save(j), "Expected the first runner to lose the job")
+	got, err := store.Get("a")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, StatusRunning, got.Status)
+}
+
+func TestStoreFailsExpiredLeaseOnLastAttempt(t *testing.T) {
+	store := NewMemoryStore()
+	require.NoError(t, store.Insert(Job{ID: "oom", Kind: "echo", Status: StatusQueued, MaxAttempts: 2, Created: time.Now()}))
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		_, ok, err := store.Claim("crashing")
+		require.NoError(t, err, "Expected no error")
+		require.True(t, ok, "Expected the job to be claimed")
+
+		requeued, err := store.Requeue(time.Now().Add(time.Second))
+		require.NoError(t, err, "Expected no error")
+		require.Len(t, requeued, 1)
+	}
+
+	j, err := store.Get("oom")
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, StatusFailed, j.Status)
+	assert.Equal(t, "lease expired on attempt 2 of 2", j.Error)
+	assert.NotNil(t, j.Finished)
+	assert.Contains(t, j.Logs[len(j.Logs)-1], "failed: lease expired on attempt 2 of 2")
+
+	_, ok, err := store.Claim("crashing")
+	require.NoError(t, err, "Expected no error")
+	assert.False(t, ok, "Expected the failed job not to be claimed")
+}
+
+func TestRunnerStopsJobWhenLeaseLost(t *testing.T) {
+	store := NewMemoryStore()
+	r := newRunner(store)
+	started := make(chan struct{})
+	r.Register("slow", func(ctx context.Context, task *Task) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}, Retry{Attempts: 2})
+	r.Start()
+	defer r.Stop()
+
+	job, err := r.Submit("slow", nil)
+	require.NoError(t, err, "Expected no error")
+	<-started
+
+	_, err = store.Requeue(time.Now().Add(time.Second))
+	require.NoError(t, err, "Expected no error")
+	_, _, err = store.Claim("other")
+	require.NoError(t, err, "Expected no error")
+
+	// the next save of the job tells the runner it lost the job.
+	r.(*runner).mu.Lock()
+	cancel := r.(*runner).running[job.ID]
+	r.(*runner).mu.Unlock()
+	require.NotNil(t, cancel, "Expected the job to be running")
+	cancel()
+
+	time.Sleep(50 * time.Millisecond)
+	j, err := store.Get(job.ID)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, StatusRunning, j.Status)
+	assert.Equal(t, "other", j.Runner)
+	assert.Zero(t, r.Stats().Counts["slow"][StatusCanceled])
+}
+
+func TestSubmitFailsWhenKindUnknown(t *testing.T) {
+	r := newRunner(NewMemoryStore())
+
+	_, err := r.Submit("unknown", nil)
+
+	assert.EqualError(t, err, `submit: unknown job kind "unknown"`)
+}
@@ -0,0 +1,487 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Store persists jobs. A runner owns the jobs it claims through a lease
+// renewed by Heartbeat, jobs whose lease expired are requeued.
+type Store interface {
+	Insert(Job) error
+	// Save saves the state of a job claimed by its runner, ErrNotOwned is
+	// returned if the job was requeued since.
+	Save(Job) error
+	Get(id string) (Job, error)
+	List(status string, limit int64) ([]Job, error)
+	// Claim marks the oldest runnable queued job as running by runner and
+	// returns it.
+	Claim(runner string) (Job, bool, error)
+	// Heartbeat renews the lease of the jobs running by runner and returns
+	// the ids of those whose cancellation was requested.
+	Heartbeat(runner string) ([]string, error)
+	// Requeue puts the running jobs whose last heartbeat is before expired
+	// back in the queue, or cancels them if asked to, and returns them with
+	// their new status.
+	Requeue(expired time.Time) ([]Job, error)
+	// Cancel cancels a queued job, or requests the cancellation of a
+	// running job from its runner, and returns the job. A *StatusError is
+	// returned for a job which is done.
+	Cancel(id string) (Job, error)
+}
+
+type mongoStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoStore returns a Store backed by collection c.
+func NewMongoStore(c *mongo.Collection) Store {
+	return &mongoStore{Collection: c}
+}
+
+func (s *mongoStore) Insert(j Job) error {
+	_, err := s.Collection.InsertOne(context.Background(), j)
+	if err != nil {
+		return fmt.Errorf("insert: unable to insert job: %s", err)
+	}
+
+	return nil
+}
+
+func (s *mongoStore) Save(j Job) error {
+	// the lease and cancellation fields are left to Heartbeat and Cancel.
+	res, err := s.Collection.UpdateOne(context.Background(),
+		bson.D{{Key: "_id", Value: j.ID}, {Key: "runner", Value: j.Runner}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: j.Status},
+			{Key: "progress", Value: j.Progress},
+			{Key: "logs", Value: j.Logs},
+			{Key: "error", Value: j.Error},
+			{Key: "notbefore", Value: j.NotBefore},
+			{Key: "started", Value: j.Started},
+			{Key: "finished", Value: j.Finished},
+			{Key: "updated", Value: j.Updated},
+		}}})
+	if err != nil {
+		return fmt.Errorf("save: unable to save job %s: %s", j.ID, err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrNotOwned
+	}
+
+	return nil
+}
+
+func (s *mongoStore) Get(id string) (Job, error) {
+	var j Job
+	res := s.Collection.FindOne(context.Background(), bson.D{{Key: "_id", Value: id}})
+	if err := res.Err(); err != nil {
+		return j, fmt.Errorf("get: error finding job: %s", err)
+	}
+
+	err := res.Decode(&j)
+	if err == mongo.ErrNoDocuments {
+		return j, ErrNotFound
+	}
+	if err != nil {
+		return j, fmt.Errorf("get: could not decode job: %s", err)
+	}
+
+	return j, nil
+}
+
+func (s *mongoStore) List(status string, limit int64) ([]Job, error) {
+	filter := bson.D{}
+	if status != "" {
+		filter = bson.D{{Key: "status", Value: status}}
+	}
+
+	ctx := context.Background()
+	cur, err := s.Collection.Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "created", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return nil, fmt.Errorf("list: unable to find jobs: %s", err)
+	}
+	defer cur.Close(ctx)
+
+	jobs := []Job{}
+	for cur.Next(ctx) {
+		var j Job
+		err = cur.Decode(&j)
+		if err != nil {
+			return nil, fmt.Errorf("list: error decoding job: %s", err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+func (s *mongoStore) Claim(runner string) (Job, bool, error) {
+	now := time.Now()
+	filter := bson.D{
+		{Key: "status", Value: StatusQueued},
+		{Key: "notbefore", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: StatusRunning},
+			{Key: "runner", Value: runner},
+			{Key: "heartbeat", Value: now},
+			{Key: "cancelrequested", Value: false},
+			{Key: "started", Value: now},
+			{Key: "updated", Value: now},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var j Job
+	err := s.Collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&j)
+	if err == mongo.ErrNoDocuments {
+		return j, false, nil
+	}
+	if err != nil {
+		return j, false, fmt.Errorf("claim: unable to claim job: %s", err)
+	}
+
+	return j, true, nil
+}
+
+func (s *mongoStore) Heartbeat(runner string) ([]string, error) {
+	ctx := context.Background()
+	owned := bson.D{{Key: "runner", Value: runner}, {Key: "status", Value: StatusRunning}}
+
+	_, err := s.Collection.UpdateMany(ctx, owned,
+		bson.D{{Key: "$set", Value: bson.D{{Key: "heartbeat", Value: time.Now()}}}})
+	if err != nil {
+		return nil, fmt.Errorf("heartbeat: unable to renew leases: %s", err)
+	}
+
+	cur, err := s.Collection.Find(ctx, append(owned, bson.E{Key: "cancelrequested", Value: true}),
+		options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("heartbeat: unable to find canceled jobs: %s", err)
+	}
+	defer cur.Close(ctx)
+
+	var ids []string
+	for cur.Next(ctx) {
+		var j Job
+		err = cur.Decode(&j)
+		if err != nil {
+			return nil, fmt.Errorf("heartbeat: error decoding job: %s", err)
+		}
+		ids = append(ids, j.ID)
+	}
+
+	return ids, nil
+}
+
+func (s *mongoStore) Requeue(expired time.Time) ([]Job, error) {
+	ctx := context.Background()
+	// a missing heartbeat, from a job claimed before leases, is expired too.
+	lapsed := bson.D{
+		{Key: "status", Value: StatusRunning},
+		{Key: "heartbeat", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$gte", Value: expired}}}}},
+	}
+
+	cur, err := s.Collection.Find(ctx, lapsed)
+	if err != nil {
+		return nil, fmt.Errorf("requeue: unable to find jobs: %s", err)
+	}
+	defer cur.Close(ctx)
+
+	var found []Job
+	for cur.Next(ctx) {
+		var j Job
+		err = cur.Decode(&j)
+		if err != nil {
+			return nil, fmt.Errorf("requeue: error decoding job: %s", err)
+		}
+		found = append(found, j)
+	}
+
+	var requeued []Job
+	for _, j := range found {
+		now := time.Now()
+		j.Status = StatusQueued
+		j.Runner = ""
+		j.Updated = now
+		set := bson.D{{Key: "status", Value: StatusQueued}, {Key: "updated", Value: now}}
+		update := bson.D{{Key: "$unset", Value: bson.D{{Key: "runner", Value: ""}}}}
+		// a cancellation requested since the job was found is kept for the
+		// next run.
+		cancelRequested := bson.D{{Key: "$ne", Value: true}}
+		switch {
+		case j.CancelRequested:
+			j.Status = StatusCanceled
+			j.Finished = &now
+			set = bson.D{{Key: "status", Value: StatusCanceled}, {Key: "finished", Value: now}, {Key: "updated", Value: now}}
+			cancelRequested = bson.D{{Key: "$eq", Value: true}}
+		case j.lastAttempt():
+			j.expire(now)
+			set = bson.D{
+				{Key: "status", Value: StatusFailed},
+				{Key: "error", Value: j.Error},
+				{Key: "finished", Value: now},
+				{Key: "updated", Value: now},
+			}
+			update = append(update, bson.E{Key: "$push", Value: pushLog(StatusFailed + ": " + j.Error)})
+		}
+
+		// the lease may have been renewed since the job was found.
+		filter := append(bson.D{{Key: "_id", Value: j.ID}, {Key: "cancelrequested", Value: cancelRequested}}, lapsed...)
+		res, err := s.Collection.UpdateOne(ctx, filter, append(update, bson.E{Key: "$set", Value: set}))
+		if err != nil {
+			return requeued, fmt.Errorf("requeue: unable to requeue job %s: %s", j.ID, err)
+		}
+		if res.ModifiedCount > 0 {
+			requeued = append(requeued, j)
+		}
+	}
+
+	return requeued, nil
+}
+
+func (s *mongoStore) Cancel(id string) (Job, error) {
+	ctx := context.Background()
+	now := time.Now()
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var j Job
+	err := s.Collection.FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "status", Value: StatusQueued}},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: StatusCanceled},
+				{Key: "finished", Value: now},
+				{Key: "updated", Value: now},
+			}},
+			{Key: "$push", Value: pushLog("canceled")},
+		}, opts).Decode(&j)
+	if err != mongo.ErrNoDocuments {
+		if err != nil {
+			return j, fmt.Errorf("cancel: unable to cancel job %s: %s", id, err)
+		}
+		return j, nil
+	}
+
+	err = s.Collection.FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: id}, {Key: "status", Value: StatusRunning}},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "cancelrequested", Value: true},
+				{Key: "updated", Value: now},
+			}},
+			{Key: "$push", Value: pushLog("cancel requested")},
+		}, opts).Decode(&j)
+	if err != mongo.ErrNoDocuments {
+		if err != nil {
+			return j, fmt.Errorf("cancel: unable to cancel job %s: %s", id, err)
+		}
+		return j, nil
+	}
+
+	// the job is done, or was neither queued nor running when updated.
+	j, err = s.Get(id)
+	if err != nil {
+		return j, err
+	}
+
+	return j, &StatusError{ID: id, Status: j.Status}
+}
+
+// pushLog returns the $push of a line to the job log, keeping the last
+// maxLogs lines.
+func pushLog(line string) bson.D {
+	return bson.D{{Key: "logs", Value: bson.D{
+		{Key: "$each", Value: bson.A{logLine(line)}},
+		{Key: "$slice", Value: -maxLogs},
+	}}}
+}
+
+type memoryStore struct {
+	mu   sync.Mutex
+	jobs map[string]Job
+}
+
+// NewMemoryStore returns a Store keeping jobs in memory, they are lost on
+// restart.
+func NewMemoryStore() Store {
+	return &memoryStore{jobs: map[string]Job{}}
+}
+
+func (s *memoryStore) Insert(j Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.jobs[j.ID]; ok {
+		return fmt.Errorf("insert: duplicate job %s", j.ID)
+	}
+	s.jobs[j.ID] = j
+
+	return nil
+}
+
+func (s *memoryStore) Save(j Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.jobs[j.ID]
+	if !ok || stored.Runner != j.Runner {
+		return ErrNotOwned
+	}
+	j.Heartbeat = stored.Heartbeat
+	j.CancelRequested = stored.CancelRequested
+	s.jobs[j.ID] = j
+
+	return nil
+}
+
+func (s *memoryStore) Get(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return j, ErrNotFound
+	}
+
+	return j, nil
+}
+
+func (s *memoryStore) List(status string, limit int64) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []Job{}
+	for _, j := range s.jobs {
+		if status == "" || j.Status == status {
+			jobs = append(jobs, j)
+		}
+	}
+
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].Created.After(jobs[b].Created) })
+	if limit > 0 && int64(len(jobs)) > limit {
+		jobs = jobs[:limit]
+	}
+
+	return jobs, nil
+}
+
+func (s *memoryStore) Claim(runner string) (Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var claimed *Job
+	for _, j := range s.jobs {
+		if j.Status != StatusQueued || j.NotBefore.After(now) {
+			continue
+		}
+		if claimed == nil || j.Created.Before(claimed.Created) {
+			j := j
+			claimed = &j
+		}
+	}
+
+	if claimed == nil {
+		return Job{}, false, nil
+	}
+
+	claimed.Status = StatusRunning
+	claimed.Runner = runner
+	claimed.Heartbeat = &now
+	claimed.CancelRequested = false
+	claimed.Started = &now
+	claimed.Updated = now
+	claimed.Attempts++
+	s.jobs[claimed.ID] = *claimed
+
+	return *claimed, true, nil
+}
+
+func (s *memoryStore) Heartbeat(runner string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var ids []string
+	for id, j := range s.jobs {
+		if j.Runner != runner || j.Status != StatusRunning {
+			continue
+		}
+		j.Heartbeat = &now
+		s.jobs[id] = j
+		if j.CancelRequested {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (s *memoryStore) Requeue(expired time.Time) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var requeued []Job
+	for id, j := range s.jobs {
+		if j.Status != StatusRunning || (j.Heartbeat != nil && !j.Heartbeat.Before(expired)) {
+			continue
+		}
+		j.Status = StatusQueued
+		switch {
+		case j.CancelRequested:
+			j.Status = StatusCanceled
+			j.Finished = &now
+		case j.lastAttempt():
+			j.expire(now)
+			j.log(StatusFailed + ": " + j.Error)
+		}
+		j.Runner = ""
+		j.Updated = now
+		s.jobs[id] = j
+		requeued = append(requeued, j)
+	}
+
+	return requeued, nil
+}
+
+func (s *memoryStore) Cancel(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return j, ErrNotFound
+	}
+
+	now := time.Now()
+	switch j.Status {
+	case StatusQueued:
+		j.Status = StatusCanceled
+		j.Finished = &now
+		j.log("canceled")
+	case StatusRunning:
+		j.CancelRequested = true
+		j.log("cancel requested")
+	default:
+		return j, &StatusError{ID: id, Status: j.Status}
+	}
+	j.Updated = now
+	s.jobs[id] = j
+
+	return j, nil
+}
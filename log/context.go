@@ -0,0 +1,65 @@
+package log
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/event"
+
+	"github.com/vikashvverma/stock-backend/requestid"
+	"github.com/vikashvverma/stock-backend/tracing"
+)
+
+// ContextHook adds the request ID and trace ID of the entry context to the
+// entries logged with logrus' WithContext.
+type ContextHook struct{}
+
+// Levels of the entries the hook applies to.
+func (ContextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire adds the request ID and trace ID fields to the entry.
+func (ContextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+
+	if id := requestid.FromContext(entry.Context); id != "" {
+		entry.Data["RequestID"] = id
+	}
+	if sc, ok := tracing.SpanContextFromContext(entry.Context); ok {
+		entry.Data["TraceID"] = sc.TraceID.String()
+	}
+
+	return nil
+}
+
+// CommandMonitor returns a Mongo command monitor logging the commands with
+// the request ID of their context, at debug level unless they fail.
+func CommandMonitor(l *logrus.Logger) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			l.WithContext(ctx).WithFields(logrus.Fields{
+				"Command":        e.CommandName,
+				"Database":       e.DatabaseName,
+				"MongoRequestID": e.RequestID,
+			}).Debugf("Mongo: command started")
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			l.WithContext(ctx).WithFields(logrus.Fields{
+				"Command":        e.CommandName,
+				"MongoRequestID": e.RequestID,
+				"Duration":       int64(time.Duration(e.DurationNanos) / time.Millisecond),
+			}).Debugf("Mongo: command succeeded")
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			l.WithContext(ctx).WithFields(logrus.Fields{
+				"Command":        e.CommandName,
+				"MongoRequestID": e.RequestID,
+				"Duration":       int64(time.Duration(e.DurationNanos) / time.Millisecond),
+			}).Errorf("Mongo: command failed: %s", e.Failure)
+		},
+	}
+}
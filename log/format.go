@@ -0,0 +1,35 @@
+package log
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+// Formatter returns the logrus formatter of the format, a JSON object per
+// line for config.LogJSON and text otherwise.
+func Formatter(format string) logrus.Formatter {
+	if format == config.LogJSON {
+		return &logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano}
+	}
+
+	return &logrus.TextFormatter{}
+}
+
+// AccessLogger returns the logger of the requests and responses: l, or a
+// logger with its format and level writing to c.AccessLogFile if set.
+func AccessLogger(c *config.Config, l *logrus.Logger) *logrus.Logger {
+	if c.AccessLogFile() == nil {
+		return l
+	}
+
+	access := logrus.New()
+	access.Out = c.AccessLogFile()
+	access.Formatter = Formatter(c.LogFormat())
+	access.SetLevel(logrus.Level(c.LogLevel()))
+	access.AddHook(ContextHook{})
+
+	return access
+}
@@ -1,11 +1,14 @@
 package log
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/codegangsta/negroni"
 	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/requestid"
 )
 
 // Logger represents a request response logger.
@@ -22,6 +25,21 @@ func New(l *logrus.Logger) Logger {
 	return &requestResponseLogger{Logger: l}
 }
 
+type fieldsKey struct{}
+
+// AddFields adds fields to the response log line of the request, e.g. the
+// identity set by a later middleware.
+func AddFields(r *http.Request, fields logrus.Fields) {
+	extra, ok := r.Context().Value(fieldsKey{}).(logrus.Fields)
+	if !ok {
+		return
+	}
+
+	for k, v := range fields {
+		extra[k] = v
+	}
+}
+
 // ServeHTTP method for the middleware.
 func (rrl *requestResponseLogger) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	start := time.Now()
@@ -29,16 +47,26 @@ func (rrl *requestResponseLogger) ServeHTTP(w http.ResponseWriter, r *http.Reque
 	reqFields := requestFields(r)
 	rrl.Logger.WithFields(reqFields).Infof("Request")
 
-	next(w, r)
+	// Outside of negroni the writer must be wrapped to know the status.
+	res, ok := w.(negroni.ResponseWriter)
+	if !ok {
+		res = negroni.NewResponseWriter(w)
+	}
+
+	extra := logrus.Fields{}
+	next(res, r.WithContext(context.WithValue(r.Context(), fieldsKey{}, extra)))
 
-	res := w.(negroni.ResponseWriter)
 	resFields := responseFields(r, res)
+	for k, v := range extra {
+		resFields[k] = v
+	}
 	resFields["Duration"] = int64(time.Since(start) / time.Millisecond)
 	rrl.Logger.WithFields(resFields).Infof("Response")
 }
 
 func requestFields(r *http.Request) logrus.Fields {
 	fields := logrus.Fields{}
+	fields["RequestID"] = requestid.FromContext(r.Context())
 	fields["Client"] = r.RemoteAddr
 	fields["Method"] = r.Method
 	fields["URL"] = r.URL.String()
@@ -49,6 +77,7 @@ func requestFields(r *http.Request) logrus.Fields {
 
 func responseFields(r *http.Request, w negroni.ResponseWriter) logrus.Fields {
 	fields := logrus.Fields{}
+	fields["RequestID"] = requestid.FromContext(r.Context())
 	fields["Method"] = r.Method
 	fields["URL"] = r.URL.String()
 	fields["StatusCode"] = w.Status()
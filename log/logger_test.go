@@ -1,6 +1,9 @@
 package log
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -9,6 +12,11 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/config"
+	"github.com/vikashvverma/stock-backend/requestid"
+	"github.com/vikashvverma/stock-backend/tracing"
 )
 
 func TestServeHTTP(t *testing.T) {
@@ -16,6 +24,7 @@ func TestServeHTTP(t *testing.T) {
 	requestResponseLogger := New(logger)
 	nrw := negroni.NewResponseWriter(httptest.NewRecorder())
 	r := httptest.NewRequest(http.MethodGet, "/foo/bar/baz", nil)
+	r = r.WithContext(requestid.WithID(r.Context(), "abc"))
 	handler := func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "/foo/bar/baz", r.URL.Path)
 		w.WriteHeader(http.StatusOK)
@@ -24,6 +33,7 @@ func TestServeHTTP(t *testing.T) {
 	assert.Len(t, hook.Entries, 2)
 
 	reqFields := logrus.Fields{
+		"RequestID":  "abc",
 		"Client":     r.RemoteAddr,
 		"Method":     http.MethodGet,
 		"URL":        "/foo/bar/baz",
@@ -37,6 +47,67 @@ func TestServeHTTP(t *testing.T) {
 	assert.Equal(t, "GET", entry1.Data["Method"])
 	assert.Equal(t, "/foo/bar/baz", entry1.Data["URL"])
 	assert.Equal(t, 200, entry1.Data["StatusCode"])
+	assert.Equal(t, "abc", entry1.Data["RequestID"])
 	assert.Equal(t, "Request", hook.Entries[0].Message)
 	assert.Equal(t, "Response", hook.Entries[1].Message)
 }
+
+func TestServeHTTPPlainWriter(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	New(logger).ServeHTTP(httptest.NewRecorder(), r, handler)
+	require.Len(t, hook.Entries, 2)
+
+	assert.Equal(t, http.StatusNotFound, hook.Entries[1].Data["StatusCode"])
+}
+
+func TestAddFields(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	requestResponseLogger := New(logger)
+	nrw := negroni.NewResponseWriter(httptest.NewRecorder())
+	r := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		AddFields(r, logrus.Fields{"Key": "web"})
+		w.WriteHeader(http.StatusOK)
+	}
+	requestResponseLogger.ServeHTTP(nrw, r, handler)
+	require.Len(t, hook.Entries, 2)
+
+	assert.Equal(t, "web", hook.Entries[1].Data["Key"])
+	assert.NotContains(t, hook.Entries[0].Data, "Key")
+}
+
+func TestContextHook(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	logger.AddHook(ContextHook{})
+
+	sc, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := tracing.ContextWithSpanContext(requestid.WithID(context.Background(), "abc"), sc)
+	logger.WithContext(ctx).Errorf("with id")
+	logger.Errorf("without context")
+	require.Len(t, hook.Entries, 2)
+
+	assert.Equal(t, "abc", hook.Entries[0].Data["RequestID"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", hook.Entries[0].Data["TraceID"])
+	assert.NotContains(t, hook.Entries[1].Data, "RequestID")
+}
+
+func TestFormatter(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	var out bytes.Buffer
+	logger.Out = &out
+	logger.Formatter = Formatter(config.LogJSON)
+
+	logger.WithField("RequestID", "abc").Infof("Request")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry), "Expected no error")
+	assert.Equal(t, "Request", entry["msg"])
+	assert.Equal(t, "abc", entry["RequestID"])
+	assert.Equal(t, "info", entry["level"])
+
+	assert.IsType(t, &logrus.TextFormatter{}, Formatter(config.LogText))
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/codegangsta/negroni"
+	"github.com/gorilla/mux"
+
+	"github.com/vikashvverma/stock-backend/route"
+)
+
+// Middleware records the count, latency and status of the requests.
+type Middleware interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+}
+
+type requestMetrics struct {
+	router   *mux.Router
+	requests *Vec
+	duration *HistogramVec
+	inFlight *Vec
+}
+
+// New returns a Middleware recording the requests in reg, labeled with the
+// path template of the router route they match. It must come before the
+// recovery middleware to count the panics as server errors.
+func New(reg *Registry, router *mux.Router) Middleware {
+	return &requestMetrics{
+		router:   router,
+		requests: reg.Counter("http_requests_total", "Number of HTTP requests.", "method", "route", "status"),
+		duration: reg.Histogram("http_request_duration_seconds", "Latency of the HTTP requests.", DefaultBuckets, "method", "route", "status"),
+		inFlight: reg.Gauge("http_requests_in_flight", "Number of HTTP requests being served."),
+	}
+}
+
+func (rm *requestMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	start := time.Now()
+	rm.inFlight.Inc()
+	defer rm.inFlight.Dec()
+
+	res, ok := w.(negroni.ResponseWriter)
+	if !ok {
+		res = negroni.NewResponseWriter(w)
+	}
+
+	next(res, r)
+
+	status := res.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	template := route.Template(rm.router, r)
+	code := strconv.Itoa(status)
+
+	rm.requests.Inc(r.Method, template, code)
+	rm.duration.Observe(time.Since(start).Seconds(), r.Method, template, code)
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codegangsta/negroni"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vikashvverma/stock-backend/route"
+)
+
+func TestServeHTTP(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/stock/{name}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods(http.MethodGet)
+	router.HandleFunc("/healthcheck", func(w http.ResponseWriter, r *http.Request) {}).Methods(http.MethodGet)
+
+	reg := NewRegistry()
+	n := negroni.New(New(reg, router))
+	n.UseHandler(router)
+
+	for _, path := range []string{"/stock/AAPL", "/stock/MSFT", "/healthcheck", "/unknown"} {
+		n.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	requests := reg.Counter("http_requests_total", "")
+	assert.Equal(t, float64(2), requests.Get("GET", "/stock/{name}", "404"))
+	assert.Equal(t, float64(1), requests.Get("GET", "/healthcheck", "200"))
+	assert.Equal(t, float64(1), requests.Get("GET", route.Unmatched, "404"))
+	assert.Equal(t, float64(0), reg.Gauge("http_requests_in_flight", "").Get())
+}
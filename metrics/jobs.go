@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"sort"
+
+	"github.com/vikashvverma/stock-backend/jobs"
+)
+
+// Jobs registers the job counters of r in reg.
+func Jobs(reg *Registry, r jobs.Runner) {
+	reg.Func("jobs_total", "Number of jobs by kind and event (submitted, retried or final status).", TypeCounter, func() []Value {
+		var values []Value
+		for kind, events := range r.Stats().Counts {
+			for event, n := range events {
+				values = append(values, Value{Labels: []string{kind, event}, Value: float64(n)})
+			}
+		}
+		sort.Slice(values, func(i, j int) bool {
+			return key(values[i].Labels) < key(values[j].Labels)
+		})
+
+		return values
+	}, "kind", "event")
+
+	reg.Func("jobs_running", "Number of jobs being run.", TypeGauge, func() []Value {
+		return []Value{{Value: float64(r.Stats().Running)}}
+	})
+}
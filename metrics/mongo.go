@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// CommandMonitor returns a Mongo command monitor recording the commands in
+// reg, calling next as well if not nil. The driver has no connection pool
+// monitor, the commands in flight are the closest to the connections in use.
+func CommandMonitor(reg *Registry, next *event.CommandMonitor) *event.CommandMonitor {
+	if next == nil {
+		next = &event.CommandMonitor{}
+	}
+
+	commands := reg.Counter("mongo_commands_total", "Number of Mongo commands by outcome.", "command", "outcome")
+	duration := reg.Histogram("mongo_command_duration_seconds", "Duration of the Mongo commands.", DefaultBuckets, "command")
+	inFlight := reg.Gauge("mongo_commands_in_flight", "Number of Mongo commands waiting for a reply.")
+
+	finished := func(e event.CommandFinishedEvent, outcome string) {
+		inFlight.Dec()
+		commands.Inc(e.CommandName, outcome)
+		duration.Observe((time.Duration(e.DurationNanos) * time.Nanosecond).Seconds(), e.CommandName)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			inFlight.Inc()
+			if next.Started != nil {
+				next.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			finished(e.CommandFinishedEvent, "succeeded")
+			if next.Succeeded != nil {
+				next.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			finished(e.CommandFinishedEvent, "failed")
+			if next.Failed != nil {
+				next.Failed(ctx, e)
+			}
+		},
+	}
+}
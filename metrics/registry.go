@@ -0,0 +1,324 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metric types of the Prometheus text format.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the latency histograms.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Registry holds the metrics of the service and writes them in the
+// Prometheus text format. Getting a metric that is already registered
+// returns it, so components can share a registry without coordination.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+type family interface {
+	write(w io.Writer)
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]family{}}
+}
+
+// Counter returns the counter with the given name and label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Vec {
+	return r.vec(name, help, TypeCounter, labels).(*Vec)
+}
+
+// Gauge returns the gauge with the given name and label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *Vec {
+	return r.vec(name, help, TypeGauge, labels).(*Vec)
+}
+
+// Histogram returns the histogram with the given name, bucket upper bounds
+// and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		return f.(*HistogramVec)
+	}
+
+	h := &HistogramVec{
+		desc:    desc{name: name, help: help, typ: TypeHistogram, labels: labels},
+		buckets: buckets,
+		values:  map[string]*histogram{},
+	}
+	r.families[name] = h
+
+	return h
+}
+
+// Func registers a metric of the given type whose values are read from f
+// when the metrics are written, for counts kept by other components.
+func (r *Registry) Func(name, help, typ string, f func() []Value, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.families[name] = &funcFamily{desc: desc{name: name, help: help, typ: typ, labels: labels}, f: f}
+}
+
+// CounterFunc registers a counter without labels whose value is read from f.
+func (r *Registry) CounterFunc(name, help string, f func() float64) {
+	r.Func(name, help, TypeCounter, func() []Value {
+		return []Value{{Value: f()}}
+	})
+}
+
+func (r *Registry) vec(name, help, typ string, labels []string) family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f, ok := r.families[name]; ok {
+		return f
+	}
+
+	v := &Vec{desc: desc{name: name, help: help, typ: typ, labels: labels}, values: map[string]*Value{}}
+	r.families[name] = v
+
+	return v
+}
+
+// Write writes the metrics sorted by name.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	families := make([]family, len(names))
+	for i, name := range names {
+		families[i] = r.families[name]
+	}
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	bw.Flush()
+}
+
+// Handler returns the handler serving the metrics.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// Value is the value of a metric for a set of label values.
+type Value struct {
+	Labels []string
+	Value  float64
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, strings.Replace(d.help, "\n", " ", -1), d.name, d.typ)
+}
+
+// sample writes a line of the metric, extra are label name and value pairs
+// following the labels of the metric.
+func (d desc) sample(w io.Writer, suffix string, values []string, v float64, extra ...string) {
+	pairs := make([]string, 0, len(d.labels)+len(extra)/2)
+	for i, name := range d.labels {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, name+"="+quote(value))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"="+quote(extra[i+1]))
+	}
+
+	labels := ""
+	if len(pairs) > 0 {
+		labels = "{" + strings.Join(pairs, ",") + "}"
+	}
+	fmt.Fprintf(w, "%s%s%s %s\n", d.name, suffix, labels, format(v))
+}
+
+func quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
+
+func format(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// key joins label values, which can't contain the separator byte.
+func key(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Vec is a counter or gauge with a value per set of label values.
+type Vec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*Value
+}
+
+// Add adds delta to the value for the label values.
+func (v *Vec) Add(delta float64, labels ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	k := key(labels)
+	val, ok := v.values[k]
+	if !ok {
+		val = &Value{Labels: append([]string{}, labels...)}
+		v.values[k] = val
+	}
+	val.Value += delta
+}
+
+// Inc adds one to the value for the label values.
+func (v *Vec) Inc(labels ...string) {
+	v.Add(1, labels...)
+}
+
+// Dec subtracts one from the value for the label values, for gauges.
+func (v *Vec) Dec(labels ...string) {
+	v.Add(-1, labels...)
+}
+
+// Get returns the value for the label values.
+func (v *Vec) Get(labels ...string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if val, ok := v.values[key(labels)]; ok {
+		return val.Value
+	}
+	return 0
+}
+
+func (v *Vec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.header(w)
+	if len(v.labels) == 0 && len(v.values) == 0 {
+		v.sample(w, "", nil, 0)
+		return
+	}
+
+	keys := map[string]bool{}
+	for k := range v.values {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		v.sample(w, "", v.values[k].Labels, v.values[k].Value)
+	}
+}
+
+// HistogramVec is a histogram with a distribution per set of label values.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe adds the observation v for the label values.
+func (h *HistogramVec) Observe(v float64, labels ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := key(labels)
+	hist, ok := h.values[k]
+	if !ok {
+		hist = &histogram{labels: append([]string{}, labels...), counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hist
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w)
+	keys := map[string]bool{}
+	for k := range h.values {
+		keys[k] = true
+	}
+	for _, k := range sortedKeys(keys) {
+		hist := h.values[k]
+		for i, upper := range h.buckets {
+			h.sample(w, "_bucket", hist.labels, float64(hist.counts[i]), "le", format(upper))
+		}
+		h.sample(w, "_bucket", hist.labels, float64(hist.count), "le", "+Inf")
+		h.sample(w, "_sum", hist.labels, hist.sum)
+		h.sample(w, "_count", hist.labels, float64(hist.count))
+	}
+}
+
+type funcFamily struct {
+	desc
+	f func() []Value
+}
+
+func (ff *funcFamily) write(w io.Writer) {
+	ff.header(w)
+	for _, v := range ff.f() {
+		ff.sample(w, "", v.Labels, v.Value)
+	}
+}
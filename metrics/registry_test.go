@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("requests_total", "Number of requests.", "route").Inc(`/a"b`)
+	reg.Counter("requests_total", "ignored", "route").Add(2, "/c")
+	reg.Gauge("in_flight", "Requests in flight.")
+	h := reg.Histogram("duration_seconds", "Duration.", []float64{.1, 1}, "route")
+	h.Observe(.05, "/c")
+	h.Observe(.5, "/c")
+	reg.CounterFunc("panics_total", "Panics.", func() float64 { return 3 })
+
+	var buf bytes.Buffer
+	reg.Write(&buf)
+
+	assert.Equal(t, `# HELP duration_seconds Duration.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{route="/c",le="0.1"} 1
+duration_seconds_bucket{route="/c",le="1"} 2
+duration_seconds_bucket{route="/c",le="+Inf"} 2
+duration_seconds_sum{route="/c"} 0.55
+duration_seconds_count{route="/c"} 2
+# HELP in_flight Requests in flight.
+# TYPE in_flight gauge
+in_flight 0
+# HELP panics_total Panics.
+# TYPE panics_total counter
+panics_total 3
+# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{route="/a\"b"} 1
+requests_total{route="/c"} 2
+`, buf.String())
+}
+
+func TestRegistryHandler(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("requests_total", "Number of requests.").Inc()
+	rec := httptest.NewRecorder()
+
+	reg.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "requests_total 1\n")
+}
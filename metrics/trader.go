@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+type trader struct {
+	next     stock.Trader
+	duration *HistogramVec
+	errors   *Vec
+}
+
+// Trader returns a stock.Trader recording the duration and errors of the
+// methods of t in reg.
+func Trader(t stock.Trader, reg *Registry) stock.Trader {
+	return &trader{
+		next:     t,
+		duration: reg.Histogram("stock_trader_duration_seconds", "Duration of the stock.Trader methods.", DefaultBuckets, "method"),
+		errors:   reg.Counter("stock_trader_errors_total", "Number of stock.Trader errors by kind.", "method", "kind"),
+	}
+}
+
+func (t *trader) Find(ctx context.Context, name string) ([]stock.PricePoint, error) {
+	defer t.observe("Find", time.Now())
+	points, err := t.next.Find(ctx, name)
+	t.count("Find", err)
+	return points, err
+}
+
+func (t *trader) FindAll(ctx context.Context, tickers []string, from, to time.Time) ([]stock.Stock, error) {
+	defer t.observe("FindAll", time.Now())
+	stocks, err := t.next.FindAll(ctx, tickers, from, to)
+	t.count("FindAll", err)
+	return stocks, err
+}
+
+func (t *trader) Top(ctx context.Context, from, to time.Time, best bool) (interface{}, error) {
+	defer t.observe("Top", time.Now())
+	top, err := t.next.Top(ctx, from, to, best)
+	t.count("Top", err)
+	return top, err
+}
+
+func (t *trader) observe(method string, start time.Time) {
+	t.duration.Observe(time.Since(start).Seconds(), method)
+}
+
+// count records err with the kind of the stock.Error, or "internal".
+func (t *trader) count(method string, err error) {
+	if err == nil {
+		return
+	}
+
+	kind := "internal"
+	if e, ok := err.(*stock.Error); ok {
+		kind = e.Kind
+	}
+	t.errors.Inc(method, kind)
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+type fakeTrader struct {
+	err error
+}
+
+func (f fakeTrader) Find(context.Context, string) ([]stock.PricePoint, error) {
+	return nil, f.err
+}
+
+func (f fakeTrader) FindAll(context.Context, []string, time.Time, time.Time) ([]stock.Stock, error) {
+	return nil, f.err
+}
+
+func (f fakeTrader) Top(context.Context, time.Time, time.Time, bool) (interface{}, error) {
+	return nil, f.err
+}
+
+func TestTrader(t *testing.T) {
+	reg := NewRegistry()
+	ctx := context.Background()
+
+	Trader(fakeTrader{err: stock.NotFound("FOO")}, reg).Find(ctx, "FOO")
+	Trader(fakeTrader{err: errors.New("boom")}, reg).Top(ctx, time.Now(), time.Now(), true)
+	Trader(fakeTrader{}, reg).FindAll(ctx, nil, time.Now(), time.Now())
+
+	errs := reg.Counter("stock_trader_errors_total", "")
+	assert.Equal(t, float64(1), errs.Get("Find", stock.KindNotFound))
+	assert.Equal(t, float64(1), errs.Get("Top", "internal"))
+	assert.Equal(t, float64(0), errs.Get("FindAll", "internal"))
+	assert.Equal(t, uint64(1), reg.Histogram("stock_trader_duration_seconds", "", nil).values[key([]string{"FindAll"})].count)
+}
@@ -0,0 +1,128 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/auth"
+	"github.com/vikashvverma/stock-backend/config"
+	"github.com/vikashvverma/stock-backend/response"
+)
+
+// FailureLimiter is the middleware limiting the failed authentications per
+// client IP.
+type FailureLimiter interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+}
+
+type failureLimiter struct {
+	Logger *logrus.Logger
+	spec   config.AuthFailureLimitSpec
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+// NewFailureLimiter returns a FailureLimiter taking a token of the bucket
+// of the client IP for every request the authenticator denies for invalid
+// credentials, see auth.WithFailures. It has to come
+// before the authenticator: the requests of an IP whose bucket is empty get
+// a 429 without their API key being looked up, so guessing keys is
+// throttled.
+func NewFailureLimiter(l *logrus.Logger, spec config.AuthFailureLimitSpec) FailureLimiter {
+	return &failureLimiter{Logger: l, spec: spec, now: time.Now, buckets: map[string]*bucket{}}
+}
+
+func (fl *failureLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if fl.spec.Disabled {
+		next(w, r)
+		return
+	}
+
+	ip := clientIP(r)
+	if retryAfter, ok := fl.blocked(ip); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		fl.Logger.WithContext(r.Context()).Warnf("FailureLimiter: ip:%s exceeded the failed authentications limit", ip)
+		response.Response{Errors: &response.Error{
+			Reason: "too many failed authentications",
+			Code:   response.CodeRateLimitExceeded,
+		}}.TooManyRequests(w)
+		return
+	}
+
+	ctx, failed := auth.WithFailures(r.Context())
+	next(w, r.WithContext(ctx))
+
+	if failed() {
+		fl.fail(ip)
+	}
+}
+
+// blocked tells whether ip has no failure left and when it gets one back.
+func (fl *failureLimiter) blocked(ip string) (time.Duration, bool) {
+	now := fl.now()
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	b := fl.buckets[ip]
+	if b == nil {
+		return 0, false
+	}
+	b.refill(now, fl.spec.Rate, fl.spec.Burst)
+	if b.tokens >= 1 {
+		return 0, false
+	}
+
+	return time.Duration((1 - b.tokens) / fl.spec.Rate * float64(time.Second)), true
+}
+
+func (fl *failureLimiter) fail(ip string) {
+	now := fl.now()
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	fl.prune(now)
+
+	b := fl.buckets[ip]
+	if b == nil {
+		b = &bucket{tokens: float64(fl.spec.Burst), last: now}
+		fl.buckets[ip] = b
+	}
+	b.refill(now, fl.spec.Rate, fl.spec.Burst)
+	b.tokens = math.Max(b.tokens-1, 0)
+	b.fullAt = now.Add(time.Duration((float64(fl.spec.Burst) - b.tokens) / fl.spec.Rate * float64(time.Second)))
+}
+
+// prune drops the buckets which are full again, they are created full.
+func (fl *failureLimiter) prune(now time.Time) {
+	if now.Sub(fl.pruned) < pruneInterval {
+		return
+	}
+	fl.pruned = now
+
+	for ip, b := range fl.buckets {
+		if now.After(b.fullAt) {
+			delete(fl.buckets, ip)
+		}
+	}
+}
+
+// clientIP returns the IP of the client of the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
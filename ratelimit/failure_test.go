@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/auth"
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+// lookups counts the API keys looked up.
+type lookups struct {
+	auth.KeyStore
+	n int
+}
+
+func (l *lookups) Lookup(hash string) (auth.Key, error) {
+	l.n++
+	return l.KeyStore.Lookup(hash)
+}
+
+func TestFailureLimiterThrottlesInvalidKeys(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	keys := &lookups{KeyStore: auth.NewMemoryKeyStore()}
+	require.NoError(t, keys.Seed([]auth.Key{{ID: "1", Name: "web", Hash: auth.Hash("web-secret"), Scopes: []string{auth.ScopeRead}}}))
+	rules, err := auth.NewRules([]config.RouteSpec{{Path: "/stock/*", Scopes: []string{auth.ScopeRead}}})
+	require.NoError(t, err, "Expected no error")
+	authenticator := auth.New(logger, keys, nil, nil, rules)
+
+	now := time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)
+	fl := NewFailureLimiter(logger, config.AuthFailureLimitSpec{Rate: 0.1, Burst: 3}).(*failureLimiter)
+	fl.now = func() time.Time { return now }
+
+	serve := func(key, addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+		r.RemoteAddr = addr
+		r.Header.Set("API-KEY", key)
+		w := httptest.NewRecorder()
+		fl.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+			authenticator.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+		})
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusForbidden, serve("guess", "192.0.2.1:1234").Code)
+	}
+	for i := 0; i < 5; i++ {
+		w := serve("guess", "192.0.2.1:1234")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "10", w.Header().Get("Retry-After"))
+	}
+	assert.Equal(t, 3, keys.n, "Expected the throttled requests not to look up their key")
+
+	assert.Equal(t, http.StatusOK, serve("web-secret", "192.0.2.2:1234").Code, "Expected the other IPs to be served")
+
+	now = now.Add(10 * time.Second)
+	assert.Equal(t, http.StatusOK, serve("web-secret", "192.0.2.1:1234").Code, "Expected a failure to be refilled")
+}
+
+func TestFailureLimiterIgnoresMissingScopes(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	keys := auth.NewMemoryKeyStore()
+	require.NoError(t, keys.Seed([]auth.Key{{ID: "1", Name: "web", Hash: auth.Hash("web-secret"), Scopes: []string{auth.ScopeRead}}}))
+	rules, err := auth.NewRules([]config.RouteSpec{{Path: "/admin/*", Scopes: []string{auth.ScopeAdmin}}})
+	require.NoError(t, err, "Expected no error")
+	authenticator := auth.New(logger, keys, nil, nil, rules)
+	fl := NewFailureLimiter(logger, config.AuthFailureLimitSpec{Rate: 0.1, Burst: 1})
+
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/admin/keys", nil)
+		r.Header.Set("API-KEY", "web-secret")
+		w := httptest.NewRecorder()
+		fl.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+			authenticator.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code, "Expected a valid key missing a scope not to be throttled")
+	}
+}
+
+func TestFailureLimiterDisabled(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	fl := NewFailureLimiter(logger, config.AuthFailureLimitSpec{Rate: 0.1, Burst: 1, Disabled: true})
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		fl.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil), func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	}
+}
@@ -0,0 +1,258 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/auth"
+	"github.com/vikashvverma/stock-backend/config"
+	"github.com/vikashvverma/stock-backend/response"
+	"github.com/vikashvverma/stock-backend/route"
+)
+
+// pruneInterval is how often the idle buckets are dropped.
+const pruneInterval = 10 * time.Minute
+
+// Limiter is the middleware rate limiting requests.
+type Limiter interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+	// Update replaces the limits, keeping the counts. The limits are kept
+	// if specs aren't valid.
+	Update(specs []config.LimitSpec) error
+}
+
+type limit struct {
+	route.Pattern
+	spec config.LimitSpec
+}
+
+type requestLimiter struct {
+	Logger *logrus.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	limits  []limit
+	buckets map[string]*bucket
+	quotas  map[string]int64
+	day     string
+	pruned  time.Time
+}
+
+// New returns a Limiter applying the first of the limits matching a
+// request. Requests are counted per API key once authenticated, per client
+// IP otherwise, so the limiter has to follow the authenticator. The counts
+// are kept in memory, each instance of the service limits on its own.
+func New(l *logrus.Logger, specs []config.LimitSpec) (Limiter, error) {
+	limits, err := newLimits(specs)
+	if err != nil {
+		return nil, fmt.Errorf("new: %s", err)
+	}
+
+	return &requestLimiter{
+		Logger:  l,
+		now:     time.Now,
+		limits:  limits,
+		buckets: map[string]*bucket{},
+		quotas:  map[string]int64{},
+	}, nil
+}
+
+// Validate returns an error if specs aren't valid limits.
+func Validate(specs []config.LimitSpec) error {
+	_, err := newLimits(specs)
+	if err != nil {
+		return fmt.Errorf("validate: %s", err)
+	}
+
+	return nil
+}
+
+func newLimits(specs []config.LimitSpec) ([]limit, error) {
+	var limits []limit
+	for _, spec := range specs {
+		p, err := route.New(spec.Method, spec.Path)
+		if err != nil {
+			return nil, fmt.Errorf("rate limit %s: %s", spec.Path, err)
+		}
+
+		limits = append(limits, limit{Pattern: p, spec: spec})
+	}
+
+	return limits, nil
+}
+
+func (rl *requestLimiter) Update(specs []config.LimitSpec) error {
+	limits, err := newLimits(specs)
+	if err != nil {
+		return fmt.Errorf("update: %s", err)
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// the buckets and quotas are named after the limits, a limit which
+	// changed keeps its counts, its bucket refills up to its new burst.
+	rl.limits = limits
+
+	return nil
+}
+
+func (rl *requestLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	lim, ok := rl.match(r)
+	if !ok {
+		next(w, r)
+		return
+	}
+
+	client := clientID(r)
+	d := rl.take(lim, client)
+
+	if lim.spec.Rate > 0 {
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(lim.spec.Burst))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(d.reset.Unix(), 10))
+	}
+	if lim.spec.DailyQuota > 0 {
+		w.Header().Set("X-RateLimit-Quota-Limit", strconv.FormatInt(lim.spec.DailyQuota, 10))
+		w.Header().Set("X-RateLimit-Quota-Remaining", strconv.FormatInt(d.quotaRemaining, 10))
+	}
+
+	if !d.allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(d.retryAfter.Seconds()))))
+		rl.Logger.WithContext(r.Context()).Warnf("Limiter: %s exceeded the %s of %s", client, d.reason, lim.Path)
+		response.Response{Errors: &response.Error{Reason: d.reason + " exceeded", Code: d.code}}.TooManyRequests(w)
+		return
+	}
+
+	next(w, r)
+}
+
+func (rl *requestLimiter) match(r *http.Request) (limit, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	for _, lim := range rl.limits {
+		if lim.Match(r.Method, r.URL.Path) {
+			return lim, true
+		}
+	}
+
+	return limit{}, false
+}
+
+// decision is the outcome of counting a request.
+type decision struct {
+	allowed        bool
+	reason         string
+	code           string
+	remaining      int
+	reset          time.Time
+	quotaRemaining int64
+	retryAfter     time.Duration
+}
+
+func (rl *requestLimiter) take(lim limit, client string) decision {
+	now := rl.now()
+	name := lim.Method + " " + lim.Path + " " + client
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.prune(now)
+
+	d := decision{allowed: true}
+
+	var b *bucket
+	if lim.spec.Rate > 0 {
+		b = rl.buckets[name]
+		if b == nil {
+			b = &bucket{tokens: float64(lim.spec.Burst), last: now}
+			rl.buckets[name] = b
+		}
+		b.refill(now, lim.spec.Rate, lim.spec.Burst)
+	}
+
+	if lim.spec.DailyQuota > 0 {
+		day := now.UTC().Format("2006-01-02")
+		if day != rl.day {
+			rl.day = day
+			rl.quotas = map[string]int64{}
+		}
+
+		used := rl.quotas[name]
+		if used >= lim.spec.DailyQuota {
+			year, month, date := now.UTC().Date()
+			midnight := time.Date(year, month, date+1, 0, 0, 0, 0, time.UTC)
+
+			d.allowed = false
+			d.reason = "daily quota"
+			d.code = response.CodeQuotaExceeded
+			d.retryAfter = midnight.Sub(now)
+		} else if b == nil || b.tokens >= 1 {
+			used++
+			rl.quotas[name] = used
+		}
+		d.quotaRemaining = lim.spec.DailyQuota - used
+	}
+
+	if b != nil {
+		if d.allowed && b.tokens < 1 {
+			d.allowed = false
+			d.reason = "rate limit"
+			d.code = response.CodeRateLimitExceeded
+			d.retryAfter = time.Duration((1 - b.tokens) / lim.spec.Rate * float64(time.Second))
+		} else if d.allowed {
+			b.tokens--
+		}
+
+		b.fullAt = now.Add(time.Duration((float64(lim.spec.Burst) - b.tokens) / lim.spec.Rate * float64(time.Second)))
+
+		d.remaining = int(b.tokens)
+		d.reset = b.fullAt
+	}
+
+	return d
+}
+
+// prune drops the buckets which are full again, they are created full.
+func (rl *requestLimiter) prune(now time.Time) {
+	if now.Sub(rl.pruned) < pruneInterval {
+		return
+	}
+	rl.pruned = now
+
+	for name, b := range rl.buckets {
+		if now.After(b.fullAt) {
+			delete(rl.buckets, name)
+		}
+	}
+}
+
+// bucket is a token bucket, a request takes a token and tokens are added
+// at the limit rate up to the burst.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	fullAt time.Time
+}
+
+func (b *bucket) refill(now time.Time, rate float64, burst int) {
+	b.tokens = math.Min(b.tokens+now.Sub(b.last).Seconds()*rate, float64(burst))
+	b.last = now
+}
+
+// clientID returns the API key of the request, or its client IP if not
+// authenticated.
+func clientID(r *http.Request) string {
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		return "key:" + identity.ID
+	}
+
+	return "ip:" + clientIP(r)
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/auth"
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+func newLimiter(t *testing.T, now *time.Time, specs ...config.LimitSpec) *requestLimiter {
+	logger, _ := test.NewNullLogger()
+	l, err := New(logger, specs)
+	require.NoError(t, err, "Expected no error")
+
+	rl := l.(*requestLimiter)
+	rl.now = func() time.Time { return *now }
+
+	return rl
+}
+
+func serve(rl *requestLimiter, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	rl.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	return w
+}
+
+func request(path, key string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if key != "" {
+		r = r.WithContext(auth.WithIdentity(r.Context(), auth.Identity{ID: key, Name: key}))
+	}
+
+	return r
+}
+
+func TestServeHTTPRateLimit(t *testing.T) {
+	now := time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)
+	rl := newLimiter(t, &now, config.LimitSpec{Path: "/stock/top/*", Rate: 1, Burst: 2})
+
+	w := serve(rl, request("/stock/top/01-01-2016/01-02-2016", "web"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1546423201", w.Header().Get("X-RateLimit-Reset"))
+
+	w = serve(rl, request("/stock/top/01-01-2016/01-02-2016", "web"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = serve(rl, request("/stock/top/01-01-2016/01-02-2016", "web"))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "rate limit exceeded")
+
+	// other keys and anonymous clients have their own buckets.
+	w = serve(rl, request("/stock/top/01-01-2016/01-02-2016", "other"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serve(rl, request("/stock/top/01-01-2016/01-02-2016", ""))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	now = now.Add(time.Second)
+	w = serve(rl, request("/stock/top/01-01-2016/01-02-2016", "web"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// routes without limits are not counted.
+	w = serve(rl, request("/stock/AAPL", "web"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestServeHTTPDailyQuota(t *testing.T) {
+	now := time.Date(2019, 1, 2, 23, 0, 0, 0, time.UTC)
+	rl := newLimiter(t, &now, config.LimitSpec{Path: "/stock/*", DailyQuota: 2})
+
+	for i := 0; i < 2; i++ {
+		w := serve(rl, request("/stock/AAPL", "web"))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+
+	w := serve(rl, request("/stock/AAPL", "web"))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Quota-Remaining"))
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "daily quota exceeded")
+
+	now = now.Add(time.Hour)
+	w = serve(rl, request("/stock/AAPL", "web"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Quota-Remaining"))
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)
+	rl := newLimiter(t, &now, config.LimitSpec{Path: "/*", Rate: 1, Burst: 1})
+
+	serve(rl, request("/stock/AAPL", "web"))
+	require.Len(t, rl.buckets, 1)
+
+	now = now.Add(pruneInterval)
+	serve(rl, request("/stock/AAPL", "other"))
+
+	assert.Len(t, rl.buckets, 1)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate([]config.LimitSpec{{Path: "/stock/*", Rate: 1, Burst: 1}}))
+
+	err := Validate([]config.LimitSpec{{Path: "/stock/[", Rate: 1, Burst: 1}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "validate: rate limit /stock/[:")
+}
+
+func TestUpdate(t *testing.T) {
+	now := time.Date(2019, 1, 2, 10, 0, 0, 0, time.UTC)
+	rl := newLimiter(t, &now, config.LimitSpec{Path: "/stock/*", Rate: 1, Burst: 1})
+
+	assert.Equal(t, http.StatusOK, serve(rl, request("/stock/AAPL", "web")).Code)
+	assert.Equal(t, http.StatusTooManyRequests, serve(rl, request("/stock/AAPL", "web")).Code)
+
+	err := rl.Update([]config.LimitSpec{{Path: "/stock/[", Rate: 1, Burst: 1}})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, serve(rl, request("/stock/AAPL", "web")).Code, "Expected the limits to be kept")
+
+	err = rl.Update([]config.LimitSpec{{Path: "/stock/top/*", Rate: 1, Burst: 1}})
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, http.StatusOK, serve(rl, request("/stock/AAPL", "web")).Code)
+}
@@ -0,0 +1,59 @@
+package recovery
+
+import (
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/codegangsta/negroni"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/response"
+)
+
+// Recovery is the middleware recovering from panics of the next handlers.
+type Recovery interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+	// Panics returns the number of panics recovered since the start.
+	Panics() int64
+}
+
+type panicRecovery struct {
+	Logger *logrus.Logger
+	panics int64
+}
+
+// New returns a Recovery logging panics with their stack trace and answering
+// with a server error if nothing was written yet.
+func New(l *logrus.Logger) Recovery {
+	return &panicRecovery{Logger: l}
+}
+
+func (pr *panicRecovery) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	defer func() {
+		err := recover()
+		if err == nil {
+			return
+		}
+		// Let net/http abort the response silently, as it does without us.
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+
+		atomic.AddInt64(&pr.panics, 1)
+		pr.Logger.WithContext(r.Context()).
+			WithField("Stack", string(debug.Stack())).
+			Errorf("Recovery: panic serving %s %s: %v", r.Method, r.URL.Path, err)
+
+		if res, ok := w.(negroni.ResponseWriter); ok && res.Written() {
+			return
+		}
+		response.Response{Errors: &response.Error{Reason: "internal server error", Code: response.CodeInternal}}.ServerError(w)
+	}()
+
+	next(w, r)
+}
+
+func (pr *panicRecovery) Panics() int64 {
+	return atomic.LoadInt64(&pr.panics)
+}
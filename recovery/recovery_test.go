@@ -0,0 +1,61 @@
+package recovery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codegangsta/negroni"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/vikashvverma/stock-backend/constants"
+	"github.com/vikashvverma/stock-backend/requestid"
+)
+
+func TestServeHTTP(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	rec := httptest.NewRecorder()
+	w := negroni.NewResponseWriter(rec)
+	recovery := New(logger)
+
+	recovery.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil), func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"success":false,"errors":{"reason":"internal server error","code":"internal"}}`, rec.Body.String())
+	assert.Equal(t, "Recovery: panic serving GET /stock/AAPL: boom", hook.LastEntry().Message)
+	assert.Contains(t, hook.LastEntry().Data["Stack"], "recovery_test.go")
+	assert.Equal(t, int64(1), recovery.Panics())
+}
+
+func TestServeHTTPWithRequestID(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	rec := httptest.NewRecorder()
+	n := negroni.New(requestid.New(), New(logger))
+	n.UseHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+	r.Header.Set(constants.RequestIDHeader, "abc")
+	n.ServeHTTP(rec, r)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"success":false,"errors":{"reason":"internal server error","code":"internal"},"requestId":"abc"}`, rec.Body.String())
+}
+
+func TestServeHTTPWritten(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	rec := httptest.NewRecorder()
+	w := negroni.NewResponseWriter(rec)
+
+	New(logger).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("boom")
+	})
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
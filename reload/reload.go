@@ -0,0 +1,181 @@
+package reload
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+// watchInterval is how often the config file is checked for changes.
+const watchInterval = 5 * time.Second
+
+// Reloadable lists the settings applied without restart, other changes are
+// logged and wait for the next restart. CORS origins are out of scope: the
+// service sends no CORS headers, so it has none to reload.
+var Reloadable = map[string]bool{"logLevel": true, "apiKey": true, "apiKeys": true, "rateLimits": true}
+
+// Validator checks a reloaded config before any of it is applied, e.g. that
+// its rate limits compile.
+type Validator func(c *config.Config) error
+
+// Applier applies the reloadable settings of a reloaded config. When a later
+// applier fails it is called again with the current config to roll back, so
+// it applies the whole config and not only the changes.
+type Applier func(c *config.Config) error
+
+// Reloader loads the config again when its file changes or when asked to,
+// on SIGHUP, and applies it. A config which isn't valid is rejected and
+// the current one kept.
+type Reloader struct {
+	load       func() (*config.Config, error)
+	logger     *logrus.Logger
+	validators []Validator
+	appliers   []Applier
+
+	mu       sync.Mutex
+	current  *config.Config
+	modified time.Time
+
+	stop chan struct{}
+}
+
+// New returns a Reloader of c, loaded again with load.
+func New(c *config.Config, load func() (*config.Config, error), l *logrus.Logger) *Reloader {
+	return &Reloader{load: load, logger: l, current: c, stop: make(chan struct{})}
+}
+
+// Validate adds a validator, a config rejected by any validator isn't
+// applied.
+func (r *Reloader) Validate(v Validator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.validators = append(r.validators, v)
+}
+
+// OnReload adds an applier, the appliers are called in order.
+func (r *Reloader) OnReload(a Applier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.appliers = append(r.appliers, a)
+}
+
+// Current returns the config last applied.
+func (r *Reloader) Current() *config.Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.current
+}
+
+// Reload loads the config and applies the settings which changed. The
+// config becomes the current one once every applier succeeded. Otherwise
+// the appliers which succeeded apply the current config again, so none of
+// the config is applied, and the next reload applies it again.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		return fmt.Errorf("reload: invalid config: %s", err)
+	}
+	for _, validate := range r.validators {
+		if err := validate(next); err != nil {
+			return fmt.Errorf("reload: invalid config: %s", err)
+		}
+	}
+
+	changes, err := config.Diff(r.current, next)
+	if err != nil {
+		return fmt.Errorf("reload: %s", err)
+	}
+	if len(changes) == 0 {
+		r.logger.Infof("Reloader: config unchanged")
+		return nil
+	}
+
+	var restart []string
+	for _, change := range changes {
+		if !Reloadable[change.Setting] {
+			restart = append(restart, change.Setting)
+			continue
+		}
+		r.logger.Infof("Reloader: %s", change)
+	}
+	if len(restart) > 0 {
+		r.logger.Warnf("Reloader: changes to %s need a restart", strings.Join(restart, ", "))
+	}
+
+	for i, apply := range r.appliers {
+		if err := apply(next); err != nil {
+			r.logger.WithError(err).Errorf("Reloader: unable to apply config, rolling back")
+			r.rollback(r.appliers[:i])
+			return fmt.Errorf("reload: %s", err)
+		}
+	}
+	r.current = next
+
+	return nil
+}
+
+// rollback applies the current config again with the appliers, the last one
+// first.
+func (r *Reloader) rollback(appliers []Applier) {
+	for i := len(appliers) - 1; i >= 0; i-- {
+		if err := appliers[i](r.current); err != nil {
+			r.logger.WithError(err).Errorf("Reloader: unable to roll back config")
+		}
+	}
+}
+
+// Watch reloads the config when file changes, until stopped.
+func (r *Reloader) Watch(file string) {
+	if info, err := os.Stat(file); err == nil {
+		r.modified = info.ModTime()
+	}
+
+	go func() {
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.check(file)
+			}
+		}
+	}()
+}
+
+// check reloads the config if file changed since the last check.
+func (r *Reloader) check(file string) {
+	info, err := os.Stat(file)
+	if err != nil {
+		r.logger.WithError(err).Errorf("Reloader: unable to check config file")
+		return
+	}
+	if !info.ModTime().After(r.modified) {
+		return
+	}
+	r.modified = info.ModTime()
+
+	r.logger.Infof("Reloader: %s changed, reloading", file)
+	if err := r.Reload(); err != nil {
+		r.logger.WithError(err).Errorf("Reloader: unable to reload config")
+	}
+}
+
+// Stop stops watching the config file.
+func (r *Reloader) Stop() {
+	close(r.stop)
+}
@@ -0,0 +1,159 @@
+package reload
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+func writeConfig(t *testing.T, name, content string) {
+	require.NoError(t, ioutil.WriteFile(name, []byte(content), 0600))
+}
+
+func newReloader(t *testing.T, content string) (*Reloader, string, *test.Hook) {
+	f, err := ioutil.TempFile("", "config")
+	require.NoError(t, err, "Expected no error")
+	f.Close()
+	writeConfig(t, f.Name(), content)
+
+	load := func() (*config.Config, error) {
+		return config.Load([]string{"cmd", "-config", f.Name()}, nil)
+	}
+	c, err := load()
+	require.NoError(t, err, "Expected no error")
+
+	logger, hook := test.NewNullLogger()
+
+	return New(c, load, logger), f.Name(), hook
+}
+
+func TestReload(t *testing.T) {
+	r, name, hook := newReloader(t, `{"dbServer": "baz", "logLevel": "info"}`)
+	defer os.Remove(name)
+
+	var applied *config.Config
+	r.OnReload(func(c *config.Config) error {
+		applied = c
+		return nil
+	})
+
+	writeConfig(t, name, `{"dbServer": "other", "logLevel": "debug", "rateLimits": [{"path": "/stock/*", "rate": 1, "burst": 1}]}`)
+	require.NoError(t, r.Reload(), "Expected no error")
+
+	require.NotNil(t, applied)
+	assert.Equal(t, int(logrus.DebugLevel), applied.LogLevel())
+	assert.Equal(t, applied, r.Current())
+
+	var messages []string
+	for _, e := range hook.AllEntries() {
+		messages = append(messages, e.Message)
+	}
+	assert.Contains(t, messages, `Reloader: logLevel: "info" -> "debug"`)
+	assert.Contains(t, messages, "Reloader: changes to dbServer need a restart")
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	r, name, _ := newReloader(t, `{"dbServer": "baz"}`)
+	defer os.Remove(name)
+	current := r.Current()
+
+	r.OnReload(func(c *config.Config) error {
+		t.Fatal("Expected the invalid config not to be applied")
+		return nil
+	})
+
+	writeConfig(t, name, `{"dbServer": "baz", "rateLimits": [{"path": "stock"}]}`)
+	err := r.Reload()
+
+	assert.Contains(t, err.Error(), "reload: invalid config:")
+	assert.Equal(t, current, r.Current())
+}
+
+func TestReloadValidatesBeforeApplying(t *testing.T) {
+	r, name, _ := newReloader(t, `{"dbServer": "baz"}`)
+	defer os.Remove(name)
+	current := r.Current()
+
+	r.Validate(func(c *config.Config) error {
+		if c.LogLevel() == int(logrus.DebugLevel) {
+			return errors.New("debug not allowed")
+		}
+		return nil
+	})
+	r.OnReload(func(c *config.Config) error {
+		t.Fatal("Expected the rejected config not to be applied")
+		return nil
+	})
+
+	writeConfig(t, name, `{"dbServer": "baz", "logLevel": "debug"}`)
+	err := r.Reload()
+
+	require.Error(t, err)
+	assert.Equal(t, "reload: invalid config: debug not allowed", err.Error())
+	assert.Equal(t, current, r.Current())
+}
+
+func TestReloadKeepsConfigWhenApplierFails(t *testing.T) {
+	r, name, _ := newReloader(t, `{"dbServer": "baz"}`)
+	defer os.Remove(name)
+	current := r.Current()
+
+	var applied []int
+	failing := true
+	r.OnReload(func(c *config.Config) error {
+		applied = append(applied, c.LogLevel())
+		return nil
+	})
+	r.OnReload(func(c *config.Config) error {
+		if failing {
+			return errors.New("key store down")
+		}
+		return nil
+	})
+
+	writeConfig(t, name, `{"dbServer": "baz", "logLevel": "debug"}`)
+	err := r.Reload()
+
+	require.Error(t, err)
+	assert.Equal(t, "reload: key store down", err.Error())
+	assert.Equal(t, current, r.Current())
+	assert.Equal(t, []int{int(logrus.DebugLevel), int(logrus.InfoLevel)}, applied, "Expected the current config to be applied back")
+
+	// the next reload applies the changes again.
+	failing = false
+	require.NoError(t, r.Reload(), "Expected no error")
+	assert.Equal(t, []int{int(logrus.DebugLevel), int(logrus.InfoLevel), int(logrus.DebugLevel)}, applied)
+	assert.Equal(t, int(logrus.DebugLevel), r.Current().LogLevel())
+}
+
+func TestCheck(t *testing.T) {
+	r, name, _ := newReloader(t, `{"dbServer": "baz"}`)
+	defer os.Remove(name)
+	r.Watch(name)
+	defer r.Stop()
+
+	reloads := 0
+	r.OnReload(func(c *config.Config) error {
+		reloads++
+		return nil
+	})
+
+	r.check(name)
+	assert.Equal(t, 0, reloads, "Expected no reload of an unchanged file")
+
+	writeConfig(t, name, `{"dbServer": "baz", "logLevel": "error"}`)
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(name, later, later))
+	r.check(name)
+
+	assert.Equal(t, 1, reloads)
+}
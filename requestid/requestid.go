@@ -0,0 +1,58 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/vikashvverma/stock-backend/constants"
+)
+
+// Middleware gives every request an ID.
+type Middleware interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+}
+
+type requestIDMiddleware struct{}
+
+// validID matches the incoming request IDs which are kept.
+var validID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// New returns a Middleware giving every request an ID, kept in the request
+// context and sent in the X-Request-ID response header. A valid incoming
+// X-Request-ID is kept, e.g. the one of a proxy, otherwise an ID is
+// generated.
+func New() Middleware {
+	return &requestIDMiddleware{}
+}
+
+type idKey struct{}
+
+// FromContext returns the request ID held by ctx, empty if none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+// WithID returns a copy of ctx holding the request ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+func (m *requestIDMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	id := r.Header.Get(constants.RequestIDHeader)
+	if !validID.MatchString(id) {
+		id = newID()
+	}
+	w.Header().Set(constants.RequestIDHeader, id)
+
+	next(w, r.WithContext(WithID(r.Context(), id)))
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
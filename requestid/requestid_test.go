@@ -0,0 +1,45 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeHTTP(t *testing.T) {
+	m := New()
+	w := httptest.NewRecorder()
+
+	var id string
+	m.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil), func(w http.ResponseWriter, r *http.Request) {
+		id = FromContext(r.Context())
+	})
+
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, w.Header().Get("X-Request-ID"))
+}
+
+func TestServeHTTPKeepsIncomingID(t *testing.T) {
+	m := New()
+
+	for incoming, kept := range map[string]bool{
+		"proxy-1234.5:6":       true,
+		"":                     false,
+		"bad id\nwith newline": false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+		r.Header.Set("X-Request-ID", incoming)
+		w := httptest.NewRecorder()
+
+		var id string
+		m.ServeHTTP(w, r, func(w http.ResponseWriter, r *http.Request) {
+			id = FromContext(r.Context())
+		})
+
+		assert.Equal(t, kept, id == incoming, incoming)
+		assert.NotEmpty(t, id)
+		assert.Equal(t, id, w.Header().Get("X-Request-ID"))
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+
+	"github.com/vikashvverma/stock-backend/constants"
 )
 
 // Response represent the response to be sent for an API call.
@@ -11,13 +13,39 @@ type Response struct {
 	Success bool        `json:"success"`
 	Result  interface{} `json:"result,omitempty"`
 	Errors  *Error      `json:"errors,omitempty"`
+	// RequestID is set on error responses from the request ID header of
+	// the response.
+	RequestID string `json:"requestId,omitempty"`
 }
 
 // Error represents an error to be sent to the client.
 type Error struct {
 	Reason string `json:"reason,omitempty"`
+	// Code is a machine readable identifier of the error.
+	Code string `json:"code,omitempty"`
+	// Field is the request parameter the error is about.
+	Field   string            `json:"field,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
 }
 
+// Error codes besides the stock error kinds.
+const (
+	CodeInvalidParam      = "invalid_param"
+	CodeInternal          = "internal"
+	CodeForbidden         = "forbidden"
+	CodeRouteNotFound     = "route_not_found"
+	CodeMethodNotAllowed  = "method_not_allowed"
+	CodeRateLimitExceeded = "rate_limit_exceeded"
+	CodeQuotaExceeded     = "quota_exceeded"
+	// CodeUnavailable and CodeNotFound match the stock error kinds for the
+	// other dependencies and resources, like API keys or jobs.
+	CodeUnavailable = "unavailable"
+	CodeNotFound    = "not_found"
+	// CodeConflict is for a request the resource can't take in its state,
+	// like canceling a job which is done.
+	CodeConflict = "conflict"
+)
+
 // Send writes a successful response to the given http.ResponseWriter.
 func (s Response) Send(w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -32,6 +60,7 @@ func (s Response) Send(w http.ResponseWriter) error {
 
 // ServerError writes a server error response to the given http.ResponseWriter.
 func (s Response) ServerError(w http.ResponseWriter) error {
+	s = s.withRequestID(w)
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(http.StatusInternalServerError)
 
@@ -45,6 +74,7 @@ func (s Response) ServerError(w http.ResponseWriter) error {
 
 // ClientError writes a client error response to the given http.ResponseWriter.
 func (s Response) ClientError(w http.ResponseWriter) error {
+	s = s.withRequestID(w)
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(http.StatusBadRequest)
 
@@ -56,16 +86,159 @@ func (s Response) ClientError(w http.ResponseWriter) error {
 	return nil
 }
 
+// Accepted writes a response for a request which is processed in the
+// background to the given http.ResponseWriter.
+func (s Response) Accepted(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		return fmt.Errorf("accepted: could not write JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// Created writes a response for a created resource to the given
+// http.ResponseWriter.
+func (s Response) Created(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		return fmt.Errorf("created: could not write JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// NotFound writes a not found error response to the given http.ResponseWriter.
+func (s Response) NotFound(w http.ResponseWriter) error {
+	s = s.withRequestID(w)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		return fmt.Errorf("notFound: could not write JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// Conflict writes an error response to the given http.ResponseWriter for a
+// request conflicting with the state of the resource.
+func (s Response) Conflict(w http.ResponseWriter) error {
+	s = s.withRequestID(w)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusConflict)
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		return fmt.Errorf("conflict: could not write JSON response: %s", err)
+	}
 
+	return nil
+}
 
-func (e Error) Forbidden(w http.ResponseWriter) error {
+// ServiceUnavailable writes an unavailable dependency error response to the
+// given http.ResponseWriter.
+func (s Response) ServiceUnavailable(w http.ResponseWriter) error {
+	s = s.withRequestID(w)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		return fmt.Errorf("serviceUnavailable: could not write JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// GatewayTimeout writes a timed out dependency error response to the given
+// http.ResponseWriter.
+func (s Response) GatewayTimeout(w http.ResponseWriter) error {
+	s = s.withRequestID(w)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusGatewayTimeout)
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		return fmt.Errorf("gatewayTimeout: could not write JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// PayloadTooLarge writes a request body too large error response to the
+// given http.ResponseWriter.
+func (s Response) PayloadTooLarge(w http.ResponseWriter) error {
+	s = s.withRequestID(w)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		return fmt.Errorf("payloadTooLarge: could not write JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// TooManyRequests writes a rate limited error response to the given
+// http.ResponseWriter.
+func (s Response) TooManyRequests(w http.ResponseWriter) error {
+	s = s.withRequestID(w)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		return fmt.Errorf("tooManyRequests: could not write JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// Forbidden writes a forbidden error response to the given
+// http.ResponseWriter.
+func (s Response) Forbidden(w http.ResponseWriter) error {
+	s = s.withRequestID(w)
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(http.StatusForbidden)
 
-	err := json.NewEncoder(w).Encode(e)
+	err := json.NewEncoder(w).Encode(s)
 	if err != nil {
-		return fmt.Errorf("unauthorized: could not write JSON response: %s", err)
+		return fmt.Errorf("forbidden: could not write JSON response: %s", err)
 	}
 
 	return nil
 }
+
+// MethodNotAllowed writes a method not allowed error response to the given
+// http.ResponseWriter.
+func (s Response) MethodNotAllowed(w http.ResponseWriter) error {
+	s = s.withRequestID(w)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusMethodNotAllowed)
+
+	err := json.NewEncoder(w).Encode(s)
+	if err != nil {
+		return fmt.Errorf("methodNotAllowed: could not write JSON response: %s", err)
+	}
+
+	return nil
+}
+
+// withRequestID returns s with the request ID set by the request ID
+// middleware on the response headers.
+func (s Response) withRequestID(w http.ResponseWriter) Response {
+	if s.RequestID == "" {
+		s.RequestID = w.Header().Get(constants.RequestIDHeader)
+	}
+
+	return s
+}
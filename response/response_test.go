@@ -66,3 +66,174 @@ func TestClientError(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
 	assert.Equal(t, e, response)
 }
+
+func TestAccepted(t *testing.T) {
+	s := Response{Success: true, Result: "queued"}
+	w := httptest.NewRecorder()
+
+	err := s.Accepted(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	result := w.Result()
+	var response Response
+	err = json.NewDecoder(result.Body).Decode(&response)
+	require.NoError(t, err, "Expected no error reading response body")
+
+	assert.Equal(t, "application/json; charset=utf-8", result.Header.Get("Content-Type"))
+	assert.Equal(t, http.StatusAccepted, result.StatusCode)
+	assert.Equal(t, s, response)
+}
+
+func TestCreated(t *testing.T) {
+	s := Response{Success: true, Result: "created"}
+	w := httptest.NewRecorder()
+
+	err := s.Created(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	result := w.Result()
+	var response Response
+	err = json.NewDecoder(result.Body).Decode(&response)
+	require.NoError(t, err, "Expected no error reading response body")
+
+	assert.Equal(t, "application/json; charset=utf-8", result.Header.Get("Content-Type"))
+	assert.Equal(t, http.StatusCreated, result.StatusCode)
+	assert.Equal(t, s, response)
+}
+
+func TestNotFound(t *testing.T) {
+	e := Response{Errors: &Error{Reason: "job not found"}}
+	w := httptest.NewRecorder()
+
+	err := e.NotFound(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	result := w.Result()
+	var response Response
+	err = json.NewDecoder(result.Body).Decode(&response)
+	require.NoError(t, err, "Expected no error reading response body")
+
+	assert.Equal(t, "application/json; charset=utf-8", result.Header.Get("Content-Type"))
+	assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	assert.Equal(t, e, response)
+}
+
+func TestConflict(t *testing.T) {
+	e := Response{Errors: &Error{Reason: "job 1 is done", Code: CodeConflict}}
+	w := httptest.NewRecorder()
+
+	err := e.Conflict(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	result := w.Result()
+	var response Response
+	err = json.NewDecoder(result.Body).Decode(&response)
+	require.NoError(t, err, "Expected no error reading response body")
+
+	assert.Equal(t, "application/json; charset=utf-8", result.Header.Get("Content-Type"))
+	assert.Equal(t, http.StatusConflict, result.StatusCode)
+	assert.Equal(t, e, response)
+}
+
+func TestTooManyRequests(t *testing.T) {
+	e := Response{Errors: &Error{Reason: "rate limit exceeded"}}
+	w := httptest.NewRecorder()
+
+	err := e.TooManyRequests(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	result := w.Result()
+	var response Response
+	err = json.NewDecoder(result.Body).Decode(&response)
+	require.NoError(t, err, "Expected no error reading response body")
+
+	assert.Equal(t, "application/json; charset=utf-8", result.Header.Get("Content-Type"))
+	assert.Equal(t, http.StatusTooManyRequests, result.StatusCode)
+	assert.Equal(t, e, response)
+}
+
+func TestServiceUnavailable(t *testing.T) {
+	e := Response{Errors: &Error{Reason: "stock data unavailable", Code: "unavailable"}}
+	w := httptest.NewRecorder()
+
+	err := e.ServiceUnavailable(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	result := w.Result()
+	var response Response
+	err = json.NewDecoder(result.Body).Decode(&response)
+	require.NoError(t, err, "Expected no error reading response body")
+
+	assert.Equal(t, "application/json; charset=utf-8", result.Header.Get("Content-Type"))
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+	assert.Equal(t, e, response)
+}
+
+func TestGatewayTimeout(t *testing.T) {
+	e := Response{Errors: &Error{Reason: "stock data query timed out", Code: "timeout"}}
+	w := httptest.NewRecorder()
+
+	err := e.GatewayTimeout(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	result := w.Result()
+	var response Response
+	err = json.NewDecoder(result.Body).Decode(&response)
+	require.NoError(t, err, "Expected no error reading response body")
+
+	assert.Equal(t, "application/json; charset=utf-8", result.Header.Get("Content-Type"))
+	assert.Equal(t, http.StatusGatewayTimeout, result.StatusCode)
+	assert.Equal(t, e, response)
+}
+
+func TestErrorFields(t *testing.T) {
+	e := Response{Errors: &Error{Reason: "invalid range", Code: "invalid_range", Field: "from",
+		Details: map[string]string{"from": "2016-02-01", "to": "2016-01-01"}}}
+	w := httptest.NewRecorder()
+
+	err := e.ClientError(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	assert.JSONEq(t, `{"success":false,"errors":{"reason":"invalid range","code":"invalid_range","field":"from",
+		"details":{"from":"2016-02-01","to":"2016-01-01"}}}`, w.Body.String())
+}
+
+func TestForbidden(t *testing.T) {
+	e := Response{Errors: &Error{Reason: "forbidden", Code: "forbidden"}}
+	w := httptest.NewRecorder()
+
+	err := e.Forbidden(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"success":false,"errors":{"reason":"forbidden","code":"forbidden"}}`, w.Body.String())
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	e := Response{Errors: &Error{Reason: "method not allowed: PUT", Code: "method_not_allowed"}}
+	w := httptest.NewRecorder()
+
+	err := e.MethodNotAllowed(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.JSONEq(t, `{"success":false,"errors":{"reason":"method not allowed: PUT","code":"method_not_allowed"}}`, w.Body.String())
+}
+
+func TestErrorRequestID(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", "0af7651916cd43dd8448eb211c80319c")
+
+	err := Response{Errors: &Error{Reason: "invalid request"}}.ClientError(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	assert.JSONEq(t, `{"success":false,"errors":{"reason":"invalid request"},"requestId":"0af7651916cd43dd8448eb211c80319c"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	w.Header().Set("X-Request-ID", "0af7651916cd43dd8448eb211c80319c")
+
+	err = Response{Success: true}.Send(w)
+	require.NoError(t, err, "Expected no error writing JSON response")
+
+	assert.JSONEq(t, `{"success":true}`, w.Body.String())
+}
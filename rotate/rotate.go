@@ -0,0 +1,311 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTime formats the time of a backup in its name, names sort by time.
+const backupTime = "20060102T150405.000"
+
+// Options configures the rotation of a File.
+type Options struct {
+	// MaxSize is the size in bytes rotating the file, unlimited when 0.
+	MaxSize int64
+	// Interval rotates the file when a period starts, like every UTC day
+	// for 24h. No rotation by time when 0.
+	Interval time.Duration
+	// MaxBackups is the number of backups kept, all when 0.
+	MaxBackups int
+	// MaxAge is how long backups are kept, forever when 0.
+	MaxAge time.Duration
+	// Compress gzips the backups.
+	Compress bool
+}
+
+// File is a file appended to which is renamed to a timestamped backup and
+// created again when it gets too big or old. It is safe for concurrent use.
+type File struct {
+	name string
+	opts Options
+	now  func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time
+	closed bool
+
+	// cleaning is held while the backups are compressed and pruned.
+	cleaning sync.Mutex
+	cleaned  sync.WaitGroup
+}
+
+// Open opens the file name for appending, creating it if needed.
+func Open(name string, o Options) (*File, error) {
+	f := &File{name: name, opts: o, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, fmt.Errorf("open: %s", err)
+	}
+
+	return f, nil
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.period = f.periodOf(f.now())
+
+	return nil
+}
+
+func (f *File) periodOf(t time.Time) time.Time {
+	if f.opts.Interval <= 0 {
+		return time.Time{}
+	}
+
+	return t.UTC().Truncate(f.opts.Interval)
+}
+
+// Write appends p to the file, rotating it first if p would make it too
+// big or a new period started.
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, fmt.Errorf("write: %s is closed", f.name)
+	}
+	if f.file == nil {
+		// a rotation was unable to create the file again.
+		if err := f.open(); err != nil {
+			return 0, fmt.Errorf("write: unable to open %s: %s", f.name, err)
+		}
+	}
+
+	period := f.periodOf(f.now())
+	if f.size == 0 {
+		f.period = period
+	}
+
+	tooBig := f.opts.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.opts.MaxSize
+	if tooBig || period != f.period {
+		if err := f.rotate(); err != nil {
+			return 0, fmt.Errorf("write: unable to rotate %s: %s", f.name, err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Rotate renames the file to a backup and creates it again.
+func (f *File) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return fmt.Errorf("rotate: %s is closed", f.name)
+	}
+	if f.file == nil {
+		return f.open()
+	}
+
+	return f.rotate()
+}
+
+func (f *File) rotate() error {
+	err := f.file.Close()
+	f.file = nil
+	if err != nil {
+		return err
+	}
+
+	backup := f.backupName(f.now())
+	if err := os.Rename(f.name, backup); err != nil && !os.IsNotExist(err) {
+		// keep writing to the file rather than losing logs, the rotation is
+		// tried again once MaxSize more bytes are written or the next
+		// period starts.
+		if err := f.open(); err != nil {
+			return err
+		}
+		fmt.Fprintf(f.file, "rotate: unable to rename %s: %s\n", f.name, err)
+		f.size = 0
+
+		return nil
+	}
+
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.cleaned.Add(1)
+	go f.clean(backup)
+
+	return nil
+}
+
+// backupName returns the name of a backup made at t: the name of the file
+// with the time before its extension, like stock-20190102T150405.000.log.
+func (f *File) backupName(t time.Time) string {
+	ext := filepath.Ext(f.name)
+
+	return strings.TrimSuffix(f.name, ext) + "-" + t.UTC().Format(backupTime) + ext
+}
+
+// clean compresses the new backup and removes the old ones. Errors are
+// written to the file itself, there is nowhere else to report them.
+func (f *File) clean(backup string) {
+	defer f.cleaned.Done()
+
+	f.cleaning.Lock()
+	defer f.cleaning.Unlock()
+
+	if f.opts.Compress {
+		if err := compress(backup); err != nil {
+			fmt.Fprintf(f, "rotate: unable to compress %s: %s\n", backup, err)
+		}
+	}
+
+	if err := f.prune(); err != nil {
+		fmt.Fprintf(f, "rotate: unable to remove old backups: %s\n", err)
+	}
+}
+
+// backup is a rotated file.
+type backup struct {
+	name string
+	made time.Time
+}
+
+// backups returns the backups of the file, the newest first.
+func (f *File) backups() ([]backup, error) {
+	ext := filepath.Ext(f.name)
+	prefix := filepath.Base(strings.TrimSuffix(f.name, ext)) + "-"
+
+	entries, err := ioutil.ReadDir(filepath.Dir(f.name))
+	if err != nil {
+		return nil, err
+	}
+
+	var list []backup
+	for _, e := range entries {
+		stamp := strings.TrimSuffix(strings.TrimSuffix(e.Name(), ".gz"), ext)
+		if e.IsDir() || !strings.HasPrefix(stamp, prefix) {
+			continue
+		}
+		made, err := time.Parse(backupTime, strings.TrimPrefix(stamp, prefix))
+		if err != nil {
+			continue
+		}
+		list = append(list, backup{name: filepath.Join(filepath.Dir(f.name), e.Name()), made: made})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].made.After(list[j].made) })
+
+	return list, nil
+}
+
+// prune removes the backups beyond MaxBackups or older than MaxAge.
+func (f *File) prune() error {
+	list, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	now := f.now()
+	for i, b := range list {
+		tooMany := f.opts.MaxBackups > 0 && i >= f.opts.MaxBackups
+		tooOld := f.opts.MaxAge > 0 && now.Sub(b.made) > f.opts.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(b.name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compress gzips name to name.gz and removes it.
+func compress(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(name + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+
+	return os.Remove(name)
+}
+
+// Sync commits the file to disk.
+func (f *File) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	return f.file.Sync()
+}
+
+// Close waits for the backups being compressed and closes the file.
+func (f *File) Close() error {
+	f.cleaned.Wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
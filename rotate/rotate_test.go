@@ -0,0 +1,155 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "rotate")
+	require.NoError(t, err, "Expected no error")
+
+	return dir
+}
+
+func open(t *testing.T, name string, o Options, now *time.Time) *File {
+	f, err := Open(name, o)
+	require.NoError(t, err, "Expected no error")
+	f.now = func() time.Time { return *now }
+	f.period = f.periodOf(*now)
+
+	return f
+}
+
+func read(t *testing.T, name string) string {
+	content, err := ioutil.ReadFile(name)
+	require.NoError(t, err, "Expected no error")
+
+	return string(content)
+}
+
+func TestOpenAppends(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "stock.log")
+	require.NoError(t, ioutil.WriteFile(name, []byte("before\n"), 0644))
+
+	f, err := Open(name, Options{})
+	require.NoError(t, err, "Expected no error")
+	_, err = f.Write([]byte("after\n"))
+	require.NoError(t, err, "Expected no error")
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, "before\nafter\n", read(t, name))
+}
+
+func TestWriteRotatesBySize(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "stock.log")
+	now := time.Date(2019, 1, 2, 15, 4, 5, 0, time.UTC)
+	f := open(t, name, Options{MaxSize: 10}, &now)
+
+	_, err := f.Write([]byte("12345678\n"))
+	require.NoError(t, err, "Expected no error")
+	_, err = f.Write([]byte("abc\n"))
+	require.NoError(t, err, "Expected no error")
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, "abc\n", read(t, name))
+	assert.Equal(t, "12345678\n", read(t, filepath.Join(dir, "stock-20190102T150405.000.log")))
+}
+
+func TestWriteKeepsLinesWhenRenameFails(t *testing.T) {
+	dir := tempDir(t)
+	defer func() {
+		os.Chmod(dir, 0755)
+		os.RemoveAll(dir)
+	}()
+	name := filepath.Join(dir, "stock.log")
+	now := time.Date(2019, 1, 2, 15, 4, 5, 0, time.UTC)
+	f := open(t, name, Options{MaxSize: 10}, &now)
+
+	_, err := f.Write([]byte("12345678\n"))
+	require.NoError(t, err, "Expected no error")
+	require.NoError(t, os.Chmod(dir, 0555))
+	if os.Geteuid() == 0 {
+		// root renames in read-only directories, a directory in the way of
+		// the backup fails the rename instead.
+		require.NoError(t, os.Chmod(dir, 0755))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "stock-20190102T150405.000.log", "busy"), 0755))
+	}
+
+	n, err := f.Write([]byte("abc\n"))
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, 4, n)
+	_, err = f.Write([]byte("def\n"))
+	require.NoError(t, err, "Expected no error")
+	require.NoError(t, f.Close())
+
+	content := read(t, name)
+	assert.True(t, strings.HasPrefix(content, "12345678\nrotate: unable to rename "+name+": "), content)
+	assert.True(t, strings.HasSuffix(content, "\nabc\ndef\n"), content)
+}
+
+func TestWriteRotatesByInterval(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "stock.log")
+	now := time.Date(2019, 1, 2, 23, 59, 0, 0, time.UTC)
+	f := open(t, name, Options{Interval: 24 * time.Hour, Compress: true}, &now)
+
+	_, err := f.Write([]byte("day 1\n"))
+	require.NoError(t, err, "Expected no error")
+	now = now.Add(2 * time.Minute)
+	_, err = f.Write([]byte("day 2\n"))
+	require.NoError(t, err, "Expected no error")
+	require.NoError(t, f.Close())
+
+	assert.Equal(t, "day 2\n", read(t, name))
+
+	gz, err := os.Open(filepath.Join(dir, "stock-20190103T000100.000.log.gz"))
+	require.NoError(t, err, "Expected no error")
+	defer gz.Close()
+	zr, err := gzip.NewReader(gz)
+	require.NoError(t, err, "Expected no error")
+	content, err := ioutil.ReadAll(zr)
+	require.NoError(t, err, "Expected no error")
+	assert.Equal(t, "day 1\n", string(content))
+}
+
+func TestRotatePrunesBackups(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "stock.log")
+	now := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	f := open(t, name, Options{MaxBackups: 2, MaxAge: 72 * time.Hour}, &now)
+
+	for i := 0; i < 4; i++ {
+		_, err := f.Write([]byte("line\n"))
+		require.NoError(t, err, "Expected no error")
+		require.NoError(t, f.Rotate())
+		f.cleaned.Wait()
+		now = now.Add(time.Hour)
+	}
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "stock-20181201T000000.000.log"), nil, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other.log"), nil, 0644))
+	require.NoError(t, f.prune())
+	require.NoError(t, f.Close())
+
+	list, err := f.backups()
+	require.NoError(t, err, "Expected no error")
+	require.Len(t, list, 2)
+	assert.Equal(t, filepath.Join(dir, "stock-20190102T030000.000.log"), list[0].name)
+	assert.Equal(t, filepath.Join(dir, "stock-20190102T020000.000.log"), list[1].name)
+	_, err = os.Stat(filepath.Join(dir, "other.log"))
+	assert.NoError(t, err, "Expected the other files to be kept")
+}
@@ -0,0 +1,99 @@
+package route
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pattern matches requests by method and path.
+type Pattern struct {
+	Method string
+	Path   string
+
+	segments []segment
+}
+
+// segment matches one segment of a path.
+type segment struct {
+	// re matches a {name:regexp} template variable, nil for globs.
+	re   *regexp.Regexp
+	glob string
+}
+
+// New parses a path pattern made of segments which are either a
+// gorilla/mux style variable, {name} or {name:regexp}, or a glob like "*"
+// or "*.json". A last "*" segment matches the rest of the path, so
+// "/docs/*" matches "/docs/api/index.html". An empty method matches any
+// method.
+func New(method, pattern string) (Pattern, error) {
+	var segments []segment
+	for _, part := range split(pattern) {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			expr := "[^/]+"
+			if i := strings.Index(part, ":"); i >= 0 {
+				expr = part[i+1 : len(part)-1]
+			}
+
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if err != nil {
+				return Pattern{}, fmt.Errorf("invalid variable %s: %s", part, err)
+			}
+
+			segments = append(segments, segment{re: re})
+			continue
+		}
+
+		if _, err := path.Match(part, ""); err != nil {
+			return Pattern{}, fmt.Errorf("invalid glob %s: %s", part, err)
+		}
+
+		segments = append(segments, segment{glob: part})
+	}
+
+	return Pattern{Method: strings.ToUpper(method), Path: pattern, segments: segments}, nil
+}
+
+// Match tells whether the pattern matches the method and the path, the path
+// is cleaned first.
+func (p Pattern) Match(method, urlPath string) bool {
+	if p.Method != "" && p.Method != method {
+		return false
+	}
+
+	parts := split(urlPath)
+	for i, s := range p.segments {
+		last := i == len(p.segments)-1
+		if last && s.re == nil && s.glob == "*" {
+			return len(parts) > i
+		}
+
+		if i >= len(parts) {
+			return false
+		}
+
+		if s.re != nil {
+			if !s.re.MatchString(parts[i]) {
+				return false
+			}
+			continue
+		}
+
+		if ok, _ := path.Match(s.glob, parts[i]); !ok {
+			return false
+		}
+	}
+
+	return len(parts) == len(p.segments)
+}
+
+// split returns the segments of a cleaned path.
+func split(p string) []string {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
@@ -0,0 +1,47 @@
+package route
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	for _, tc := range []struct {
+		method  string
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"get", "/healthcheck", "/healthcheck", true},
+		{"GET", "/healthcheck", "/healthcheck/", true},
+		{"GET", "/healthcheck", "/healthcheck/foo", false},
+		{"", "/healthcheck", "/healthcheck", true},
+		{"POST", "/healthcheck", "/healthcheck", false},
+		{"", "/docs/*", "/docs/api/index.html", true},
+		{"", "/docs/*", "/docs", false},
+		{"", "/docs/*", "/stock/../docs/x", true},
+		{"", "/stock/top/{from}/{to}", "/stock/top/01-01-2016/01-02-2016", true},
+		{"", "/stock/top/{from}/{to}", "/stock/top/01-01-2016", false},
+		{"", "/stock/{name:[A-Z]+}", "/stock/AAPL", true},
+		{"", "/stock/{name:[A-Z]+}", "/stock/aapl", false},
+		{"", "/files/*.json", "/files/a.json", true},
+		{"", "/files/*.json", "/files/a.csv", false},
+		{"", "/files/*/raw", "/files/a/raw", true},
+	} {
+		p, err := New(tc.method, tc.pattern)
+		require.NoError(t, err, "Expected no error")
+
+		assert.Equal(t, tc.match, p.Match(http.MethodGet, tc.path), "%s %s", tc.pattern, tc.path)
+	}
+}
+
+func TestNewFails(t *testing.T) {
+	_, err := New("", "/stock/{name:[}")
+	assert.Contains(t, err.Error(), "invalid variable {name:[}:")
+
+	_, err = New("", "/stock/[a")
+	assert.Contains(t, err.Error(), "invalid glob [a:")
+}
@@ -0,0 +1,27 @@
+package route
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Unmatched is the template of the requests no route matches.
+const Unmatched = "unmatched"
+
+// Template returns the path template of the router route matching r, like
+// "/stock/{name}", or Unmatched. Unlike the path, the number of templates
+// is bounded, so they can label metrics and name spans.
+func Template(router *mux.Router, r *http.Request) string {
+	var match mux.RouteMatch
+	if !router.Match(r, &match) || match.MatchErr != nil || match.Route == nil {
+		return Unmatched
+	}
+
+	template, err := match.Route.GetPathTemplate()
+	if err != nil {
+		return Unmatched
+	}
+
+	return template
+}
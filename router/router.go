@@ -10,18 +10,38 @@ import (
 	"github.com/vikashvverma/stock-backend/factory"
 	"github.com/vikashvverma/stock-backend/handler"
 	"github.com/vikashvverma/stock-backend/healthcheck"
+	"github.com/vikashvverma/stock-backend/log"
 )
 
 // Router returns the router for all the API handler.
 func Router(f factory.Factory, c *config.Config, l *logrus.Logger) *mux.Router {
 	l.Out = c.LogFile()
 	l.Level = logrus.Level(c.LogLevel())
+	l.Formatter = log.Formatter(c.LogFormat())
 
 	router := mux.NewRouter()
+	router.NotFoundHandler = handler.RouteNotFound(f, l)
+	router.MethodNotAllowedHandler = handler.MethodNotAllowed(f, l)
 	router.HandleFunc("/healthcheck", healthcheck.Self).Methods(http.MethodGet)
+	router.Handle("/readiness", f.Readiness()).Methods(http.MethodGet)
+	router.Handle("/metrics", f.Metrics().Handler()).Methods(http.MethodGet)
 	router.HandleFunc("/stock/{name}", handler.Find(f.Trader(), f, l)).Methods(http.MethodGet)
 	router.HandleFunc("/stock/{from}/{to}", handler.FindList(f.Trader(), f, l)).Queries("ticker", "{ticker}").Methods(http.MethodGet)
 	router.HandleFunc("/stock/top/{from}/{to}", handler.Top(f.Trader(), f, l)).Methods(http.MethodGet)
 
+	admin := router.PathPrefix("/admin").Subrouter()
+	admin.HandleFunc("/indexes", handler.Indexes(f.Indexer(), f, l)).Methods(http.MethodGet)
+	admin.HandleFunc("/upload/companies", handler.UploadCompanies(f.Ingester(), f, l)).Methods(http.MethodPost)
+	admin.HandleFunc("/upload/prices", handler.UploadPrices(f.Ingester(), f, l)).Methods(http.MethodPost)
+	admin.HandleFunc("/jobs", handler.Jobs(f.Runner(), f, l)).Methods(http.MethodGet)
+	admin.HandleFunc("/jobs/{id}", handler.Job(f.Runner(), f, l)).Methods(http.MethodGet)
+	admin.HandleFunc("/jobs/{id}/cancel", handler.CancelJob(f.Runner(), f, l)).Methods(http.MethodPost)
+	admin.HandleFunc("/keys", handler.Keys(f.KeyStore(), f, l)).Methods(http.MethodGet)
+	admin.HandleFunc("/keys", handler.CreateKey(f.KeyStore(), f, l)).Methods(http.MethodPost)
+	admin.HandleFunc("/keys/{id}/rotate", handler.RotateKey(f.KeyStore(), f, l)).Methods(http.MethodPost)
+	admin.HandleFunc("/keys/{id}/disable", handler.DisableKey(f.KeyStore(), f, l)).Methods(http.MethodPost)
+	admin.HandleFunc("/keys/{id}", handler.DeleteKey(f.KeyStore(), f, l)).Methods(http.MethodDelete)
+	admin.HandleFunc("/audit", handler.Audit(f.AuditStore(), f, l)).Methods(http.MethodGet)
+
 	return router
 }
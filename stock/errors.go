@@ -0,0 +1,103 @@
+package stock
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Error kinds, also used as the error codes of the API.
+const (
+	// KindNotFound is the kind of the errors for unknown stocks.
+	KindNotFound = "not_found"
+	// KindInvalidRange is the kind of the errors for invalid date ranges.
+	KindInvalidRange = "invalid_range"
+	// KindUnavailable is the kind of the errors for a failing database.
+	KindUnavailable = "unavailable"
+	// KindTimeout is the kind of the errors for queries past the deadline
+	// of their context.
+	KindTimeout = "timeout"
+	// KindCanceled is the kind of the errors for queries whose context was
+	// canceled, e.g. by a client going away.
+	KindCanceled = "canceled"
+)
+
+// Error is an error of the Trader the client can act on.
+type Error struct {
+	Kind    string
+	Message string
+	// Field is the invalid parameter, if any.
+	Field   string
+	Details map[string]string
+	// Err is the underlying error, not shown to clients.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s", e.Message, e.Err)
+	}
+
+	return e.Message
+}
+
+// NotFound returns the error for the stock with the given name.
+func NotFound(name string) error {
+	return &Error{
+		Kind:    KindNotFound,
+		Message: fmt.Sprintf("stock not found: %s", name),
+		Details: map[string]string{"name": name},
+	}
+}
+
+// InvalidRange returns the error for a from date after the to date.
+func InvalidRange(from, to time.Time) error {
+	f, t := from.Format("2006-01-02"), to.Format("2006-01-02")
+
+	return &Error{
+		Kind:    KindInvalidRange,
+		Message: fmt.Sprintf("invalid range: %s is after %s", f, t),
+		Field:   "from",
+		Details: map[string]string{"from": f, "to": t},
+	}
+}
+
+// Unavailable returns the error for a failing database operation.
+func Unavailable(op string, err error) error {
+	return &Error{
+		Kind:    KindUnavailable,
+		Message: "stock data unavailable",
+		Err:     fmt.Errorf("%s: %s", op, err),
+	}
+}
+
+// Timeout returns the error for a database operation past its deadline.
+func Timeout(op string, err error) error {
+	return &Error{
+		Kind:    KindTimeout,
+		Message: "stock data query timed out",
+		Err:     fmt.Errorf("%s: %s", op, err),
+	}
+}
+
+// Canceled returns the error for a canceled database operation.
+func Canceled(op string, err error) error {
+	return &Error{
+		Kind:    KindCanceled,
+		Message: "stock data query canceled",
+		Err:     fmt.Errorf("%s: %s", op, err),
+	}
+}
+
+// dbError returns the error for a failing database operation, telling
+// timeouts and cancellations of ctx from database failures.
+func dbError(ctx context.Context, op string, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return Timeout(op, err)
+	case context.Canceled:
+		return Canceled(op, err)
+	default:
+		return Unavailable(op, err)
+	}
+}
@@ -0,0 +1,170 @@
+package stock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Indexer manages the indexes backing the Trader queries.
+type Indexer interface {
+	Ensure() error
+	Stats() ([]IndexStat, error)
+}
+
+// IndexStat describes an index and how often it has been used.
+type IndexStat struct {
+	Collection string      `json:"collection"`
+	Name       string      `json:"name"`
+	Keys       interface{} `json:"keys"`
+	Unique     bool        `json:"unique,omitempty"`
+	Ops        int64       `json:"ops"`
+	Since      time.Time   `json:"since,omitempty"`
+}
+
+type stockIndexer struct {
+	DB          *mongo.Database
+	Collections Collections
+}
+
+// NewIndexer returns an Indexer for the company and price collections of db.
+func NewIndexer(db *mongo.Database, c Collections) Indexer {
+	return &stockIndexer{DB: db, Collections: c}
+}
+
+// CompanyIndexes returns the index definitions for the company collection: a
+// unique symbol and the name used by Find and a text index for search.
+func CompanyIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "symbol", Value: 1}},
+			Options: options.Index().SetName("symbol").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "name", Value: 1}},
+			Options: options.Index().SetName("name"),
+		},
+		{
+			Keys:    bson.D{{Key: "name", Value: "text"}, {Key: "symbol", Value: "text"}},
+			Options: options.Index().SetName("search"),
+		},
+	}
+}
+
+// PriceIndexes returns the index definitions for the price collection: one
+// price point per symbol and day serving Find and FindAll, and the date
+// used by the Top aggregation.
+func PriceIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "symbol", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetName("symbol_date").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "date", Value: 1}, {Key: "symbol", Value: 1}},
+			Options: options.Index().SetName("date_symbol"),
+		},
+	}
+}
+
+func (s *stockIndexer) indexes() map[string][]mongo.IndexModel {
+	return map[string][]mongo.IndexModel{
+		s.Collections.Company: CompanyIndexes(),
+		s.Collections.Price:   PriceIndexes(),
+	}
+}
+
+// Ensure creates the indexes which do not exist yet.
+func (s *stockIndexer) Ensure() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for name, models := range s.indexes() {
+		_, err := s.DB.Collection(name).Indexes().CreateMany(ctx, models)
+		if err != nil {
+			return fmt.Errorf("ensure: unable to create indexes on %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Stats lists the indexes of the company and price collections along with
+// their usage.
+func (s *stockIndexer) Stats() ([]IndexStat, error) {
+	var res []IndexStat
+	for _, name := range []string{s.Collections.Company, s.Collections.Price} {
+		stats, err := s.collectionStats(name)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, stats...)
+	}
+
+	return res, nil
+}
+
+func (s *stockIndexer) collectionStats(name string) ([]IndexStat, error) {
+	collection := s.DB.Collection(name)
+	ctx := context.Background()
+
+	cur, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("stats: unable to list indexes: %s", err)
+	}
+	defer cur.Close(ctx)
+
+	var res []IndexStat
+	positions := map[string]int{}
+	for cur.Next(ctx) {
+		var index struct {
+			Name   string `bson:"name"`
+			Key    bson.D `bson:"key"`
+			Unique bool   `bson:"unique"`
+		}
+		err = cur.Decode(&index)
+		if err != nil {
+			return nil, fmt.Errorf("stats: error decoding index: %s", err)
+		}
+
+		positions[index.Name] = len(res)
+		res = append(res, IndexStat{Collection: name, Name: index.Name, Keys: index.Key.Map(), Unique: index.Unique})
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$indexStats", Value: bson.D{}}}}
+	statCur, err := collection.Aggregate(ctx, pipeline, options.Aggregate())
+	if err != nil {
+		return nil, fmt.Errorf("stats: unable to get index stats: %s", err)
+	}
+	defer statCur.Close(ctx)
+
+	for statCur.Next(ctx) {
+		var stat struct {
+			Name     string `bson:"name"`
+			Accesses struct {
+				Ops   int64     `bson:"ops"`
+				Since time.Time `bson:"since"`
+			} `bson:"accesses"`
+		}
+		err = statCur.Decode(&stat)
+		if err != nil {
+			return nil, fmt.Errorf("stats: error decoding index stats: %s", err)
+		}
+
+		i, ok := positions[stat.Name]
+		if !ok {
+			continue
+		}
+		// with several mongod hosts every host reports its own counters.
+		res[i].Ops += stat.Accesses.Ops
+		if res[i].Since.IsZero() || stat.Accesses.Since.Before(res[i].Since) {
+			res[i].Since = stat.Accesses.Since
+		}
+	}
+
+	return res, nil
+}
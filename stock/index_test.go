@@ -0,0 +1,50 @@
+package stock
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIndexes(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		models []mongo.IndexModel
+		index  string
+		keys   bson.D
+		unique bool
+	}{
+		{"company", CompanyIndexes(), "symbol", bson.D{{Key: "symbol", Value: 1}}, true},
+		{"company", CompanyIndexes(), "name", bson.D{{Key: "name", Value: 1}}, false},
+		{"company", CompanyIndexes(), "search", bson.D{{Key: "name", Value: "text"}, {Key: "symbol", Value: "text"}}, false},
+		{"price", PriceIndexes(), "symbol_date", bson.D{{Key: "symbol", Value: 1}, {Key: "date", Value: 1}}, true},
+		{"price", PriceIndexes(), "date_symbol", bson.D{{Key: "date", Value: 1}, {Key: "symbol", Value: 1}}, false},
+	} {
+		var model *mongo.IndexModel
+		for i := range tc.models {
+			if tc.models[i].Options != nil && tc.models[i].Options.Name != nil && *tc.models[i].Options.Name == tc.index {
+				model = &tc.models[i]
+			}
+		}
+		require.NotNil(t, model, "Expected %s index %s", tc.name, tc.index)
+
+		assert.Equal(t, tc.keys, model.Keys, tc.index)
+		assert.Equal(t, tc.unique, model.Options.Unique != nil && *model.Options.Unique, tc.index)
+	}
+
+	assert.Len(t, CompanyIndexes(), 3)
+	assert.Len(t, PriceIndexes(), 2)
+}
+
+func TestIndexerIndexes(t *testing.T) {
+	s := &stockIndexer{Collections: Collections{Company: "company_v2", Price: "price_v2"}}
+
+	indexes := s.indexes()
+
+	assert.Len(t, indexes, 2)
+	assert.Equal(t, CompanyIndexes(), indexes["company_v2"])
+	assert.Equal(t, PriceIndexes(), indexes["price_v2"])
+}
@@ -0,0 +1,162 @@
+package stock
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// batchSize is the number of documents written in a single bulk write.
+const batchSize = 1000
+
+// Loader writes companies and price points. Writes are upserts keyed by
+// symbol, and by symbol and date for price points, so loading the same
+// data twice is harmless.
+type Loader interface {
+	SaveCompanies([]Company) (int64, error)
+	SavePrices([]PricePoint) (int64, error)
+	AddSymbols([]string) (int64, error)
+	ConvertLegacy() (int64, error)
+}
+
+type stockLoader struct {
+	DB          *mongo.Database
+	Collections Collections
+}
+
+// NewLoader returns a Loader for the company and price collections of db.
+func NewLoader(db *mongo.Database, c Collections) Loader {
+	return &stockLoader{DB: db, Collections: c}
+}
+
+// SaveCompanies upserts the given companies and returns how many were saved,
+// unchanged ones included.
+func (s *stockLoader) SaveCompanies(companies []Company) (int64, error) {
+	collection := s.DB.Collection(s.Collections.Company)
+
+	var models []mongo.WriteModel
+	for _, c := range companies {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{Key: "symbol", Value: c.Symbol}}).
+			SetReplacement(c).
+			SetUpsert(true))
+	}
+
+	n, err := write(collection, models)
+	if err != nil {
+		return n, fmt.Errorf("saveCompanies: %s", err)
+	}
+
+	return n, nil
+}
+
+// SavePrices upserts the given price points and returns how many were saved,
+// unchanged ones included.
+func (s *stockLoader) SavePrices(pricePoints []PricePoint) (int64, error) {
+	collection := s.DB.Collection(s.Collections.Price)
+
+	var models []mongo.WriteModel
+	for _, p := range pricePoints {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{Key: "symbol", Value: p.Symbol}, {Key: "date", Value: p.Date}}).
+			SetReplacement(p).
+			SetUpsert(true))
+	}
+
+	n, err := write(collection, models)
+	if err != nil {
+		return n, fmt.Errorf("savePrices: %s", err)
+	}
+
+	return n, nil
+}
+
+// AddSymbols creates a company for every symbol which has none yet, leaving
+// existing companies untouched.
+func (s *stockLoader) AddSymbols(symbols []string) (int64, error) {
+	collection := s.DB.Collection(s.Collections.Company)
+
+	var models []mongo.WriteModel
+	for _, symbol := range symbols {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "symbol", Value: symbol}}).
+			SetUpdate(bson.D{{Key: "$setOnInsert", Value: bson.D{{Key: "symbol", Value: symbol}}}}).
+			SetUpsert(true))
+	}
+
+	n, err := write(collection, models)
+	if err != nil {
+		return n, fmt.Errorf("addSymbols: %s", err)
+	}
+
+	return n, nil
+}
+
+// ConvertLegacy copies the documents of the legacy stock collection, which
+// embed every price point, into the company and price collections. The legacy
+// collection is left untouched.
+func (s *stockLoader) ConvertLegacy() (int64, error) {
+	collection := s.DB.Collection(s.Collections.Legacy)
+
+	ctx := context.Background()
+	cur, err := collection.Find(ctx, bson.D{})
+	if err != nil {
+		return 0, fmt.Errorf("convertLegacy: unable to read stocks: %s", err)
+	}
+	defer cur.Close(ctx)
+
+	var converted int64
+	for cur.Next(ctx) {
+		var st Stock
+		err = cur.Decode(&st)
+		if err != nil {
+			return converted, fmt.Errorf("convertLegacy: error decoding stock: %s", err)
+		}
+
+		_, err = s.SaveCompanies([]Company{st.Company()})
+		if err != nil {
+			return converted, fmt.Errorf("convertLegacy: %s: %s", st.Symbol, err)
+		}
+
+		for i := range st.PricePoints {
+			st.PricePoints[i].Symbol = st.Symbol
+		}
+
+		n, err := s.SavePrices(st.PricePoints)
+		converted += n
+		if err != nil {
+			return converted, fmt.Errorf("convertLegacy: %s: %s", st.Symbol, err)
+		}
+	}
+
+	if err := cur.Err(); err != nil {
+		return converted, fmt.Errorf("convertLegacy: error reading stocks: %s", err)
+	}
+
+	return converted, nil
+}
+
+func write(collection *mongo.Collection, models []mongo.WriteModel) (int64, error) {
+	ctx := context.Background()
+
+	var n int64
+	for start := 0; start < len(models); start += batchSize {
+		end := start + batchSize
+		if end > len(models) {
+			end = len(models)
+		}
+
+		res, err := collection.BulkWrite(ctx, models[start:end], options.BulkWrite().SetOrdered(false))
+		if res != nil {
+			n += res.MatchedCount + res.UpsertedCount
+		}
+		if err != nil {
+			return n, fmt.Errorf("bulk write failed: %s", err)
+		}
+	}
+
+	return n, nil
+}
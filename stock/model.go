@@ -6,6 +6,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Stock is a company along with its price points. It is also the layout of
+// the legacy stock collection which embedded every price point.
 type Stock struct {
 	Id          primitive.ObjectID `json:"-" bson:"_id,omitempty"`
 	Symbol      string             `json:"symbol,omitempty"`
@@ -16,6 +18,17 @@ type Stock struct {
 	PricePoints []PricePoint       `json:"pricePoints,omitempty"`
 }
 
+// Company is a document of the company collection.
+type Company struct {
+	Id        primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	Symbol    string             `json:"symbol,omitempty"`
+	Name      string             `json:"name,omitempty"`
+	MarketCap float64            `json:"marketCap,omitempty"`
+	Sector    string             `json:"sector,omitempty"`
+	Industry  string             `json:"industry,omitempty"`
+}
+
+// PricePoint is a document of the price collection, one per symbol and day.
 type PricePoint struct {
 	Date   time.Time `json:"date,omitempty"`
 	Symbol string    `json:"symbol,omitempty"`
@@ -25,3 +38,26 @@ type PricePoint struct {
 	High   float64   `json:"high,omitempty"`
 	Volume float64   `json:"volume,omitempty"`
 }
+
+// Stock returns the company as a Stock holding the given price points.
+func (c Company) Stock(pp []PricePoint) Stock {
+	return Stock{
+		Symbol:      c.Symbol,
+		Name:        c.Name,
+		MarketCap:   c.MarketCap,
+		Sector:      c.Sector,
+		Industry:    c.Industry,
+		PricePoints: pp,
+	}
+}
+
+// Company returns the company part of a legacy Stock document.
+func (s Stock) Company() Company {
+	return Company{
+		Symbol:    s.Symbol,
+		Name:      s.Name,
+		MarketCap: s.MarketCap,
+		Sector:    s.Sector,
+		Industry:  s.Industry,
+	}
+}
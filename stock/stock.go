@@ -9,26 +9,37 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/x/bsonx"
-
-	"github.com/vikashvverma/stock-backend/constants"
 )
 
+// Trader finds stocks and their prices. Its errors the client can act on
+// are of type *Error.
 type Trader interface {
-	Find(string) ([]PricePoint, error)
-	FindAll([]string, time.Time, time.Time) ([]Stock, error)
-	Top(time.Time, time.Time, bool) (interface{}, error)
+	Find(context.Context, string) ([]PricePoint, error)
+	FindAll(context.Context, []string, time.Time, time.Time) ([]Stock, error)
+	Top(context.Context, time.Time, time.Time, bool) (interface{}, error)
+}
+
+// Collections names the collections of the stocks.
+type Collections struct {
+	Company string
+	Price   string
+	// Legacy is the stock collection embedding the price points, read by
+	// ConvertLegacy.
+	Legacy string
 }
 
 type stockTrader struct {
-	Client *mongo.Client
+	DB          *mongo.Database
+	Collections Collections
 }
 
-func New(c *mongo.Client) Trader {
-	return &stockTrader{Client: c}
+// New returns a Trader reading the collections of db.
+func New(db *mongo.Database, c Collections) Trader {
+	return &stockTrader{DB: db, Collections: c}
 }
 
-func (s *stockTrader) Find(name string) ([]PricePoint, error) {
-	collection := s.Client.Database(constants.Database).Collection(constants.Collection)
+func (s *stockTrader) Find(ctx context.Context, name string) ([]PricePoint, error) {
+	companies := s.DB.Collection(s.Collections.Company)
 	filter := bson.D{{
 		Key: "$or",
 		Value: bson.A{
@@ -36,28 +47,56 @@ func (s *stockTrader) Find(name string) ([]PricePoint, error) {
 			bson.D{{Key: "name", Value: bsonx.String(name)}},
 		},
 	}}
-	res := collection.FindOne(context.Background(), filter)
+	res := companies.FindOne(ctx, filter)
 
 	if err := res.Err(); err != nil {
-		return nil, fmt.Errorf("find: error finding: %s", err)
+		return nil, dbError(ctx, "find", err)
 	}
 
-	var st Stock
-	err := res.Decode(&st)
+	var c Company
+	err := res.Decode(&c)
+	if err == mongo.ErrNoDocuments {
+		return nil, NotFound(name)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("find: could not decode result: %s", err)
 	}
 
-	return st.PricePoints, nil
+	prices := s.DB.Collection(s.Collections.Price)
+	cur, err := prices.Find(ctx, bson.D{{Key: "symbol", Value: c.Symbol}},
+		options.Find().SetSort(bson.D{{Key: "date", Value: 1}}))
+	if err != nil {
+		return nil, dbError(ctx, "find", err)
+	}
+	defer cur.Close(ctx)
+
+	pp := []PricePoint{}
+	for cur.Next(ctx) {
+		var p PricePoint
+		err = cur.Decode(&p)
+		if err != nil {
+			return nil, fmt.Errorf("find: could not decode price point: %s", err)
+		}
+		pp = append(pp, p)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, dbError(ctx, "find", err)
+	}
+
+	return pp, nil
 }
 
-func (s *stockTrader) Top(from, to time.Time, best bool) (interface{}, error) {
-	collection := s.Client.Database(constants.Database).Collection(constants.Collection)
+func (s *stockTrader) Top(ctx context.Context, from, to time.Time, best bool) (interface{}, error) {
+	if from.After(to) {
+		return nil, InvalidRange(from, to)
+	}
+
+	collection := s.DB.Collection(s.Collections.Price)
 	total := bson.D{
 		{
 			Key: "$sum",
 			Value: bson.D{
-				{Key: "$subtract", Value: bson.A{"$pricepoints.close", "$pricepoints.open"}},
+				{Key: "$subtract", Value: bson.A{"$close", "$open"}},
 			},
 		},
 	}
@@ -67,18 +106,15 @@ func (s *stockTrader) Top(from, to time.Time, best bool) (interface{}, error) {
 		order = -1
 	}
 	pipeline := mongo.Pipeline{
-		{{Key: "$match", Value: bson.D{}}},
-		{{Key: "$unwind", Value: "$pricepoints"}},
-		{{Key: "$match", Value: bson.D{{Key: "pricepoints.date", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}}}}},
+		{{Key: "$match", Value: bson.D{{Key: "date", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lte", Value: to}}}}}},
 		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$symbol"}, {Key: "total", Value: total}}}},
 		{{Key: "$sort", Value: bson.D{{Key: "total", Value: order}}}},
 		{{Key: "$limit", Value: 10}},
 	}
 
-	ctx := context.Background()
 	cur, err := collection.Aggregate(ctx, pipeline, options.Aggregate())
 	if err != nil {
-		return nil, fmt.Errorf("top: unable to find top stocks: %s", err)
+		return nil, dbError(ctx, "top", err)
 	}
 	defer cur.Close(ctx)
 
@@ -95,12 +131,17 @@ func (s *stockTrader) Top(from, to time.Time, best bool) (interface{}, error) {
 		}
 		res = append(res, name)
 	}
+	if err := cur.Err(); err != nil {
+		return nil, dbError(ctx, "top", err)
+	}
 
 	return res, nil
 }
 
-func (s *stockTrader) FindAll(tickers []string, from, to time.Time) ([]Stock, error) {
-	collection := s.Client.Database(constants.Database).Collection(constants.Collection)
+func (s *stockTrader) FindAll(ctx context.Context, tickers []string, from, to time.Time) ([]Stock, error) {
+	if from.After(to) {
+		return nil, InvalidRange(from, to)
+	}
 
 	var names []interface{}
 	for _, v := range tickers {
@@ -114,29 +155,70 @@ func (s *stockTrader) FindAll(tickers []string, from, to time.Time) ([]Stock, er
 				{Key: "$in", Value: bson.A(names)},
 			},
 		}, {
-			Key: "pricepoints.date", Value: bson.D{
+			Key: "date", Value: bson.D{
 				{Key: "$gte", Value: from},
 				{Key: "$lte", Value: to},
 			},
 		},
 	}
 
-	ctx := context.Background()
-	cur, err := collection.Find(ctx, filter)
+	cur, err := s.DB.Collection(s.Collections.Price).Find(ctx, filter,
+		options.Find().SetSort(bson.D{{Key: "symbol", Value: 1}, {Key: "date", Value: 1}}))
 	if err != nil {
-		return nil, fmt.Errorf("findAll: unable to find stocks: %s", err)
+		return nil, dbError(ctx, "findAll", err)
 	}
 	defer cur.Close(ctx)
 
-	var res []Stock
+	var symbols []interface{}
+	pricePoints := map[string][]PricePoint{}
 	for cur.Next(ctx) {
-		var result Stock
-		err = cur.Decode(&result)
+		var p PricePoint
+		err = cur.Decode(&p)
+		if err != nil {
+			return nil, fmt.Errorf("findAll: error decoding price point: %s", err)
+		}
+
+		if _, ok := pricePoints[p.Symbol]; !ok {
+			symbols = append(symbols, p.Symbol)
+		}
+		pricePoints[p.Symbol] = append(pricePoints[p.Symbol], p)
+	}
+	if err := cur.Err(); err != nil {
+		return nil, dbError(ctx, "findAll", err)
+	}
+
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+
+	companyCur, err := s.DB.Collection(s.Collections.Company).Find(ctx,
+		bson.D{{Key: "symbol", Value: bson.D{{Key: "$in", Value: bson.A(symbols)}}}})
+	if err != nil {
+		return nil, dbError(ctx, "findAll", err)
+	}
+	defer companyCur.Close(ctx)
+
+	companies := map[string]Company{}
+	for companyCur.Next(ctx) {
+		var c Company
+		err = companyCur.Decode(&c)
 		if err != nil {
 			return nil, fmt.Errorf("findAll: error decoding result: %s", err)
 		}
+		companies[c.Symbol] = c
+	}
+	if err := companyCur.Err(); err != nil {
+		return nil, dbError(ctx, "findAll", err)
+	}
 
-		res = append(res, result)
+	var res []Stock
+	for _, v := range symbols {
+		symbol := v.(string)
+		c, ok := companies[symbol]
+		if !ok {
+			c = Company{Symbol: symbol}
+		}
+		res = append(res, c.Stock(pricePoints[symbol]))
 	}
 
 	return res, nil
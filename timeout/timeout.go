@@ -0,0 +1,62 @@
+package timeout
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/vikashvverma/stock-backend/config"
+	"github.com/vikashvverma/stock-backend/route"
+)
+
+// Timeout is the middleware setting the deadline of requests.
+type Timeout interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+}
+
+type deadline struct {
+	route.Pattern
+	timeout time.Duration
+}
+
+type requestTimeout struct {
+	deadlines []deadline
+}
+
+// New returns a Timeout setting the deadline of the first of the timeouts
+// matching a request on its context. Handlers pass the context to the
+// stock.Trader, which gives up on the queries past the deadline so the
+// handlers answer with a 504.
+func New(specs []config.TimeoutSpec) (Timeout, error) {
+	rt := &requestTimeout{}
+	for _, spec := range specs {
+		p, err := route.New(spec.Method, spec.Path)
+		if err != nil {
+			return nil, fmt.Errorf("new: timeout %s: %s", spec.Path, err)
+		}
+
+		rt.deadlines = append(rt.deadlines, deadline{Pattern: p, timeout: spec.Duration()})
+	}
+
+	return rt, nil
+}
+
+func (rt *requestTimeout) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	for _, d := range rt.deadlines {
+		if !d.Match(r.Method, r.URL.Path) {
+			continue
+		}
+		if d.timeout <= 0 {
+			break
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), d.timeout)
+		defer cancel()
+
+		next(w, r.WithContext(ctx))
+		return
+	}
+
+	next(w, r)
+}
@@ -0,0 +1,47 @@
+package timeout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+func TestServeHTTP(t *testing.T) {
+	timeout, err := New([]config.TimeoutSpec{
+		{Path: "/stock/top/*", Timeout: "0"},
+		{Method: http.MethodGet, Path: "/stock/*", Timeout: "2s"},
+	})
+	require.NoError(t, err, "Expected no error")
+
+	for _, tc := range []struct {
+		method, path string
+		deadline     bool
+	}{
+		{http.MethodGet, "/stock/AAPL", true},
+		{http.MethodPost, "/stock/AAPL", false},
+		{http.MethodGet, "/stock/top/01-01-2016/01-02-2016", false},
+		{http.MethodGet, "/healthcheck", false},
+	} {
+		var deadline time.Time
+		var ok bool
+		timeout.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil), func(w http.ResponseWriter, r *http.Request) {
+			deadline, ok = r.Context().Deadline()
+		})
+
+		assert.Equal(t, tc.deadline, ok, "%s %s", tc.method, tc.path)
+		if tc.deadline {
+			assert.WithinDuration(t, time.Now().Add(2*time.Second), deadline, time.Second)
+		}
+	}
+}
+
+func TestNewFailsWhenPatternInvalid(t *testing.T) {
+	_, err := New([]config.TimeoutSpec{{Path: "/stock/{name:[}", Timeout: "1s"}})
+	assert.Error(t, err)
+}
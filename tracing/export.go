@@ -0,0 +1,238 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+// Exporter sends finished spans to a tracing backend.
+type Exporter interface {
+	Export(service string, spans []*Span) error
+	Close() error
+}
+
+// NewExporter returns the exporter of the configuration.
+func NewExporter(spec config.TracingSpec) (Exporter, error) {
+	switch spec.Exporter {
+	case config.TracingStdout:
+		return &writerExporter{w: os.Stdout}, nil
+	case config.TracingFile:
+		f, err := os.OpenFile(spec.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("new exporter: %s", err)
+		}
+		return &writerExporter{w: f, c: f}, nil
+	case config.TracingOTLP:
+		return &otlpExporter{endpoint: spec.Endpoint, client: &http.Client{Timeout: 10 * time.Second}}, nil
+	default:
+		return nil, fmt.Errorf("new exporter: unknown exporter %q", spec.Exporter)
+	}
+}
+
+// jsonSpan is a span written by the writer exporter.
+type jsonSpan struct {
+	Service    string            `json:"service"`
+	TraceID    string            `json:"traceId"`
+	SpanID     string            `json:"spanId"`
+	ParentID   string            `json:"parentId,omitempty"`
+	Name       string            `json:"name"`
+	Kind       string            `json:"kind"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Duration   float64           `json:"durationMs"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// writerExporter writes spans as JSON lines.
+type writerExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+	c  io.Closer
+}
+
+func (e *writerExporter) Export(service string, spans []*Span) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, s := range spans {
+		js := jsonSpan{
+			Service:    service,
+			TraceID:    s.Context.TraceID.String(),
+			SpanID:     s.Context.SpanID.String(),
+			Name:       s.Name,
+			Kind:       s.Kind,
+			Start:      s.Start,
+			End:        s.End,
+			Duration:   float64(s.End.Sub(s.Start)) / float64(time.Millisecond),
+			Attributes: s.Attributes,
+			Error:      s.Error,
+		}
+		if s.Parent != (SpanID{}) {
+			js.ParentID = s.Parent.String()
+		}
+		if err := enc.Encode(js); err != nil {
+			return fmt.Errorf("export: %s", err)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("export: %s", err)
+	}
+
+	return nil
+}
+
+func (e *writerExporter) Close() error {
+	if e.c == nil {
+		return nil
+	}
+
+	return e.c.Close()
+}
+
+// OTLP span kinds and status codes.
+const (
+	otlpKindInternal = 1
+	otlpKindServer   = 2
+	otlpKindClient   = 3
+	otlpStatusError  = 2
+)
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+// otlpExporter posts spans to an OTLP/HTTP endpoint in the JSON encoding.
+type otlpExporter struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (e *otlpExporter) Export(service string, spans []*Span) error {
+	scope := otlpScopeSpans{Scope: otlpScope{Name: "github.com/vikashvverma/stock-backend/tracing"}}
+	for _, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.Context.TraceID.String(),
+			SpanID:            s.Context.SpanID.String(),
+			Name:              s.Name,
+			Kind:              otlpKind(s.Kind),
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.Parent != (SpanID{}) {
+			span.ParentSpanID = s.Parent.String()
+		}
+		if s.Error != "" {
+			span.Status = &otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+		scope.Spans = append(scope.Spans, span)
+	}
+
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]string{"service.name": service})},
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}})
+	if err != nil {
+		return fmt.Errorf("export: %s", err)
+	}
+
+	res, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("export: %s", err)
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("export: %s answered %s", e.endpoint, res.Status)
+	}
+
+	return nil
+}
+
+func (e *otlpExporter) Close() error {
+	return nil
+}
+
+func otlpKind(kind string) int {
+	switch kind {
+	case KindServer:
+		return otlpKindServer
+	case KindClient:
+		return otlpKindClient
+	default:
+		return otlpKindInternal
+	}
+}
+
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	list := make([]otlpAttribute, len(keys))
+	for i, k := range keys {
+		list[i].Key = k
+		list[i].Value.StringValue = attributes[k]
+	}
+
+	return list
+}
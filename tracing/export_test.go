@@ -0,0 +1,81 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+func testSpan() *Span {
+	sc, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	start := time.Date(2019, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	return &Span{
+		Context:    sc,
+		Parent:     SpanID{1},
+		Name:       "stock.Trader/Top",
+		Kind:       KindInternal,
+		Start:      start,
+		End:        start.Add(1500 * time.Microsecond),
+		Attributes: map[string]string{"stock.best": "true"},
+		Error:      "boom",
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	var buf bytes.Buffer
+	e := &writerExporter{w: &buf}
+
+	require.NoError(t, e.Export("stock", []*Span{testSpan()}), "Expected no error")
+
+	assert.JSONEq(t, `{"service":"stock","traceId":"4bf92f3577b34da6a3ce929d0e0e4736","spanId":"00f067aa0ba902b7",
+		"parentId":"0100000000000000","name":"stock.Trader/Top","kind":"internal","start":"2019-05-01T10:00:00Z",
+		"end":"2019-05-01T10:00:00.0015Z","durationMs":1.5,"attributes":{"stock.best":"true"},"error":"boom"}`, buf.String())
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	e, err := NewExporter(config.TracingSpec{Exporter: config.TracingOTLP, Endpoint: server.URL + "/v1/traces"})
+	require.NoError(t, err, "Expected no error")
+	require.NoError(t, e.Export("stock", []*Span{testSpan()}), "Expected no error")
+
+	var req otlpRequest
+	require.NoError(t, json.Unmarshal(body, &req), "Expected no error")
+	require.Len(t, req.ResourceSpans, 1)
+	assert.Equal(t, "service.name", req.ResourceSpans[0].Resource.Attributes[0].Key)
+	assert.Equal(t, "stock", req.ResourceSpans[0].Resource.Attributes[0].Value.StringValue)
+
+	span := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID)
+	assert.Equal(t, "0100000000000000", span.ParentSpanID)
+	assert.Equal(t, otlpKindInternal, span.Kind)
+	assert.Equal(t, "1556704800000000000", span.StartTimeUnixNano)
+	assert.Equal(t, &otlpStatus{Code: otlpStatusError, Message: "boom"}, span.Status)
+}
+
+func TestOTLPExporterFailsWhenRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	e, err := NewExporter(config.TracingSpec{Exporter: config.TracingOTLP, Endpoint: server.URL})
+	require.NoError(t, err, "Expected no error")
+
+	assert.Error(t, e.Export("stock", []*Span{testSpan()}))
+}
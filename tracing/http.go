@@ -0,0 +1,70 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/codegangsta/negroni"
+	"github.com/gorilla/mux"
+
+	"github.com/vikashvverma/stock-backend/requestid"
+	"github.com/vikashvverma/stock-backend/route"
+)
+
+// TraceparentHeader is the W3C header propagating the trace of a request.
+const TraceparentHeader = "traceparent"
+
+// Middleware traces the requests.
+type Middleware interface {
+	ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc)
+}
+
+type requestTracer struct {
+	tracer *Tracer
+	router *mux.Router
+}
+
+// NewMiddleware returns a Middleware starting a server span per request,
+// named after the path template of the router route it matches. A request
+// with a valid traceparent header continues its trace.
+func NewMiddleware(t *Tracer, router *mux.Router) Middleware {
+	return &requestTracer{tracer: t, router: router}
+}
+
+func (rt *requestTracer) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if rt.tracer == nil {
+		next(w, r)
+		return
+	}
+
+	ctx := r.Context()
+	if parent, ok := ParseTraceparent(r.Header.Get(TraceparentHeader)); ok {
+		ctx = ContextWithSpanContext(ctx, parent)
+	}
+
+	template := route.Template(rt.router, r)
+	ctx, span := rt.tracer.Start(ctx, r.Method+" "+template, KindServer)
+	defer span.Finish()
+
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.route", template)
+	span.SetAttribute("http.target", r.URL.RequestURI())
+	span.SetAttribute("request.id", requestid.FromContext(ctx))
+
+	res, ok := w.(negroni.ResponseWriter)
+	if !ok {
+		res = negroni.NewResponseWriter(w)
+	}
+
+	next(res, r.WithContext(ctx))
+
+	status := res.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	span.SetAttribute("http.status_code", strconv.Itoa(status))
+	if status >= http.StatusInternalServerError {
+		span.SetError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+	}
+}
@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codegangsta/negroni"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeHTTP(t *testing.T) {
+	tracer, exporter := newTestTracer(0)
+	router := mux.NewRouter()
+	router.HandleFunc("/stock/{name}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracer.Start(r.Context(), "handler", KindInternal)
+		span.Finish()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	n := negroni.New(NewMiddleware(tracer, router))
+	n.UseHandler(router)
+
+	r := httptest.NewRequest(http.MethodGet, "/stock/AAPL", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	n.ServeHTTP(httptest.NewRecorder(), r)
+
+	require.NoError(t, tracer.Close(), "Expected no error")
+	require.Len(t, exporter.spans, 2)
+
+	handler, server := exporter.spans[0], exporter.spans[1]
+	assert.Equal(t, "GET /stock/{name}", server.Name)
+	assert.Equal(t, KindServer, server.Kind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.Context.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.String())
+	assert.Equal(t, "503", server.Attributes["http.status_code"])
+	assert.Equal(t, "503 Service Unavailable", server.Error)
+	assert.Equal(t, server.Context.SpanID, handler.Parent)
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// CommandMonitor returns a Mongo command monitor starting a client span per
+// command, calling next as well if not nil. It returns next if the tracer is
+// nil.
+func CommandMonitor(t *Tracer, next *event.CommandMonitor) *event.CommandMonitor {
+	if t == nil {
+		return next
+	}
+	if next == nil {
+		next = &event.CommandMonitor{}
+	}
+
+	var mu sync.Mutex
+	spans := map[int64]*Span{}
+
+	finish := func(e event.CommandFinishedEvent, failure string) {
+		mu.Lock()
+		span := spans[e.RequestID]
+		delete(spans, e.RequestID)
+		mu.Unlock()
+
+		if failure != "" {
+			span.SetError(errors.New(failure))
+		}
+		span.Finish()
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			_, span := t.Start(ctx, "mongo."+e.CommandName, KindClient)
+			span.SetAttribute("db.system", "mongodb")
+			span.SetAttribute("db.name", e.DatabaseName)
+			span.SetAttribute("db.operation", e.CommandName)
+			span.SetAttribute("db.mongodb.request_id", strconv.FormatInt(e.RequestID, 10))
+
+			mu.Lock()
+			spans[e.RequestID] = span
+			mu.Unlock()
+
+			if next.Started != nil {
+				next.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			finish(e.CommandFinishedEvent, "")
+			if next.Succeeded != nil {
+				next.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			finish(e.CommandFinishedEvent, e.Failure)
+			if next.Failed != nil {
+				next.Failed(ctx, e)
+			}
+		},
+	}
+}
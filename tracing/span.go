@@ -0,0 +1,164 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span kinds.
+const (
+	KindInternal = "internal"
+	KindServer   = "server"
+	KindClient   = "client"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanID identifies a span of a trace.
+type SpanID [8]byte
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// SpanContext is the part of a span propagated to its children, in the
+// same process or through the traceparent header.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// Valid tells whether the trace and span ids are set.
+func (sc SpanContext) Valid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Traceparent returns the W3C traceparent header of the span context.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent parses a W3C traceparent header, it returns false if
+// the header isn't valid. Future versions may append fields.
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+
+	var sc SpanContext
+	var version, flags [1]byte
+	for _, f := range []struct {
+		dst []byte
+		src string
+	}{{version[:], parts[0]}, {sc.TraceID[:], parts[1]}, {sc.SpanID[:], parts[2]}, {flags[:], parts[3]}} {
+		if _, err := hex.Decode(f.dst, []byte(f.src)); err != nil {
+			return SpanContext{}, false
+		}
+	}
+	sc.Sampled = flags[0]&1 == 1
+
+	return sc, sc.Valid()
+}
+
+type spanKey struct{}
+
+// ContextWithSpanContext returns a copy of ctx whose spans are children of
+// sc.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of ctx, if any.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Span is a timed operation of a trace. The methods of a nil Span do
+// nothing, so code can trace without checking whether tracing is enabled.
+type Span struct {
+	tracer *Tracer
+
+	Context    SpanContext
+	Parent     SpanID
+	Name       string
+	Kind       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	// Error is the error the operation failed with, if any.
+	Error string
+
+	mu    sync.Mutex
+	ended bool
+}
+
+// SetAttribute sets an attribute of the span.
+func (s *Span) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Attributes[key] = value
+}
+
+// SetError records that the operation failed.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Error = err.Error()
+}
+
+// Finish ends the span and queues it for export if the trace is sampled.
+// Only the first call has an effect.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+
+	if s.Context.Sampled {
+		s.tracer.export(s)
+	}
+}
+
+func randomID(b []byte) {
+	// crypto/rand doesn't fail on the supported platforms.
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("tracing: unable to generate id: %s", err))
+	}
+}
@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+const (
+	// queueSize is the number of finished spans waiting for export, more
+	// are dropped.
+	queueSize = 2048
+	// batchSize is the number of spans exported at once.
+	batchSize = 128
+	// flushInterval is how often the queued spans are exported.
+	flushInterval = 5 * time.Second
+)
+
+// Tracer starts spans and exports them in batches. The methods of a nil
+// Tracer start nil spans, so a disabled tracer costs nothing.
+type Tracer struct {
+	service  string
+	ratio    float64
+	exporter Exporter
+	logger   *logrus.Logger
+
+	spans chan *Span
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// New returns the Tracer of the configuration, nil if tracing is disabled.
+func New(spec config.TracingSpec, l *logrus.Logger) (*Tracer, error) {
+	if !spec.Enabled() {
+		return nil, nil
+	}
+
+	exporter, err := NewExporter(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTracer(spec, exporter, l), nil
+}
+
+func newTracer(spec config.TracingSpec, exporter Exporter, l *logrus.Logger) *Tracer {
+	ratio := spec.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+
+	t := &Tracer{
+		service:  spec.ServiceName,
+		ratio:    ratio,
+		exporter: exporter,
+		logger:   l,
+		spans:    make(chan *Span, queueSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+
+	return t
+}
+
+// Start starts a span, the child of the span of ctx if any, and returns a
+// context for the children of the new span. The span must be finished.
+func (t *Tracer) Start(ctx context.Context, name, kind string) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	s := &Span{
+		tracer:     t,
+		Name:       name,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: map[string]string{},
+	}
+
+	if parent, ok := SpanContextFromContext(ctx); ok && parent.Valid() {
+		s.Context.TraceID = parent.TraceID
+		s.Context.Sampled = parent.Sampled
+		s.Parent = parent.SpanID
+	} else {
+		randomID(s.Context.TraceID[:])
+		s.Context.Sampled = t.sample(s.Context.TraceID)
+	}
+	randomID(s.Context.SpanID[:])
+
+	return ContextWithSpanContext(ctx, s.Context), s
+}
+
+// sample decides from the random trace id, like other tracers do, so
+// services sampling at the same ratio keep the same traces.
+func (t *Tracer) sample(id TraceID) bool {
+	if t.ratio >= 1 {
+		return true
+	}
+
+	return float64(binary.BigEndian.Uint64(id[8:])>>11)/(1<<53) < t.ratio
+}
+
+func (t *Tracer) export(s *Span) {
+	select {
+	case t.spans <- s:
+	default:
+		t.logger.Warnf("Tracer: queue full, dropped span %s", s.Name)
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(t.service, batch); err != nil {
+			t.logger.WithError(err).Errorf("Tracer: unable to export %d spans", len(batch))
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case s := <-t.spans:
+			batch = append(batch, s)
+			if len(batch) >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-t.stop:
+			for {
+				select {
+				case s := <-t.spans:
+					batch = append(batch, s)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close exports the queued spans and closes the exporter. Spans finished
+// afterwards aren't exported.
+func (t *Tracer) Close() error {
+	if t == nil {
+		return nil
+	}
+
+	close(t.stop)
+	<-t.done
+
+	if err := t.exporter.Close(); err != nil {
+		return fmt.Errorf("close: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vikashvverma/stock-backend/config"
+)
+
+type fakeExporter struct {
+	mu     sync.Mutex
+	spans  []*Span
+	closed bool
+}
+
+func (e *fakeExporter) Export(service string, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *fakeExporter) Close() error {
+	e.closed = true
+	return nil
+}
+
+func newTestTracer(ratio float64) (*Tracer, *fakeExporter) {
+	logger, _ := test.NewNullLogger()
+	exporter := &fakeExporter{}
+	return newTracer(config.TracingSpec{ServiceName: "stock", SampleRatio: ratio}, exporter, logger), exporter
+}
+
+func TestTracerStart(t *testing.T) {
+	tracer, exporter := newTestTracer(0)
+
+	ctx, root := tracer.Start(context.Background(), "root", KindServer)
+	_, child := tracer.Start(ctx, "child", KindInternal)
+	child.SetError(errors.New("boom"))
+	child.Finish()
+	root.Finish()
+	root.Finish()
+
+	require.NoError(t, tracer.Close(), "Expected no error")
+	require.Len(t, exporter.spans, 2)
+	assert.True(t, exporter.closed)
+
+	assert.Equal(t, "child", exporter.spans[0].Name)
+	assert.Equal(t, root.Context.TraceID, exporter.spans[0].Context.TraceID)
+	assert.Equal(t, root.Context.SpanID, exporter.spans[0].Parent)
+	assert.Equal(t, "boom", exporter.spans[0].Error)
+	assert.Equal(t, SpanID{}, exporter.spans[1].Parent)
+}
+
+func TestTracerFollowsRemoteSampling(t *testing.T) {
+	tracer, exporter := newTestTracer(1)
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	_, span := tracer.Start(ContextWithSpanContext(context.Background(), parent), "remote", KindServer)
+	span.Finish()
+
+	require.NoError(t, tracer.Close(), "Expected no error")
+	assert.Equal(t, parent.TraceID, span.Context.TraceID)
+	assert.False(t, span.Context.Sampled)
+	assert.Empty(t, exporter.spans)
+}
+
+func TestNilTracer(t *testing.T) {
+	var tracer *Tracer
+	ctx := context.Background()
+
+	spanCtx, span := tracer.Start(ctx, "noop", KindInternal)
+	span.SetAttribute("key", "value")
+	span.Finish()
+
+	assert.Nil(t, span)
+	assert.Equal(t, ctx, spanCtx)
+	assert.NoError(t, tracer.Close(), "Expected no error")
+}
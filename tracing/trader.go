@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vikashvverma/stock-backend/stock"
+)
+
+// dateFormat is the format of the date attributes.
+const dateFormat = "2006-01-02"
+
+type trader struct {
+	next   stock.Trader
+	tracer *Tracer
+}
+
+// Trader returns a stock.Trader tracing the methods of t, t itself if the
+// tracer is nil.
+func Trader(t stock.Trader, tracer *Tracer) stock.Trader {
+	if tracer == nil {
+		return t
+	}
+
+	return &trader{next: t, tracer: tracer}
+}
+
+func (t *trader) Find(ctx context.Context, name string) ([]stock.PricePoint, error) {
+	ctx, span := t.tracer.Start(ctx, "stock.Trader/Find", KindInternal)
+	defer span.Finish()
+	span.SetAttribute("stock.name", name)
+
+	points, err := t.next.Find(ctx, name)
+	span.SetError(err)
+	return points, err
+}
+
+func (t *trader) FindAll(ctx context.Context, tickers []string, from, to time.Time) ([]stock.Stock, error) {
+	ctx, span := t.tracer.Start(ctx, "stock.Trader/FindAll", KindInternal)
+	defer span.Finish()
+	span.SetAttribute("stock.tickers", strings.Join(tickers, ","))
+	span.SetAttribute("stock.from", from.Format(dateFormat))
+	span.SetAttribute("stock.to", to.Format(dateFormat))
+
+	stocks, err := t.next.FindAll(ctx, tickers, from, to)
+	span.SetError(err)
+	return stocks, err
+}
+
+func (t *trader) Top(ctx context.Context, from, to time.Time, best bool) (interface{}, error) {
+	ctx, span := t.tracer.Start(ctx, "stock.Trader/Top", KindInternal)
+	defer span.Finish()
+	span.SetAttribute("stock.from", from.Format(dateFormat))
+	span.SetAttribute("stock.to", to.Format(dateFormat))
+	span.SetAttribute("stock.best", strconv.FormatBool(best))
+
+	top, err := t.next.Top(ctx, from, to, best)
+	span.SetError(err)
+	return top, err
+}